@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/isacskoglund/rotox/internal/fault"
+	"github.com/isacskoglund/rotox/internal/hub"
+)
+
+// AddProbe implements hub.ProbeManager, creating a client for the probe host.
+// Probes added through the admin API are removed on the next reload unless
+// they are also added to the config file.
+func (srv *hubServer) AddProbe(req hub.AddProbeRequest) (*hub.Probe, error) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	secret := ""
+	if req.SecretEnv != "" {
+		if !srv.isProbeSecretEnv(req.SecretEnv) {
+			return nil, fault.New(
+				fmt.Sprintf("environment variable %s is not the secret of a configured probe group", req.SecretEnv),
+				hub.PoolInvalidProbe,
+			)
+		}
+		secret = os.Getenv(req.SecretEnv)
+		if secret == "" {
+			return nil, fault.New(
+				fmt.Sprintf("environment variable %s is not set", req.SecretEnv),
+				hub.PoolInvalidProbe,
+			)
+		}
+	}
+	if _, ok := srv.clients[req.Host]; ok {
+		return nil, fault.New(fmt.Sprintf("probe %s already exists", req.Host), hub.PoolProbeExists)
+	}
+	client, err := newProbeClient(req.Host, secret, req.RequireTls, srv.ipEchoUrl)
+	if err != nil {
+		return nil, fault.Wrap(err, "invalid probe", hub.PoolInvalidProbe)
+	}
+	spec := client.spec(req.Host)
+	spec.Weight = req.Weight
+	spec.MaxConnections = req.MaxConcurrentConnections
+	spec.Group = req.Group
+	spec.Labels = req.Labels
+	probe, err := srv.core.AddProbe(spec)
+	if err != nil {
+		client.conn.Close()
+		return nil, err
+	}
+	srv.clients[req.Host] = client
+	return probe, nil
+}
+
+// isProbeSecretEnv reports whether env holds the secret of a configured probe
+// group. Probes added through the admin API may only use those secrets, since
+// the secret is sent to the probe host chosen by the caller.
+func (srv *hubServer) isProbeSecretEnv(env string) bool {
+	return slices.ContainsFunc(srv.cfg.Probes, func(probe ProbeConfig) bool {
+		return probe.SecretEnv != nil && *probe.SecretEnv == env
+	})
+}
+
+// RemoveProbe implements hub.ProbeManager, closing the client of the probe
+// once the connections still using it have finished.
+func (srv *hubServer) RemoveProbe(name string) error {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	probe, err := srv.core.RemoveProbe(name)
+	if err != nil {
+		return err
+	}
+	stale := map[string]*probeClient{}
+	if client, ok := srv.clients[name]; ok {
+		stale[name] = client
+		delete(srv.clients, name)
+	}
+	go srv.releaseProbeClients([]*hub.Probe{probe}, stale)
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/isacskoglund/rotox/internal/fault"
+	"github.com/isacskoglund/rotox/internal/hub"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddProbe_SecretEnv(t *testing.T) {
+	t.Setenv("PROBES_SECRET", "probe-secret")
+	t.Setenv("HTTP_PROXY_SECRET", "proxy-secret")
+	srv := startServer(t, fmt.Sprintf(`
+proxies:
+    http:
+        port: %d
+        secret_env: HTTP_PROXY_SECRET
+probes:
+    - secret_env: PROBES_SECRET
+      require_tls: false
+      hosts: [probe-a:8000]
+`, freePort(t)))
+
+	// Other secrets of the hub are never sent to a probe host.
+	_, err := srv.AddProbe(hub.AddProbeRequest{Host: "probe-b:8000", SecretEnv: "HTTP_PROXY_SECRET"})
+	assert.Equal(t, hub.PoolInvalidProbe, fault.Code[hub.PoolErrorCode](err))
+
+	probe, err := srv.AddProbe(hub.AddProbeRequest{Host: "probe-b:8000", SecretEnv: "PROBES_SECRET"})
+	assert.NoError(t, err)
+	assert.Equal(t, "probe-b:8000", probe.Name())
+	assert.Equal(t, "probe-secret", srv.clients["probe-b:8000"].secret)
+}
@@ -4,13 +4,17 @@
 // It receives incoming proxy requests from clients and forwards them to one
 // of the available probes, distributing the traffic over a pool public IP addresses.
 //
-// The hub supports two proxy protocols:
+// The hub supports the following proxy protocols:
 //   - HTTP plaintext requests: Direct forwarding of HTTP requests
 //   - HTTP CONNECT requests: Tunneling for HTTPS and other protocols
+//   - Both of the above over TLS (HTTPS proxy)
+//   - SOCKS5 CONNECT requests: Tunneling for any TCP based protocol
+//   - Transparent proxying of connections redirected by the firewall (Linux)
 //
 // Configuration is loaded from a YAML file specified by the CONFIG_FILE
 // environment variable. The hub can manage multiple probe groups with
-// different authentication secrets and TLS requirements.
+// different authentication secrets and TLS requirements. Sending SIGHUP to the
+// hub reloads the configuration without dropping established connections.
 package main
 
 import (
@@ -23,6 +27,7 @@ import (
 	"os"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	forward_pb "github.com/isacskoglund/rotox/gen/go/forward/v1"
@@ -43,18 +48,82 @@ type ProbeConfig struct {
 	SecretEnv  *string  `yaml:"secret_env" validate:"omitempty,envexists"` // Environment variable containing the probe secret
 	RequireTls *bool    `yaml:"require_tls" validate:"required"`           // Whether TLS is required for probe connections
 	Hosts      []string `yaml:"hosts" validate:"required,min=1,dive"`      // The address of each probe in this group
+	Weight     int      `yaml:"weight" validate:"omitempty,min=1"`         // Relative weight of each probe in this group (weighted strategy)
+
+	MaxConcurrentConnections int `yaml:"max_concurrent_connections" validate:"gte=0"` // Connection limit of each host in this group, e.g. the Cloud Run concurrency, shared by its connections
+	Connections              int `yaml:"connections" validate:"omitempty,min=1"`      // Independent gRPC connections per host, each a separate probe in the pool
+
+	Group  string            `yaml:"group"`  // Name of this group, which clients can ask for through their username
+	Labels map[string]string `yaml:"labels"` // Labels of the probes in this group (e.g. region, provider), matched by label selectors
+}
+
+// RateLimitConfig represents the rate limits of the target hosts matching a pattern.
+type RateLimitConfig struct {
+	Pattern           string        `yaml:"pattern" validate:"required"`                // Target hosts the limits apply to: "example.com", "*.example.com" or "*"
+	Key               string        `yaml:"key" validate:"omitempty,oneof=host domain"` // Whether hosts are limited separately or per registrable domain
+	RequestsPerSecond float64       `yaml:"requests_per_second" validate:"gte=0"`       // Sustained rate of new connections
+	Burst             int           `yaml:"burst" validate:"gte=0"`                     // Connections allowed at once above the sustained rate
+	MaxConnections    int           `yaml:"max_connections" validate:"gte=0"`           // Maximum number of concurrent connections
+	MaxWait           time.Duration `yaml:"max_wait" validate:"gte=0"`                  // How long a connection waits for the limits to allow it
+}
+
+// RouteConfig represents a routing rule deciding how connections to the
+// targets it matches are routed.
+type RouteConfig struct {
+	Name      string   `yaml:"name" validate:"required"`                             // Name of the rule, used in logs
+	Hosts     []string `yaml:"hosts"`                                                // Glob patterns of target hosts, e.g. "*.example.com"
+	HostRegex string   `yaml:"host_regex"`                                           // Regular expression of target hosts
+	Ports     []int    `yaml:"ports" validate:"dive,min=1,max=65535"`                // Target ports
+	Cidrs     []string `yaml:"cidrs" validate:"dive,cidr"`                           // IP ranges of IP address targets
+	Action    string   `yaml:"action" validate:"required,oneof=group reject direct"` // What happens to matching connections
+	Group     string   `yaml:"group" validate:"required_if=Action group"`            // Probe group used by the group action
+
+	SetHeaders map[string]string `yaml:"set_headers"` // Headers set on plaintext HTTP requests to matching targets
+}
+
+// ProxyConfig represents the configuration of a proxy listener.
+// Clients must authenticate if a secret and/or a users file is configured.
+type ProxyConfig struct {
+	SecretEnv *string `yaml:"secret_env" validate:"omitempty,envexists"` // Environment variable for the proxy secret
+	UsersFile *string `yaml:"users_file" validate:"omitempty,file"`      // Path to a htpasswd-style file with bcrypt hashed credentials
+	Port      int     `yaml:"port" validate:"required,min=1,max=65535"`  // Port for the proxy listener
+
+	LabelSelector string `yaml:"label_selector"` // Label selector for clients that do not send one, e.g. "region=eu"
+}
+
+// HttpsProxyConfig represents the configuration of the HTTPS proxy listener,
+// which serves the HTTP proxy over TLS.
+type HttpsProxyConfig struct {
+	ProxyConfig `yaml:",inline"`
+
+	CertFile     string `yaml:"cert_file" validate:"required,file"`       // PEM certificate chain of the listener
+	KeyFile      string `yaml:"key_file" validate:"required,file"`        // PEM private key of the certificate
+	ClientCaFile string `yaml:"client_ca_file" validate:"omitempty,file"` // PEM CA certificates that must sign client certificates, which are then required
+}
+
+// TransparentProxyConfig represents the configuration of the transparent
+// proxy listener, which receives connections redirected to it by the firewall,
+// e.g. with iptables REDIRECT. Clients are not authenticated.
+type TransparentProxyConfig struct {
+	Port           int    `yaml:"port" validate:"required,min=1,max=65535"` // Port the connections are redirected to
+	LabelSelector  string `yaml:"label_selector"`                           // Label selector of the probes used for every connection
+	SniffHostnames bool   `yaml:"sniff_hostnames"`                          // Whether target host names are read from TLS SNI or the HTTP Host header
 }
 
 // Config represents the complete hub configuration loaded from YAML.
 type Config struct {
-	LogLevel  string `yaml:"log_level" validate:"required,oneof=debug info warn error"` // Logging verbosity level
-	LogFormat string `yaml:"log_format" validate:"required,oneof=json text"`            // Log output format
+	LogLevel  string `yaml:"log_level" validate:"required,oneof=debug info warn error"`                                                // Logging verbosity level
+	LogFormat string `yaml:"log_format" validate:"required,oneof=json text"`                                                           // Log output format
+	Selector  string `yaml:"selector" validate:"required,oneof=round_robin random weighted least_connections latency consistent_hash"` // Probe selection strategy
+
+	MaxDialAttempts int    `yaml:"max_dial_attempts" validate:"gte=0"`   // Probes tried per connection when a probe is unavailable, defaults to 3
+	IpEchoUrl       string `yaml:"ip_echo_url" validate:"omitempty,url"` // Echo service used by probes to discover their egress IP
 
 	Proxies struct {
-		Http *struct {
-			SecretEnv *string `yaml:"secret_env" validate:"omitempty,envexists"` // Environment variable for HTTP proxy secret
-			Port      int     `yaml:"port" validate:"required,min=1,max=65535"`  // Port for HTTP proxy listener
-		} `yaml:"http"`
+		Http        *ProxyConfig            `yaml:"http"`        // HTTP proxy listener (plaintext and CONNECT)
+		Https       *HttpsProxyConfig       `yaml:"https"`       // HTTP proxy listener over TLS
+		Socks5      *ProxyConfig            `yaml:"socks5"`      // SOCKS5 proxy listener
+		Transparent *TransparentProxyConfig `yaml:"transparent"` // Transparent proxy listener
 	} `yaml:"proxies"`
 
 	Telemetry *struct {
@@ -62,28 +131,95 @@ type Config struct {
 		Port      int     `yaml:"port" validate:"required,min=1,max=65535"`  // Port for telemetry server
 	} `yaml:"telemetry"`
 
+	Admin *struct {
+		SecretEnv string `yaml:"secret_env" validate:"required,envexists"` // Environment variable for the admin API secret
+		Port      int    `yaml:"port" validate:"required,min=1,max=65535"` // Port for the admin API
+	} `yaml:"admin"` // Admin API for managing probes at runtime, disabled if omitted
+
+	Cooldown *struct {
+		Duration time.Duration `yaml:"duration" validate:"required,gt=0"` // Minimum time between two connections to the same host from the same egress
+		MaxWait  time.Duration `yaml:"max_wait" validate:"gte=0"`         // How long a connection waits for a probe to leave its cooldown
+	} `yaml:"cooldown"` // Per-target cooldown of probes, disabled if omitted
+
+	RateLimits []RateLimitConfig `yaml:"rate_limits" validate:"dive"` // Per-target rate limits across all probes, the first matching rule applies
+	Routes     []RouteConfig     `yaml:"routes" validate:"dive"`      // Ordered routing rules of targets, the first matching rule applies
+
+	AccessPolicy *struct {
+		AllowedPorts  []string `yaml:"allowed_ports"`                     // Target ports or port ranges that may be used, defaults to 80 and 443
+		DeniedDomains []string `yaml:"denied_domains"`                    // Patterns of target hosts that may not be used
+		DeniedCidrs   []string `yaml:"denied_cidrs" validate:"dive,cidr"` // IP ranges of targets that may not be used
+	} `yaml:"access_policy"` // Targets that clients may connect to, only ports 80 and 443 are allowed if omitted
+
+	Headers *struct {
+		Via           string            `yaml:"via" validate:"omitempty,oneof=keep remove add"`             // What happens to the Via header
+		XForwardedFor string            `yaml:"x_forwarded_for" validate:"omitempty,oneof=keep remove add"` // What happens to the X-Forwarded-For header
+		Remove        []string          `yaml:"remove"`                                                     // Headers removed from every request
+		Set           map[string]string `yaml:"set"`                                                        // Headers set on every request
+	} `yaml:"headers"` // Rewriting of the headers of plaintext HTTP requests, only hop-by-hop and control headers are removed if omitted
+
+	UsernameHints *struct {
+		Separator  string `yaml:"separator" validate:"required"` // Separates the username, keys and values
+		Group      string `yaml:"group"`                         // Key of the probe group to use
+		Region     string `yaml:"region"`                        // Key of the region label of the probe to use
+		Labels     string `yaml:"labels"`                        // Key of a label selector the probe must match
+		Session    string `yaml:"session"`                       // Key of the sticky session id
+		SessionTtl string `yaml:"session_ttl"`                   // Key of the lifetime of a new sticky session, in seconds
+		Exclude    string `yaml:"exclude"`                       // Key of the name of a probe not to use
+	} `yaml:"username_hints"` // Grammar of the routing hints in proxy usernames, usernames carry no hints if omitted
+
+	Queue *struct {
+		MaxDepth int            `yaml:"max_depth" validate:"gte=0"`         // Maximum number of connections waiting for a probe, the default applies if omitted
+		Timeout  *time.Duration `yaml:"timeout" validate:"omitempty,gte=0"` // How long a connection waits for a probe, the default applies if omitted
+	} `yaml:"queue"` // Queue of connections waiting when every probe is at its connection limit, defaults apply if omitted
+
+	Sessions *struct {
+		TTL         time.Duration `yaml:"ttl" validate:"required,gt=0"`           // Lifetime of a sticky session
+		MaxSessions int           `yaml:"max_sessions" validate:"required,min=1"` // Maximum number of concurrent sticky sessions
+	} `yaml:"sessions"`
+
+	HealthCheck *struct {
+		Interval           time.Duration `yaml:"interval" validate:"gte=0"`             // Time between two health checks of a probe
+		Timeout            time.Duration `yaml:"timeout" validate:"gte=0"`              // Maximum duration of a single health check
+		FailureThreshold   int           `yaml:"failure_threshold" validate:"gte=0"`    // Consecutive failed health checks before ejection
+		DialErrorThreshold int           `yaml:"dial_error_threshold" validate:"gte=0"` // Consecutive failed dials before ejection
+		BaseEjectionTime   time.Duration `yaml:"base_ejection_time" validate:"gte=0"`   // Duration of the first ejection
+		MaxEjectionTime    time.Duration `yaml:"max_ejection_time" validate:"gte=0"`    // Upper bound of the ejection duration
+	} `yaml:"health_check"` // Active health checking and outlier ejection, disabled if omitted
+
 	Probes []ProbeConfig `yaml:"probes" validate:"required,min=1,dive"` // List of probe configurations
 }
 
+// defaultConfigFilename is the config file used unless CONFIG_FILE is set.
+const defaultConfigFilename = "config/hub.yaml"
+
 // main initializes and starts the rotox hub server.
 // It loads configuration, sets up logging, initializes probes and services,
-// and starts both the HTTP proxy server and optionally the telemetry server.
+// and starts the enabled proxy servers and optionally the telemetry server.
+// The configuration is reloaded on SIGHUP.
 func main() {
 	ctx := context.Background()
-	cfg, err := LoadConfig("config/hub.yaml")
+	cfg, err := LoadConfig(defaultConfigFilename)
 	if err != nil {
 		panic(fmt.Errorf("error loading config: %w", err))
 	}
 
-	logger, err := config.LoggerFromConfig(cfg.LogLevel, cfg.LogFormat)
+	level := new(slog.LevelVar)
+	lvl, err := config.ParseLevel(cfg.LogLevel)
 	if err != nil {
 		log.Fatalf("error creating logger: %v", err)
 	}
-	probes := setupProbes(cfg.Probes)
-	core := hub.NewCore(logger, probes)
+	level.Set(lvl)
+	logger, err := config.NewLogger(level, cfg.LogFormat)
+	if err != nil {
+		log.Fatalf("error creating logger: %v", err)
+	}
+	srv, err := newHubServer(ctx, logger, level, cfg)
+	if err != nil {
+		log.Fatalf("error setting up hub: %v", err)
+	}
+	core := srv.core
 	telemetrySrv := grpc_transport.NewTelemetryServer(logger)
 	core.RegisterTelemetryDispatcher(telemetrySrv)
-	httpApi := hub.NewHttpApi(logger, core)
 
 	logger.LogAttrs(
 		ctx,
@@ -115,41 +251,342 @@ func main() {
 		go grpcSrv.Serve(lis)
 	}
 
-	if cfg.Proxies.Http == nil {
-		log.Fatalf("no proxies are enabled")
+	if err := srv.applyProxies(cfg); err != nil {
+		log.Fatalf("error starting proxies: %v", err)
 	}
+	if cfg.Admin != nil {
+		adminApi := hub.NewAdminApi(logger, core, os.Getenv(cfg.Admin.SecretEnv))
+		adminApi.SetProbeManager(srv)
+		go func() {
+			srv.errs <- http.ListenAndServe(fmt.Sprintf(":%d", cfg.Admin.Port), adminApi)
+		}()
+	}
+	go srv.reloadOnSignal()
+	log.Fatalf("error when listening: %v", <-srv.errs)
+}
 
-	err = http.ListenAndServe(fmt.Sprintf(":%d", cfg.Proxies.Http.Port), httpApi)
+// newHubServer sets up the probes and the core of a hub configured by cfg,
+// logging to logger at level. The proxy listeners are not started yet.
+func newHubServer(ctx context.Context, logger *slog.Logger, level *slog.LevelVar, cfg *Config) (*hubServer, error) {
+	settings, err := coreSettings(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error configuring core: %w", err)
+	}
+	clients, probes, err := setupProbes(cfg.Probes, cfg.IpEchoUrl, nil)
 	if err != nil {
-		log.Fatalf("error when listening: %v", err)
+		return nil, fmt.Errorf("error setting up probes: %w", err)
+	}
+	core := hub.NewCore(logger, probes)
+	// The settings are valid.
+	core.Configure(settings)
+	if hc := cfg.HealthCheck; hc != nil {
+		core.StartHealthChecks(ctx, hub.HealthConfig{
+			Interval:           hc.Interval,
+			Timeout:            hc.Timeout,
+			FailureThreshold:   hc.FailureThreshold,
+			DialErrorThreshold: hc.DialErrorThreshold,
+			BaseEjectionTime:   hc.BaseEjectionTime,
+			MaxEjectionTime:    hc.MaxEjectionTime,
+		})
+	}
+	if cfg.Sessions != nil {
+		core.SetSessionLimits(cfg.Sessions.TTL, cfg.Sessions.MaxSessions)
 	}
+	return &hubServer{
+		ctx:    ctx,
+		logger: logger,
+		level:  level,
+		core:   core,
+		errs:   make(chan error),
+		cfg:    cfg,
+
+		ipEchoUrl: cfg.IpEchoUrl,
+		clients:   clients,
+	}, nil
+}
+
+// coreSettings returns the settings of the core, which are applied again on
+// reload. They are validated, so that Core.Configure does not fail.
+func coreSettings(cfg *Config) (hub.Settings, error) {
+	selector, err := hub.NewSelector(cfg.Selector)
+	if err != nil {
+		return hub.Settings{}, fmt.Errorf("error creating selector: %w", err)
+	}
+	settings := hub.Settings{
+		Selector:        selector,
+		MaxDialAttempts: cfg.MaxDialAttempts,
+		Cooldown:        cooldownConfig(cfg),
+		Queue:           queueConfig(cfg),
+		UsernameGrammar: usernameGrammar(cfg),
+		RateLimits:      rateLimitRules(cfg),
+		Routes:          routeRules(cfg),
+		AccessPolicy:    accessPolicy(cfg),
+		HeaderPolicy:    headerPolicy(cfg),
+	}
+	if err := settings.Validate(); err != nil {
+		return hub.Settings{}, err
+	}
+	return settings, nil
+}
+
+// cooldownConfig returns the per-target cooldown configuration of the core.
+func cooldownConfig(cfg *Config) hub.CooldownConfig {
+	if cfg.Cooldown == nil {
+		return hub.CooldownConfig{}
+	}
+	return hub.CooldownConfig{
+		Duration: cfg.Cooldown.Duration,
+		MaxWait:  cfg.Cooldown.MaxWait,
+	}
+}
+
+// usernameGrammar returns the grammar of the routing hints in proxy usernames.
+func usernameGrammar(cfg *Config) hub.UsernameGrammar {
+	if cfg.UsernameHints == nil {
+		return hub.UsernameGrammar{}
+	}
+	return hub.UsernameGrammar{
+		Separator:  cfg.UsernameHints.Separator,
+		Group:      cfg.UsernameHints.Group,
+		Region:     cfg.UsernameHints.Region,
+		Labels:     cfg.UsernameHints.Labels,
+		Session:    cfg.UsernameHints.Session,
+		SessionTtl: cfg.UsernameHints.SessionTtl,
+		Exclude:    cfg.UsernameHints.Exclude,
+	}
+}
+
+// queueConfig returns the configuration of the queue of connections waiting
+// for a probe.
+func queueConfig(cfg *Config) hub.QueueConfig {
+	queue := hub.QueueConfig{Timeout: hub.DefaultQueueTimeout}
+	if cfg.Queue == nil {
+		return queue
+	}
+	queue.MaxDepth = cfg.Queue.MaxDepth
+	if cfg.Queue.Timeout != nil {
+		queue.Timeout = *cfg.Queue.Timeout
+	}
+	return queue
+}
+
+// accessPolicy returns the access policy of the core.
+func accessPolicy(cfg *Config) hub.AccessPolicy {
+	policy := hub.AccessPolicy{AllowedPorts: hub.DefaultAllowedPorts}
+	if cfg.AccessPolicy == nil {
+		return policy
+	}
+	if cfg.AccessPolicy.AllowedPorts != nil {
+		policy.AllowedPorts = cfg.AccessPolicy.AllowedPorts
+	}
+	policy.DeniedDomains = cfg.AccessPolicy.DeniedDomains
+	policy.DeniedCidrs = cfg.AccessPolicy.DeniedCidrs
+	return policy
+}
+
+// headerPolicy returns the policy rewriting the headers of plaintext HTTP
+// requests.
+func headerPolicy(cfg *Config) hub.HeaderPolicy {
+	if cfg.Headers == nil {
+		return hub.HeaderPolicy{}
+	}
+	return hub.HeaderPolicy{
+		Via:           hub.HeaderMode(cfg.Headers.Via),
+		XForwardedFor: hub.HeaderMode(cfg.Headers.XForwardedFor),
+		Remove:        cfg.Headers.Remove,
+		Set:           cfg.Headers.Set,
+	}
+}
+
+// rateLimitRules returns the per-target rate limits of the core.
+func rateLimitRules(cfg *Config) []hub.RateLimitRule {
+	rules := make([]hub.RateLimitRule, len(cfg.RateLimits))
+	for i, rl := range cfg.RateLimits {
+		rules[i] = hub.RateLimitRule{
+			Pattern:           rl.Pattern,
+			Key:               rl.Key,
+			RequestsPerSecond: rl.RequestsPerSecond,
+			Burst:             rl.Burst,
+			MaxConnections:    rl.MaxConnections,
+			MaxWait:           rl.MaxWait,
+		}
+	}
+	return rules
+}
+
+// routeRules returns the routing rules of the core.
+func routeRules(cfg *Config) []hub.RouteRule {
+	rules := make([]hub.RouteRule, len(cfg.Routes))
+	for i, route := range cfg.Routes {
+		rules[i] = hub.RouteRule{
+			Name:      route.Name,
+			Hosts:     route.Hosts,
+			HostRegex: route.HostRegex,
+			Ports:     route.Ports,
+			Cidrs:     route.Cidrs,
+			Action:    hub.RouteAction(route.Action),
+			Group:     route.Group,
+
+			SetHeaders: route.SetHeaders,
+		}
+	}
+	return rules
+}
+
+// setupAuthenticator creates the authenticator for a proxy listener.
+// It returns nil if neither a secret nor a users file is configured.
+func setupAuthenticator(cfg *ProxyConfig) (hub.Authenticator, error) {
+	var auths []hub.Authenticator
+	if cfg.SecretEnv != nil {
+		auths = append(auths, hub.NewSecretAuthenticator(os.Getenv(*cfg.SecretEnv)))
+	}
+	if cfg.UsersFile != nil {
+		auth, err := hub.NewUsersAuthenticator(*cfg.UsersFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading users file: %w", err)
+		}
+		auths = append(auths, auth)
+	}
+	switch len(auths) {
+	case 0:
+		return nil, nil
+	case 1:
+		return auths[0], nil
+	default:
+		return hub.NewMultiAuthenticator(auths...), nil
+	}
+}
+
+// probeClient is a gRPC client of a probe host, with its own connection.
+type probeClient struct {
+	secret     string           // Secret used to authenticate to the probe
+	requireTls bool             // Whether TLS is required for the connection
+	conn       *grpc.ClientConn // Underlying gRPC connection
+	dialer     common.Dialer    // Dialer establishing connections through the probe
 }
 
 // setupProbes creates dialer instances for all configured probes.
 // It iterates through all probe configurations and creates a separate
-// dialer for each host in each probe group.
-func setupProbes(cfg []ProbeConfig) []common.Dialer {
-	probes := []common.Dialer{}
+// dialer for each host in each probe group, or several if the group asks for
+// more than one connection per host. Clients in existing whose name, secret
+// and TLS setting are unchanged are reused. New clients ask the probes to
+// discover their egress IP using ipEchoUrl, if set.
+// The clients are returned by probe name together with the probe specs.
+func setupProbes(
+	cfg []ProbeConfig,
+	ipEchoUrl string,
+	existing map[string]*probeClient,
+) (map[string]*probeClient, []hub.ProbeSpec, error) {
+	clients := map[string]*probeClient{}
+	probes := []hub.ProbeSpec{}
 	for _, probe := range cfg {
-		for _, host := range probe.Hosts {
-			probes = append(
-				probes,
-				grpc_transport.NewForwardClient(
-					setupProbeClient(
-						host,
-						os.Getenv(*probe.SecretEnv),
-						*probe.RequireTls,
-					),
-				),
+		if err := hub.ValidateLabels(probe.Labels); err != nil {
+			closeProbeClients(clients, existing)
+			return nil, nil, err
+		}
+		if probe.MaxConcurrentConnections > 0 && probe.MaxConcurrentConnections < probe.Connections {
+			closeProbeClients(clients, existing)
+			return nil, nil, fmt.Errorf(
+				"max_concurrent_connections %d must not be less than the %d connections per host",
+				probe.MaxConcurrentConnections,
+				probe.Connections,
 			)
 		}
+		secret := ""
+		if probe.SecretEnv != nil {
+			secret = os.Getenv(*probe.SecretEnv)
+		}
+		for _, host := range probe.Hosts {
+			for i := range max(probe.Connections, 1) {
+				name := probeName(host, i, probe.Connections)
+				if _, ok := clients[name]; ok {
+					continue
+				}
+				client := existing[name]
+				if client == nil || client.secret != secret || client.requireTls != *probe.RequireTls {
+					var err error
+					client, err = newProbeClient(host, secret, *probe.RequireTls, ipEchoUrl)
+					if err != nil {
+						closeProbeClients(clients, existing)
+						return nil, nil, err
+					}
+				}
+				clients[name] = client
+				spec := client.spec(name)
+				spec.Weight = probe.Weight
+				spec.MaxConnections = connectionLimit(probe.MaxConcurrentConnections, i, probe.Connections)
+				spec.Group = probe.Group
+				spec.Labels = probe.Labels
+				probes = append(probes, spec)
+			}
+		}
+	}
+	return clients, probes, nil
+}
+
+// probeName returns the name of the i-th (zero-based) of the connections to a
+// probe host. With a single connection, the probe is named by its host.
+func probeName(host string, i int, connections int) string {
+	if connections <= 1 {
+		return host
+	}
+	return fmt.Sprintf("%s#%d", host, i+1)
+}
+
+// connectionLimit returns the connection limit of the i-th (zero-based) of the
+// connections to a probe host. The limit of the host is shared evenly by its
+// connections, so that they never exceed it together. Zero is unlimited.
+func connectionLimit(limit int, i int, connections int) int {
+	connections = max(connections, 1)
+	share := limit / connections
+	if i < limit%connections {
+		share++
+	}
+	return share
+}
+
+// newProbeClient creates a client of a probe host with a new connection.
+func newProbeClient(host string, secret string, requireTls bool, ipEchoUrl string) (*probeClient, error) {
+	conn, err := setupProbeClient(host, secret, requireTls)
+	if err != nil {
+		return nil, err
+	}
+	dialer := grpc_transport.NewForwardClient(
+		forward_pb.NewForwardServiceClient(conn),
+		grpc_transport.WithIpEchoUrl(ipEchoUrl),
+	)
+	return &probeClient{
+		secret:     secret,
+		requireTls: requireTls,
+		conn:       conn,
+		dialer:     dialer,
+	}, nil
+}
+
+// spec returns the specification of the probe using the client,
+// leaving the settings of its group to the caller.
+func (client *probeClient) spec(name string) hub.ProbeSpec {
+	return hub.ProbeSpec{
+		Name:   name,
+		Dialer: client.dialer,
+		Connectivity: func() string {
+			return client.conn.GetState().String()
+		},
+	}
+}
+
+// closeProbeClients closes the clients that are not part of keep.
+func closeProbeClients(clients map[string]*probeClient, keep map[string]*probeClient) {
+	for name, client := range clients {
+		if keep[name] != client {
+			client.conn.Close()
+		}
 	}
-	return probes
 }
 
 // setupProbeClient creates a gRPC client for connecting to a probe.
 // It handles hostname normalization, TLS configuration, and authentication.
-func setupProbeClient(hostname string, secret string, requireTls bool) forward_pb.ForwardServiceClient {
+func setupProbeClient(hostname string, secret string, requireTls bool) (*grpc.ClientConn, error) {
 	hostname = strings.Replace(hostname, "http://", "dns:///", 1)
 	hostname = strings.Replace(hostname, "https://", "dns:///", 1)
 
@@ -160,13 +597,17 @@ func setupProbeClient(hostname string, secret string, requireTls bool) forward_p
 		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
 	}
 	if secret != "" {
-		opts = append(opts, grpc.WithStreamInterceptor(grpc_transport.NewClientInterceptor(secret)))
+		opts = append(
+			opts,
+			grpc.WithStreamInterceptor(grpc_transport.NewClientInterceptor(secret)),
+			grpc.WithUnaryInterceptor(grpc_transport.NewUnaryClientInterceptor(secret)),
+		)
 	}
 	client, err := grpc.NewClient(hostname, opts...)
 	if err != nil {
-		log.Fatalf("error connecting to relay: %v", err)
+		return nil, fmt.Errorf("error connecting to probe %s: %w", hostname, err)
 	}
-	return forward_pb.NewForwardServiceClient(client)
+	return client, nil
 }
 
 // LogValue implements slog.LogValuer for structured logging of configuration.
@@ -181,6 +622,8 @@ func (cfg Config) LogValue() slog.Value {
 	return slog.GroupValue(
 		slog.String("log_level", cfg.LogLevel),
 		slog.String("log_format", cfg.LogFormat),
+		slog.String("selector", cfg.Selector),
+		slog.String("ip_echo_url", cfg.IpEchoUrl),
 		slog.Any("probe_hosts", probeHostsHead),
 	)
 }
@@ -193,6 +636,7 @@ func LoadConfig(defaultConfigFilename string) (*Config, error) {
 	cfg := &Config{
 		LogLevel:  "debug",
 		LogFormat: "json",
+		Selector:  hub.StrategyRoundRobin,
 	}
 
 	configFilename := defaultConfigFilename
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/isacskoglund/rotox/internal/hub"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/connectivity"
+)
+
+func TestProbeName(t *testing.T) {
+	assert.Equal(t, "probe-a:8000", probeName("probe-a:8000", 0, 0))
+	assert.Equal(t, "probe-a:8000", probeName("probe-a:8000", 0, 1))
+	assert.Equal(t, "probe-a:8000#1", probeName("probe-a:8000", 0, 3))
+	assert.Equal(t, "probe-a:8000#3", probeName("probe-a:8000", 2, 3))
+}
+
+func TestConnectionLimit(t *testing.T) {
+	limits := func(limit int, connections int) []int {
+		var limits []int
+		for i := range max(connections, 1) {
+			limits = append(limits, connectionLimit(limit, i, connections))
+		}
+		return limits
+	}
+	assert.Equal(t, []int{80}, limits(80, 0))
+	assert.Equal(t, []int{20, 20, 20, 20}, limits(80, 4))
+	assert.Equal(t, []int{27, 27, 26}, limits(80, 3))
+	assert.Equal(t, []int{0, 0}, limits(0, 2))
+}
+
+func TestSetupProbes_ConnectionLimit(t *testing.T) {
+	requireTls := false
+	cfg := []ProbeConfig{{
+		RequireTls:               &requireTls,
+		Hosts:                    []string{"probe-a:8000"},
+		MaxConcurrentConnections: 80,
+		Connections:              3,
+	}}
+	clients, probes, err := setupProbes(cfg, "", nil)
+	assert.NoError(t, err)
+	defer closeProbeClients(clients, nil)
+	total := 0
+	for _, probe := range probes {
+		total += probe.MaxConnections
+	}
+	// The connections of a host never exceed its limit together.
+	assert.Equal(t, 80, total)
+
+	cfg[0].MaxConcurrentConnections = 2
+	_, _, err = setupProbes(cfg, "", clients)
+	assert.Error(t, err)
+}
+
+func TestSetupProbes_Connections(t *testing.T) {
+	requireTls := false
+	setup := func(connections int, existing map[string]*probeClient) map[string]*probeClient {
+		cfg := []ProbeConfig{{RequireTls: &requireTls, Hosts: []string{"probe-a:8000"}, Connections: connections}}
+		clients, probes, err := setupProbes(cfg, "", existing)
+		assert.NoError(t, err)
+		assert.Len(t, probes, len(clients))
+		for _, probe := range probes {
+			assert.Same(t, clients[probe.Name].dialer, probe.Dialer)
+		}
+		// The clients left behind are closed like on a reload.
+		closeProbeClients(existing, clients)
+		return clients
+	}
+	names := func(clients map[string]*probeClient) []string {
+		var names []string
+		for name := range clients {
+			names = append(names, name)
+		}
+		return names
+	}
+	closed := func(client *probeClient) bool {
+		return client.conn.GetState() == connectivity.Shutdown
+	}
+
+	single := setup(1, nil)
+	assert.ElementsMatch(t, []string{"probe-a:8000"}, names(single))
+
+	// 1 to n: every connection is a new probe named after its position.
+	two := setup(2, single)
+	assert.ElementsMatch(t, []string{"probe-a:8000#1", "probe-a:8000#2"}, names(two))
+	assert.True(t, closed(single["probe-a:8000"]))
+
+	// Connections that are kept keep their clients.
+	three := setup(3, two)
+	assert.ElementsMatch(t, []string{"probe-a:8000#1", "probe-a:8000#2", "probe-a:8000#3"}, names(three))
+	assert.Same(t, two["probe-a:8000#1"], three["probe-a:8000#1"])
+	assert.Same(t, two["probe-a:8000#2"], three["probe-a:8000#2"])
+
+	// Connections that are dropped are closed.
+	two = setup(2, three)
+	assert.Same(t, three["probe-a:8000#2"], two["probe-a:8000#2"])
+	assert.True(t, closed(three["probe-a:8000#3"]))
+	assert.False(t, closed(two["probe-a:8000#1"]))
+
+	// n to 1: the host is a single probe again, with a new client.
+	single = setup(1, two)
+	assert.ElementsMatch(t, []string{"probe-a:8000"}, names(single))
+	assert.True(t, closed(two["probe-a:8000#1"]))
+	assert.True(t, closed(two["probe-a:8000#2"]))
+	assert.False(t, closed(single["probe-a:8000"]))
+	closeProbeClients(single, nil)
+}
+
+func TestQueueConfig(t *testing.T) {
+	load := func(config string) (*Config, error) {
+		writeConfig(t, config+`
+probes:
+    - require_tls: false
+      hosts: [probe-a:8000]
+`)
+		return LoadConfig(defaultConfigFilename)
+	}
+
+	for _, test := range []struct {
+		config string
+		queue  hub.QueueConfig
+	}{
+		{"", hub.QueueConfig{Timeout: hub.DefaultQueueTimeout}},
+		{"queue:\n    timeout: 3s", hub.QueueConfig{Timeout: 3 * time.Second}},
+		{"queue:\n    timeout: 0s", hub.QueueConfig{}},
+		{"queue:\n    max_depth: 5", hub.QueueConfig{MaxDepth: 5, Timeout: hub.DefaultQueueTimeout}},
+	} {
+		cfg, err := load(test.config)
+		if assert.NoError(t, err, test.config) {
+			assert.Equal(t, test.queue, queueConfig(cfg), test.config)
+		}
+	}
+
+	_, err := load("queue:\n    timeout: -1s")
+	assert.Error(t, err)
+	_, err = load("queue:\n    max_depth: -1")
+	assert.Error(t, err)
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/isacskoglund/rotox/internal/config"
+	"github.com/isacskoglund/rotox/internal/hub"
+)
+
+// hubServer holds the parts of a running hub that can be reconfigured
+// without a restart, through reloads or the admin API.
+type hubServer struct {
+	ctx    context.Context
+	logger *slog.Logger
+	level  *slog.LevelVar // Log level of logger, changed on reload
+	core   *hub.Core
+	errs   chan error // Errors of the running listeners
+
+	ipEchoUrl string // Echo URL for egress IP discovery, changes require a restart
+
+	mu          sync.Mutex              // Serializes reloads and changes through the admin API
+	cfg         *Config                 // Currently applied configuration
+	clients     map[string]*probeClient // Probe clients by probe name
+	http        *proxyListener          // HTTP proxy listener, nil if disabled
+	https       *proxyListener          // HTTPS proxy listener, nil if disabled
+	tls         *hub.TlsReloader        // TLS configuration of the HTTPS proxy listener, nil if disabled
+	socks5      *proxyListener          // SOCKS5 proxy listener, nil if disabled
+	transparent *proxyListener          // Transparent proxy listener, nil if disabled
+}
+
+// proxyListener is a running proxy listener.
+type proxyListener struct {
+	cfg    ProxyConfig
+	extra  any // Settings of the listener beyond cfg, changes restart it too
+	lis    net.Listener
+	serve  func(lis net.Listener, auth hub.Authenticator) error
+	auth   *swappableAuthenticator // Nil if authentication is disabled
+	closed atomic.Bool             // Whether the listener was closed on purpose
+}
+
+// close stops accepting new connections.
+// Connections that are already accepted are not affected.
+func (pl *proxyListener) close() {
+	pl.closed.Store(true)
+	pl.lis.Close()
+}
+
+// swappableAuthenticator is an authenticator that can be replaced while the
+// listener using it is running, e.g. to pick up changes to a users file.
+type swappableAuthenticator struct {
+	current atomic.Pointer[hub.Authenticator]
+}
+
+func newSwappableAuthenticator(auth hub.Authenticator) *swappableAuthenticator {
+	s := &swappableAuthenticator{}
+	s.current.Store(&auth)
+	return s
+}
+
+func (s *swappableAuthenticator) Authenticate(username, password string) bool {
+	return (*s.current.Load()).Authenticate(username, password)
+}
+
+// reloadOnSignal reloads the configuration every time the process receives SIGHUP.
+func (srv *hubServer) reloadOnSignal() {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	for range sighup {
+		if err := srv.reload(); err != nil {
+			srv.logger.LogAttrs(
+				srv.ctx,
+				slog.LevelError,
+				"Failed to reload configuration",
+				slog.Any("error", err),
+			)
+			continue
+		}
+		srv.logger.LogAttrs(
+			srv.ctx,
+			slog.LevelInfo,
+			"Configuration reloaded",
+			slog.Any("config", srv.cfg),
+		)
+	}
+}
+
+// reload loads the configuration file again and applies the log level,
+// selector, dial attempts, cooldown, rate limits, access policy, routes,
+// header policy, queue, username hints, probes and proxy listeners, including
+// their users and TLS files. Changes to other settings are logged and take
+// effect after a restart. Everything that can fail is prepared before
+// anything is applied, so an invalid configuration changes nothing.
+func (srv *hubServer) reload() error {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	cfg, err := LoadConfig(defaultConfigFilename)
+	if err != nil {
+		return fmt.Errorf("error loading config: %w", err)
+	}
+	lvl, err := config.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		return err
+	}
+	settings, err := coreSettings(cfg)
+	if err != nil {
+		return err
+	}
+	clients, probes, err := setupProbes(cfg.Probes, srv.ipEchoUrl, srv.clients)
+	if err != nil {
+		return err
+	}
+	proxies, err := srv.prepareProxies(cfg)
+	if err != nil {
+		closeProbeClients(clients, srv.clients)
+		return err
+	}
+
+	srv.level.Set(lvl)
+	// The settings are valid, and there is at least one probe.
+	srv.core.Configure(settings)
+	srv.replaceProbes(clients, probes)
+	srv.commitProxies(proxies)
+
+	for field, changed := range map[string]bool{
+		"log_format":   cfg.LogFormat != srv.cfg.LogFormat,
+		"telemetry":    !reflect.DeepEqual(cfg.Telemetry, srv.cfg.Telemetry),
+		"sessions":     !reflect.DeepEqual(cfg.Sessions, srv.cfg.Sessions),
+		"health_check": !reflect.DeepEqual(cfg.HealthCheck, srv.cfg.HealthCheck),
+		"admin":        !reflect.DeepEqual(cfg.Admin, srv.cfg.Admin),
+		"ip_echo_url":  cfg.IpEchoUrl != srv.ipEchoUrl,
+	} {
+		if changed {
+			srv.logger.LogAttrs(
+				srv.ctx,
+				slog.LevelWarn,
+				"Configuration change requires a restart to take effect",
+				slog.String("field", field),
+			)
+		}
+	}
+	srv.cfg = cfg
+	return nil
+}
+
+// replaceProbes replaces the pool of probes with probes, created by
+// setupProbes together with clients. Clients of removed probes are closed once
+// the connections still using them have finished.
+func (srv *hubServer) replaceProbes(clients map[string]*probeClient, probes []hub.ProbeSpec) {
+	removed, err := srv.core.UpdateProbes(probes)
+	if err != nil {
+		// Only an empty pool is refused, which the config does not allow.
+		panic(err)
+	}
+	stale := map[string]*probeClient{}
+	for name, client := range srv.clients {
+		if clients[name] != client {
+			stale[name] = client
+		}
+	}
+	srv.clients = clients
+
+	for _, probe := range removed {
+		srv.logger.LogAttrs(
+			srv.ctx,
+			slog.LevelInfo,
+			"Probe removed from pool, waiting for its connections to finish",
+			slog.String("probe", probe.Name()),
+			slog.Int64("active_connections", probe.ActiveConnections()),
+		)
+	}
+	go srv.releaseProbeClients(removed, stale)
+}
+
+// releaseProbeClients closes clients once the removed probes are idle.
+func (srv *hubServer) releaseProbeClients(removed []*hub.Probe, clients map[string]*probeClient) {
+	for _, probe := range removed {
+		probe.WaitIdle(srv.ctx)
+	}
+	closeProbeClients(clients, nil)
+}
+
+// proxiesUpdate is a prepared change of every proxy listener, which is either
+// committed or rolled back.
+type proxiesUpdate struct {
+	http        *proxyUpdate
+	https       *proxyUpdate
+	socks5      *proxyUpdate
+	transparent *proxyUpdate
+
+	tls      *hub.TlsReloader // TLS configuration of the HTTPS proxy listener, nil if disabled
+	tlsFiles hub.TlsFiles     // Files loaded by tls on commit
+}
+
+// proxySlot is the prepared update of a proxy listener, along with the field
+// of hubServer holding the listener.
+type proxySlot struct {
+	listener **proxyListener
+	update   *proxyUpdate
+}
+
+func (srv *hubServer) proxySlots(update *proxiesUpdate) []proxySlot {
+	return []proxySlot{
+		{&srv.http, update.http},
+		{&srv.https, update.https},
+		{&srv.socks5, update.socks5},
+		{&srv.transparent, update.transparent},
+	}
+}
+
+// applyProxies starts, restarts or stops the proxy listeners to match cfg.
+func (srv *hubServer) applyProxies(cfg *Config) error {
+	update, err := srv.prepareProxies(cfg)
+	if err != nil {
+		return err
+	}
+	srv.commitProxies(update)
+	return nil
+}
+
+// prepareProxies prepares the changes of the proxy listeners to match cfg.
+// New listeners already listen, but do not accept connections before the
+// update is committed. If preparing any of them fails, the others are rolled
+// back.
+func (srv *hubServer) prepareProxies(cfg *Config) (*proxiesUpdate, error) {
+	if cfg.Proxies.Http == nil && cfg.Proxies.Https == nil && cfg.Proxies.Socks5 == nil && cfg.Proxies.Transparent == nil {
+		return nil, errors.New("no proxies are enabled")
+	}
+	httpLabels, err := proxyLabelSelector(cfg.Proxies.Http)
+	if err != nil {
+		return nil, fmt.Errorf("error applying http proxy: %w", err)
+	}
+	socks5Labels, err := proxyLabelSelector(cfg.Proxies.Socks5)
+	if err != nil {
+		return nil, fmt.Errorf("error applying socks5 proxy: %w", err)
+	}
+
+	update := &proxiesUpdate{}
+	update.http, err = srv.prepareProxy(srv.http, cfg.Proxies.Http, nil, func(lis net.Listener, auth hub.Authenticator) error {
+		httpApi := hub.NewHttpApi(srv.logger, srv.core)
+		if auth != nil {
+			httpApi.SetAuthenticator(auth)
+		}
+		httpApi.SetLabelSelector(httpLabels)
+		return httpApi.Serve(lis)
+	})
+	if err != nil {
+		return nil, srv.rollbackProxies(update, fmt.Errorf("error applying http proxy: %w", err))
+	}
+	if err := srv.prepareHttpsProxy(update, cfg.Proxies.Https); err != nil {
+		return nil, srv.rollbackProxies(update, fmt.Errorf("error applying https proxy: %w", err))
+	}
+	update.socks5, err = srv.prepareProxy(srv.socks5, cfg.Proxies.Socks5, nil, func(lis net.Listener, auth hub.Authenticator) error {
+		socks5Api := hub.NewSocks5Api(srv.logger, srv.core)
+		if auth != nil {
+			socks5Api.SetAuthenticator(auth)
+		}
+		socks5Api.SetLabelSelector(socks5Labels)
+		return socks5Api.Serve(lis)
+	})
+	if err != nil {
+		return nil, srv.rollbackProxies(update, fmt.Errorf("error applying socks5 proxy: %w", err))
+	}
+	update.transparent, err = srv.prepareTransparentProxy(cfg.Proxies.Transparent)
+	if err != nil {
+		return nil, srv.rollbackProxies(update, fmt.Errorf("error applying transparent proxy: %w", err))
+	}
+	return update, nil
+}
+
+// commitProxies starts the listeners prepared by update, and stops those
+// they replace.
+func (srv *hubServer) commitProxies(update *proxiesUpdate) {
+	if update.tls != nil && update.tls == srv.tls {
+		// The files were loaded when the update was prepared.
+		if err := srv.tls.SetFiles(update.tlsFiles); err != nil {
+			srv.logger.LogAttrs(
+				srv.ctx,
+				slog.LevelWarn,
+				"Failed to reload TLS files, keeping the previous ones",
+				slog.String("cert_file", update.tlsFiles.CertFile),
+				slog.Any("error", err),
+			)
+		}
+	}
+	srv.tls = update.tls
+	for _, slot := range srv.proxySlots(update) {
+		*slot.listener = srv.commitProxy(slot.update)
+	}
+}
+
+// rollbackProxies discards the listeners prepared by update, and re-opens
+// those that had to release their port. It returns err, joined with the
+// errors of re-opening listeners.
+func (srv *hubServer) rollbackProxies(update *proxiesUpdate, err error) error {
+	errs := []error{err}
+	for _, slot := range srv.proxySlots(update) {
+		if slot.update == nil {
+			continue
+		}
+		running, err := srv.rollbackProxy(slot.update)
+		*slot.listener = running
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// prepareHttpsProxy prepares the change of the HTTPS proxy listener to match
+// cfg. The TLS files are loaded again, also when the listener is unchanged.
+func (srv *hubServer) prepareHttpsProxy(update *proxiesUpdate, cfg *HttpsProxyConfig) error {
+	if cfg == nil {
+		update.https = &proxyUpdate{current: srv.https}
+		return nil
+	}
+	labels, err := proxyLabelSelector(&cfg.ProxyConfig)
+	if err != nil {
+		return err
+	}
+	files := hub.TlsFiles{
+		CertFile:     cfg.CertFile,
+		KeyFile:      cfg.KeyFile,
+		ClientCaFile: cfg.ClientCaFile,
+	}
+	// Loading the files checks them, a running reloader loads them again on
+	// commit.
+	reloader, err := hub.NewTlsReloader(srv.logger, files)
+	if err != nil {
+		return err
+	}
+	if srv.tls != nil {
+		reloader = srv.tls
+	}
+	update.https, err = srv.prepareProxy(srv.https, &cfg.ProxyConfig, nil, func(lis net.Listener, auth hub.Authenticator) error {
+		httpApi := hub.NewHttpApi(srv.logger, srv.core)
+		if auth != nil {
+			httpApi.SetAuthenticator(auth)
+		}
+		httpApi.SetLabelSelector(labels)
+		return httpApi.Serve(tls.NewListener(lis, reloader.Config()))
+	})
+	if err != nil {
+		return err
+	}
+	update.tls = reloader
+	update.tlsFiles = files
+	return nil
+}
+
+// prepareTransparentProxy prepares the change of the transparent proxy
+// listener to match cfg.
+func (srv *hubServer) prepareTransparentProxy(cfg *TransparentProxyConfig) (*proxyUpdate, error) {
+	if cfg == nil {
+		return &proxyUpdate{current: srv.transparent}, nil
+	}
+	proxyCfg := &ProxyConfig{Port: cfg.Port, LabelSelector: cfg.LabelSelector}
+	labels, err := proxyLabelSelector(proxyCfg)
+	if err != nil {
+		return nil, err
+	}
+	return srv.prepareProxy(srv.transparent, proxyCfg, cfg.SniffHostnames, func(lis net.Listener, _ hub.Authenticator) error {
+		transparentApi := hub.NewTransparentApi(srv.logger, srv.core)
+		transparentApi.SetLabelSelector(labels)
+		transparentApi.SetSniffHostnames(cfg.SniffHostnames)
+		return transparentApi.Serve(lis)
+	})
+}
+
+// proxyLabelSelector parses the default label selector of a proxy listener.
+func proxyLabelSelector(cfg *ProxyConfig) (hub.LabelSelector, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	selector, err := hub.ParseLabelSelector(cfg.LabelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid label_selector: %w", err)
+	}
+	return selector, nil
+}
+
+// proxyUpdate is a prepared change of a proxy listener, which is either
+// committed or rolled back.
+type proxyUpdate struct {
+	current *proxyListener    // Running listener, nil if there is none
+	next    *proxyListener    // Listener running after the commit, nil if there is none
+	auth    hub.Authenticator // Reloaded authenticator of current, if it is kept
+}
+
+// prepareProxy prepares the change of a running proxy listener to match its
+// configuration. An unchanged listener keeps running with its users file
+// reloaded. A changed listener is replaced on commit, letting connections
+// accepted by the old one finish. Changes to extra, the listener-specific
+// settings beyond cfg, replace the listener too. The new listener listens
+// right away; if it needs the port of the old one, the old one is closed
+// until the update is committed or rolled back.
+func (srv *hubServer) prepareProxy(
+	current *proxyListener,
+	cfg *ProxyConfig,
+	extra any,
+	serve func(lis net.Listener, auth hub.Authenticator) error,
+) (*proxyUpdate, error) {
+	update := &proxyUpdate{current: current}
+	if cfg == nil {
+		return update, nil
+	}
+	auth, err := setupAuthenticator(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil && reflect.DeepEqual(current.cfg, *cfg) && reflect.DeepEqual(current.extra, extra) {
+		update.next = current
+		update.auth = auth
+		return update, nil
+	}
+
+	if current != nil && current.cfg.Port == cfg.Port {
+		// The port must be released before it can be listened to again.
+		current.close()
+	}
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.Port))
+	if err != nil {
+		running, reopenErr := srv.rollbackProxy(update)
+		return &proxyUpdate{current: running, next: running}, errors.Join(err, reopenErr)
+	}
+	update.next = &proxyListener{cfg: *cfg, extra: extra, lis: lis, serve: serve}
+	if auth != nil {
+		update.next.auth = newSwappableAuthenticator(auth)
+	}
+	return update, nil
+}
+
+// commitProxy applies a prepared update and returns the listener running
+// afterwards.
+func (srv *hubServer) commitProxy(update *proxyUpdate) *proxyListener {
+	if update.next == update.current {
+		if update.current != nil && update.current.auth != nil {
+			update.current.auth.current.Store(&update.auth)
+		}
+		return update.current
+	}
+	if update.current != nil {
+		update.current.close()
+	}
+	if update.next != nil {
+		srv.startProxy(update.next)
+	}
+	return update.next
+}
+
+// rollbackProxy discards a prepared update and returns the listener running
+// afterwards. If the running listener was closed to release its port, it is
+// opened again.
+func (srv *hubServer) rollbackProxy(update *proxyUpdate) (*proxyListener, error) {
+	if update.next != nil && update.next != update.current {
+		update.next.lis.Close()
+	}
+	current := update.current
+	if current == nil || !current.closed.Load() {
+		return current, nil
+	}
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", current.cfg.Port))
+	if err != nil {
+		return nil, fmt.Errorf("error re-opening proxy listener on port %d: %w", current.cfg.Port, err)
+	}
+	reopened := &proxyListener{cfg: current.cfg, extra: current.extra, lis: lis, serve: current.serve, auth: current.auth}
+	srv.startProxy(reopened)
+	return reopened, nil
+}
+
+// startProxy accepts connections on a prepared listener until it is closed.
+func (srv *hubServer) startProxy(pl *proxyListener) {
+	var auth hub.Authenticator
+	if pl.auth != nil {
+		auth = pl.auth
+	}
+	go func() {
+		err := pl.serve(pl.lis, auth)
+		if !pl.closed.Load() {
+			srv.errs <- err
+		}
+	}()
+}
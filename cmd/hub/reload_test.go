@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// freePort returns a port that is free to listen on.
+func freePort(t *testing.T) int {
+	lis, err := net.Listen("tcp", ":0")
+	assert.NoError(t, err)
+	defer lis.Close()
+	return lis.Addr().(*net.TCPAddr).Port
+}
+
+// writeConfig writes a config file read by LoadConfig.
+func writeConfig(t *testing.T, config string) {
+	file := filepath.Join(t.TempDir(), "hub.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte(config), 0o600))
+	t.Setenv("CONFIG_FILE", file)
+}
+
+// startServer starts a hub server like main does, without telemetry and the
+// admin API.
+func startServer(t *testing.T, config string) *hubServer {
+	writeConfig(t, config)
+	cfg, err := LoadConfig(defaultConfigFilename)
+	assert.NoError(t, err)
+	srv, err := newHubServer(context.Background(), slog.New(slog.DiscardHandler), new(slog.LevelVar), cfg)
+	assert.NoError(t, err)
+	assert.NoError(t, srv.applyProxies(cfg))
+	t.Cleanup(func() {
+		for _, pl := range []*proxyListener{srv.http, srv.https, srv.socks5, srv.transparent} {
+			if pl != nil {
+				pl.close()
+			}
+		}
+		closeProbeClients(srv.clients, nil)
+	})
+	return srv
+}
+
+// assertListening asserts that a proxy listener accepts connections on port.
+func assertListening(t *testing.T, port int) {
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+	if assert.NoError(t, err, "port %d", port) {
+		conn.Close()
+	}
+}
+
+func TestReload_FailureChangesNothing(t *testing.T) {
+	httpPort := freePort(t)
+	srv := startServer(t, fmt.Sprintf(`
+proxies:
+    http:
+        port: %d
+probes:
+    - require_tls: false
+      hosts: [probe-a:8000]
+`, httpPort))
+	cfg := srv.cfg
+
+	// The socks5 listener cannot listen on a port that is in use.
+	blocker, err := net.Listen("tcp", ":0")
+	assert.NoError(t, err)
+	defer blocker.Close()
+	writeConfig(t, fmt.Sprintf(`
+selector: random
+proxies:
+    http:
+        port: %d
+        label_selector: region=eu
+    socks5:
+        port: %d
+probes:
+    - require_tls: false
+      hosts: [probe-a:8000, probe-b:8000]
+`, httpPort, blocker.Addr().(*net.TCPAddr).Port))
+	assert.Error(t, srv.reload())
+
+	assert.Same(t, cfg, srv.cfg)
+	assert.Len(t, srv.core.Probes(), 1)
+	assert.Len(t, srv.clients, 1)
+	assert.Nil(t, srv.socks5)
+	// The HTTP listener released its port for the change, and is re-opened
+	// with its previous configuration.
+	if assert.NotNil(t, srv.http) {
+		assert.Equal(t, "", srv.http.cfg.LabelSelector)
+	}
+	assertListening(t, httpPort)
+
+	// A valid configuration is applied as a whole.
+	socks5Port := freePort(t)
+	writeConfig(t, fmt.Sprintf(`
+proxies:
+    http:
+        port: %d
+        label_selector: region=eu
+    socks5:
+        port: %d
+probes:
+    - require_tls: false
+      hosts: [probe-a:8000, probe-b:8000]
+`, httpPort, socks5Port))
+	assert.NoError(t, srv.reload())
+	assert.Len(t, srv.core.Probes(), 2)
+	assert.Equal(t, "region=eu", srv.http.cfg.LabelSelector)
+	assertListening(t, httpPort)
+	assertListening(t, socks5Port)
+}
+
+func TestReload_InvalidSettingsChangeNothing(t *testing.T) {
+	srv := startServer(t, fmt.Sprintf(`
+proxies:
+    http:
+        port: %d
+probes:
+    - require_tls: false
+      hosts: [probe-a:8000]
+`, freePort(t)))
+	http := srv.http
+
+	writeConfig(t, fmt.Sprintf(`
+proxies:
+    http:
+        port: %d
+routes:
+    - name: broken
+      host_regex: "("
+      action: reject
+probes:
+    - require_tls: false
+      hosts: [probe-b:8000]
+`, freePort(t)))
+	assert.Error(t, srv.reload())
+	assert.Same(t, http, srv.http)
+	assert.Equal(t, "probe-a:8000", srv.core.Probes()[0].Name())
+}
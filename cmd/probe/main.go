@@ -39,6 +39,9 @@ type Config struct {
 	LogFormat string  `env:"LOG_FORMAT, default=json"` // Log output format (json or text)
 	Port      uint16  `env:"PORT, default=8000"`       // Port for the gRPC server to listen on
 	Secret    *string `env:"SECRET, required"`         // Authentication secret for hub connections
+	IpEchoUrl string  `env:"IP_ECHO_URL"`              // Echo service for egress IP discovery, overrides the hub's
+
+	AllowedCidrs []string `env:"ALLOWED_CIDRS"` // Address ranges that may be dialed even though they are blocked by default
 }
 
 // main initializes and starts the rotox probe server.
@@ -56,13 +59,19 @@ func main() {
 		log.Fatalf("error creating logger: %v", err)
 	}
 
+	guard, err := probe.NewDialGuard(cfg.AllowedCidrs)
+	if err != nil {
+		log.Fatalf("error creating dial guard: %v", err)
+	}
 	svc := probe.NewService(
 		logger,
 		&net.Dialer{
 			Timeout: dialTimeout,
+			Control: guard.Control,
 		},
 	)
 	srv := grpc_transport.NewForwardServer(logger, svc)
+	srv.SetEgressIpSource(probe.NewEgressIpDiscoverer(logger, cfg.IpEchoUrl, guard))
 
 	var opts []grpc.ServerOption
 	if cfg.Secret != nil {
@@ -73,6 +82,12 @@ func main() {
 					*cfg.Secret,
 				),
 			),
+			grpc.UnaryInterceptor(
+				grpc_transport.NewUnaryServerInterceptor(
+					logger,
+					*cfg.Secret,
+				),
+			),
 		)
 	}
 	s := grpc.NewServer(opts...)
@@ -113,5 +128,7 @@ func (cfg Config) LogValue() slog.Value {
 		slog.String("log_format", cfg.LogFormat),
 		slog.Int("port", int(cfg.Port)),
 		slog.Bool("authentication_enabled", cfg.Secret != nil),
+		slog.String("ip_echo_url", cfg.IpEchoUrl),
+		slog.Any("allowed_cidrs", cfg.AllowedCidrs),
 	)
 }
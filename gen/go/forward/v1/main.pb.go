@@ -24,9 +24,18 @@ const (
 type DialResponse_Code int32
 
 const (
+	// CODE_UNSPECIFIED indicates "OK".
+	// This particular suffix is by default required in buf's standard linting setting.
 	DialResponse_CODE_UNSPECIFIED            DialResponse_Code = 0
 	DialResponse_CODE_FAILED_TO_RESOLVE_HOST DialResponse_Code = 1
 	DialResponse_CODE_HOST_UNREACHABLE       DialResponse_Code = 2
+	// The target resolved to an address that the probe's policy blocks,
+	// e.g. a private or metadata address.
+	DialResponse_CODE_BLOCKED_BY_POLICY   DialResponse_Code = 3
+	DialResponse_CODE_CONNECTION_REFUSED  DialResponse_Code = 4
+	DialResponse_CODE_TIMEOUT             DialResponse_Code = 5
+	DialResponse_CODE_NETWORK_UNREACHABLE DialResponse_Code = 6
+	DialResponse_CODE_TLS_FAILURE         DialResponse_Code = 7
 )
 
 // Enum value maps for DialResponse_Code.
@@ -35,11 +44,21 @@ var (
 		0: "CODE_UNSPECIFIED",
 		1: "CODE_FAILED_TO_RESOLVE_HOST",
 		2: "CODE_HOST_UNREACHABLE",
+		3: "CODE_BLOCKED_BY_POLICY",
+		4: "CODE_CONNECTION_REFUSED",
+		5: "CODE_TIMEOUT",
+		6: "CODE_NETWORK_UNREACHABLE",
+		7: "CODE_TLS_FAILURE",
 	}
 	DialResponse_Code_value = map[string]int32{
 		"CODE_UNSPECIFIED":            0,
 		"CODE_FAILED_TO_RESOLVE_HOST": 1,
 		"CODE_HOST_UNREACHABLE":       2,
+		"CODE_BLOCKED_BY_POLICY":      3,
+		"CODE_CONNECTION_REFUSED":     4,
+		"CODE_TIMEOUT":                5,
+		"CODE_NETWORK_UNREACHABLE":    6,
+		"CODE_TLS_FAILURE":            7,
 	}
 )
 
@@ -153,8 +172,11 @@ func (*ForwardRequest_DialRequest) isForwardRequest_Request() {}
 func (*ForwardRequest_TransferRequest) isForwardRequest_Request() {}
 
 type DialRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Destination   string                 `protobuf:"bytes,1,opt,name=destination,proto3" json:"destination,omitempty"`
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Destination string                 `protobuf:"bytes,1,opt,name=destination,proto3" json:"destination,omitempty"`
+	// URL of an echo service returning the caller's public IP address.
+	// The probe uses it to discover its egress IP, unless configured with its own.
+	IpEchoUrl     string `protobuf:"bytes,2,opt,name=ip_echo_url,json=ipEchoUrl,proto3" json:"ip_echo_url,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -196,6 +218,13 @@ func (x *DialRequest) GetDestination() string {
 	return ""
 }
 
+func (x *DialRequest) GetIpEchoUrl() string {
+	if x != nil {
+		return x.IpEchoUrl
+	}
+	return ""
+}
+
 type TransferRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
@@ -323,8 +352,19 @@ func (*ForwardResponse_DialResponse) isForwardResponse_Response() {}
 func (*ForwardResponse_TransferResponse) isForwardResponse_Response() {}
 
 type DialResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Code          DialResponse_Code      `protobuf:"varint,1,opt,name=code,proto3,enum=forward.v1.DialResponse_Code" json:"code,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Code  DialResponse_Code      `protobuf:"varint,1,opt,name=code,proto3,enum=forward.v1.DialResponse_Code" json:"code,omitempty"`
+	// Public IP address that the probe egresses from.
+	// Empty if it is not known (yet).
+	EgressIp string `protobuf:"bytes,2,opt,name=egress_ip,json=egressIp,proto3" json:"egress_ip,omitempty"`
+	// IP address that the target host resolved to.
+	// Empty if it is not known, e.g. because resolving failed.
+	RemoteIp string `protobuf:"bytes,3,opt,name=remote_ip,json=remoteIp,proto3" json:"remote_ip,omitempty"`
+	// Local address of the probe's connection to the target.
+	// Empty if the dial failed.
+	LocalAddress string `protobuf:"bytes,4,opt,name=local_address,json=localAddress,proto3" json:"local_address,omitempty"`
+	// Time the probe spent dialing the target, in ns
+	DialDuration  uint64 `protobuf:"varint,5,opt,name=dial_duration,json=dialDuration,proto3" json:"dial_duration,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -366,6 +406,34 @@ func (x *DialResponse) GetCode() DialResponse_Code {
 	return DialResponse_CODE_UNSPECIFIED
 }
 
+func (x *DialResponse) GetEgressIp() string {
+	if x != nil {
+		return x.EgressIp
+	}
+	return ""
+}
+
+func (x *DialResponse) GetRemoteIp() string {
+	if x != nil {
+		return x.RemoteIp
+	}
+	return ""
+}
+
+func (x *DialResponse) GetLocalAddress() string {
+	if x != nil {
+		return x.LocalAddress
+	}
+	return ""
+}
+
+func (x *DialResponse) GetDialDuration() uint64 {
+	if x != nil {
+		return x.DialDuration
+	}
+	return 0
+}
+
 type TransferResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
@@ -410,6 +478,78 @@ func (x *TransferResponse) GetData() []byte {
 	return nil
 }
 
+type PingRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PingRequest) Reset() {
+	*x = PingRequest{}
+	mi := &file_forward_v1_main_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PingRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PingRequest) ProtoMessage() {}
+
+func (x *PingRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_forward_v1_main_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PingRequest.ProtoReflect.Descriptor instead.
+func (*PingRequest) Descriptor() ([]byte, []int) {
+	return file_forward_v1_main_proto_rawDescGZIP(), []int{6}
+}
+
+type PingResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PingResponse) Reset() {
+	*x = PingResponse{}
+	mi := &file_forward_v1_main_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PingResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PingResponse) ProtoMessage() {}
+
+func (x *PingResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_forward_v1_main_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PingResponse.ProtoReflect.Descriptor instead.
+func (*PingResponse) Descriptor() ([]byte, []int) {
+	return file_forward_v1_main_proto_rawDescGZIP(), []int{7}
+}
+
 var File_forward_v1_main_proto protoreflect.FileDescriptor
 
 const file_forward_v1_main_proto_rawDesc = "" +
@@ -419,26 +559,39 @@ const file_forward_v1_main_proto_rawDesc = "" +
 	"\x0eForwardRequest\x12<\n" +
 	"\fdial_request\x18\x01 \x01(\v2\x17.forward.v1.DialRequestH\x00R\vdialRequest\x12H\n" +
 	"\x10transfer_request\x18\x02 \x01(\v2\x1b.forward.v1.TransferRequestH\x00R\x0ftransferRequestB\t\n" +
-	"\arequest\"/\n" +
+	"\arequest\"O\n" +
 	"\vDialRequest\x12 \n" +
-	"\vdestination\x18\x01 \x01(\tR\vdestination\"%\n" +
+	"\vdestination\x18\x01 \x01(\tR\vdestination\x12\x1e\n" +
+	"\vip_echo_url\x18\x02 \x01(\tR\tipEchoUrl\"%\n" +
 	"\x0fTransferRequest\x12\x12\n" +
 	"\x04data\x18\x01 \x01(\fR\x04data\"\xab\x01\n" +
 	"\x0fForwardResponse\x12?\n" +
 	"\rdial_response\x18\x01 \x01(\v2\x18.forward.v1.DialResponseH\x00R\fdialResponse\x12K\n" +
 	"\x11transfer_response\x18\x02 \x01(\v2\x1c.forward.v1.TransferResponseH\x00R\x10transferResponseB\n" +
 	"\n" +
-	"\bresponse\"\x9b\x01\n" +
+	"\bresponse\"\x9f\x03\n" +
 	"\fDialResponse\x121\n" +
-	"\x04code\x18\x01 \x01(\x0e2\x1d.forward.v1.DialResponse.CodeR\x04code\"X\n" +
+	"\x04code\x18\x01 \x01(\x0e2\x1d.forward.v1.DialResponse.CodeR\x04code\x12\x1b\n" +
+	"\tegress_ip\x18\x02 \x01(\tR\begressIp\x12\x1b\n" +
+	"\tremote_ip\x18\x03 \x01(\tR\bremoteIp\x12#\n" +
+	"\rlocal_address\x18\x04 \x01(\tR\flocalAddress\x12#\n" +
+	"\rdial_duration\x18\x05 \x01(\x04R\fdialDuration\"\xd7\x01\n" +
 	"\x04Code\x12\x14\n" +
 	"\x10CODE_UNSPECIFIED\x10\x00\x12\x1f\n" +
 	"\x1bCODE_FAILED_TO_RESOLVE_HOST\x10\x01\x12\x19\n" +
-	"\x15CODE_HOST_UNREACHABLE\x10\x02\"&\n" +
+	"\x15CODE_HOST_UNREACHABLE\x10\x02\x12\x1a\n" +
+	"\x16CODE_BLOCKED_BY_POLICY\x10\x03\x12\x1b\n" +
+	"\x17CODE_CONNECTION_REFUSED\x10\x04\x12\x10\n" +
+	"\fCODE_TIMEOUT\x10\x05\x12\x1c\n" +
+	"\x18CODE_NETWORK_UNREACHABLE\x10\x06\x12\x14\n" +
+	"\x10CODE_TLS_FAILURE\x10\a\"&\n" +
 	"\x10TransferResponse\x12\x12\n" +
-	"\x04data\x18\x01 \x01(\fR\x04data2X\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\"\r\n" +
+	"\vPingRequest\"\x0e\n" +
+	"\fPingResponse2\x93\x01\n" +
 	"\x0eForwardService\x12F\n" +
-	"\aForward\x12\x1a.forward.v1.ForwardRequest\x1a\x1b.forward.v1.ForwardResponse(\x010\x01B\x99\x01\n" +
+	"\aForward\x12\x1a.forward.v1.ForwardRequest\x1a\x1b.forward.v1.ForwardResponse(\x010\x01\x129\n" +
+	"\x04Ping\x12\x17.forward.v1.PingRequest\x1a\x18.forward.v1.PingResponseB\x99\x01\n" +
 	"\x0ecom.forward.v1B\tMainProtoP\x01Z3github.com/isacskoglund/goroxy/forward/v1;forwardv1\xa2\x02\x03FXX\xaa\x02\n" +
 	"Forward.V1\xca\x02\n" +
 	"Forward\\V1\xe2\x02\x16Forward\\V1\\GPBMetadata\xea\x02\vForward::V1b\x06proto3"
@@ -456,7 +609,7 @@ func file_forward_v1_main_proto_rawDescGZIP() []byte {
 }
 
 var file_forward_v1_main_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_forward_v1_main_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_forward_v1_main_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
 var file_forward_v1_main_proto_goTypes = []any{
 	(DialResponse_Code)(0),   // 0: forward.v1.DialResponse.Code
 	(*ForwardRequest)(nil),   // 1: forward.v1.ForwardRequest
@@ -465,6 +618,8 @@ var file_forward_v1_main_proto_goTypes = []any{
 	(*ForwardResponse)(nil),  // 4: forward.v1.ForwardResponse
 	(*DialResponse)(nil),     // 5: forward.v1.DialResponse
 	(*TransferResponse)(nil), // 6: forward.v1.TransferResponse
+	(*PingRequest)(nil),      // 7: forward.v1.PingRequest
+	(*PingResponse)(nil),     // 8: forward.v1.PingResponse
 }
 var file_forward_v1_main_proto_depIdxs = []int32{
 	2, // 0: forward.v1.ForwardRequest.dial_request:type_name -> forward.v1.DialRequest
@@ -473,9 +628,11 @@ var file_forward_v1_main_proto_depIdxs = []int32{
 	6, // 3: forward.v1.ForwardResponse.transfer_response:type_name -> forward.v1.TransferResponse
 	0, // 4: forward.v1.DialResponse.code:type_name -> forward.v1.DialResponse.Code
 	1, // 5: forward.v1.ForwardService.Forward:input_type -> forward.v1.ForwardRequest
-	4, // 6: forward.v1.ForwardService.Forward:output_type -> forward.v1.ForwardResponse
-	6, // [6:7] is the sub-list for method output_type
-	5, // [5:6] is the sub-list for method input_type
+	7, // 6: forward.v1.ForwardService.Ping:input_type -> forward.v1.PingRequest
+	4, // 7: forward.v1.ForwardService.Forward:output_type -> forward.v1.ForwardResponse
+	8, // 8: forward.v1.ForwardService.Ping:output_type -> forward.v1.PingResponse
+	7, // [7:9] is the sub-list for method output_type
+	5, // [5:7] is the sub-list for method input_type
 	5, // [5:5] is the sub-list for extension type_name
 	5, // [5:5] is the sub-list for extension extendee
 	0, // [0:5] is the sub-list for field type_name
@@ -500,7 +657,7 @@ func file_forward_v1_main_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_forward_v1_main_proto_rawDesc), len(file_forward_v1_main_proto_rawDesc)),
 			NumEnums:      1,
-			NumMessages:   6,
+			NumMessages:   8,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -21,6 +21,162 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type ProbeEvent_State int32
+
+const (
+	ProbeEvent_STATE_UNSPECIFIED ProbeEvent_State = 0
+	ProbeEvent_STATE_HEALTHY     ProbeEvent_State = 1
+	ProbeEvent_STATE_EJECTED     ProbeEvent_State = 2
+)
+
+// Enum value maps for ProbeEvent_State.
+var (
+	ProbeEvent_State_name = map[int32]string{
+		0: "STATE_UNSPECIFIED",
+		1: "STATE_HEALTHY",
+		2: "STATE_EJECTED",
+	}
+	ProbeEvent_State_value = map[string]int32{
+		"STATE_UNSPECIFIED": 0,
+		"STATE_HEALTHY":     1,
+		"STATE_EJECTED":     2,
+	}
+)
+
+func (x ProbeEvent_State) Enum() *ProbeEvent_State {
+	p := new(ProbeEvent_State)
+	*p = x
+	return p
+}
+
+func (x ProbeEvent_State) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ProbeEvent_State) Descriptor() protoreflect.EnumDescriptor {
+	return file_telemetry_v1_main_proto_enumTypes[0].Descriptor()
+}
+
+func (ProbeEvent_State) Type() protoreflect.EnumType {
+	return &file_telemetry_v1_main_proto_enumTypes[0]
+}
+
+func (x ProbeEvent_State) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ProbeEvent_State.Descriptor instead.
+func (ProbeEvent_State) EnumDescriptor() ([]byte, []int) {
+	return file_telemetry_v1_main_proto_rawDescGZIP(), []int{8, 0}
+}
+
+type QueueEvent_Outcome int32
+
+const (
+	QueueEvent_OUTCOME_UNSPECIFIED QueueEvent_Outcome = 0
+	QueueEvent_OUTCOME_SERVED      QueueEvent_Outcome = 1
+	QueueEvent_OUTCOME_TIMED_OUT   QueueEvent_Outcome = 2
+	QueueEvent_OUTCOME_REJECTED    QueueEvent_Outcome = 3
+	QueueEvent_OUTCOME_CANCELED    QueueEvent_Outcome = 4
+)
+
+// Enum value maps for QueueEvent_Outcome.
+var (
+	QueueEvent_Outcome_name = map[int32]string{
+		0: "OUTCOME_UNSPECIFIED",
+		1: "OUTCOME_SERVED",
+		2: "OUTCOME_TIMED_OUT",
+		3: "OUTCOME_REJECTED",
+		4: "OUTCOME_CANCELED",
+	}
+	QueueEvent_Outcome_value = map[string]int32{
+		"OUTCOME_UNSPECIFIED": 0,
+		"OUTCOME_SERVED":      1,
+		"OUTCOME_TIMED_OUT":   2,
+		"OUTCOME_REJECTED":    3,
+		"OUTCOME_CANCELED":    4,
+	}
+)
+
+func (x QueueEvent_Outcome) Enum() *QueueEvent_Outcome {
+	p := new(QueueEvent_Outcome)
+	*p = x
+	return p
+}
+
+func (x QueueEvent_Outcome) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (QueueEvent_Outcome) Descriptor() protoreflect.EnumDescriptor {
+	return file_telemetry_v1_main_proto_enumTypes[1].Descriptor()
+}
+
+func (QueueEvent_Outcome) Type() protoreflect.EnumType {
+	return &file_telemetry_v1_main_proto_enumTypes[1]
+}
+
+func (x QueueEvent_Outcome) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use QueueEvent_Outcome.Descriptor instead.
+func (QueueEvent_Outcome) EnumDescriptor() ([]byte, []int) {
+	return file_telemetry_v1_main_proto_rawDescGZIP(), []int{11, 0}
+}
+
+type PolicyEvent_Violation int32
+
+const (
+	PolicyEvent_VIOLATION_UNSPECIFIED      PolicyEvent_Violation = 0
+	PolicyEvent_VIOLATION_PORT_NOT_ALLOWED PolicyEvent_Violation = 1
+	PolicyEvent_VIOLATION_DOMAIN_DENIED    PolicyEvent_Violation = 2
+	PolicyEvent_VIOLATION_IP_RANGE_DENIED  PolicyEvent_Violation = 3
+)
+
+// Enum value maps for PolicyEvent_Violation.
+var (
+	PolicyEvent_Violation_name = map[int32]string{
+		0: "VIOLATION_UNSPECIFIED",
+		1: "VIOLATION_PORT_NOT_ALLOWED",
+		2: "VIOLATION_DOMAIN_DENIED",
+		3: "VIOLATION_IP_RANGE_DENIED",
+	}
+	PolicyEvent_Violation_value = map[string]int32{
+		"VIOLATION_UNSPECIFIED":      0,
+		"VIOLATION_PORT_NOT_ALLOWED": 1,
+		"VIOLATION_DOMAIN_DENIED":    2,
+		"VIOLATION_IP_RANGE_DENIED":  3,
+	}
+)
+
+func (x PolicyEvent_Violation) Enum() *PolicyEvent_Violation {
+	p := new(PolicyEvent_Violation)
+	*p = x
+	return p
+}
+
+func (x PolicyEvent_Violation) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PolicyEvent_Violation) Descriptor() protoreflect.EnumDescriptor {
+	return file_telemetry_v1_main_proto_enumTypes[2].Descriptor()
+}
+
+func (PolicyEvent_Violation) Type() protoreflect.EnumType {
+	return &file_telemetry_v1_main_proto_enumTypes[2]
+}
+
+func (x PolicyEvent_Violation) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PolicyEvent_Violation.Descriptor instead.
+func (PolicyEvent_Violation) EnumDescriptor() ([]byte, []int) {
+	return file_telemetry_v1_main_proto_rawDescGZIP(), []int{14, 0}
+}
+
 type TransferSubscribeRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
@@ -260,7 +416,10 @@ type ConnectionEvent struct {
 	OpenedAt uint64 `protobuf:"varint,4,opt,name=opened_at,json=openedAt,proto3" json:"opened_at,omitempty"`
 	// Unix epoch ns
 	// 0 indicates yet to be closed
-	ClosedAt      uint64 `protobuf:"varint,5,opt,name=closed_at,json=closedAt,proto3" json:"closed_at,omitempty"`
+	ClosedAt uint64 `protobuf:"varint,5,opt,name=closed_at,json=closedAt,proto3" json:"closed_at,omitempty"`
+	Probe    string `protobuf:"bytes,6,opt,name=probe,proto3" json:"probe,omitempty"`
+	// Public IP address of the probe, empty if unknown
+	EgressIp      string `protobuf:"bytes,7,opt,name=egress_ip,json=egressIp,proto3" json:"egress_ip,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -330,6 +489,481 @@ func (x *ConnectionEvent) GetClosedAt() uint64 {
 	return 0
 }
 
+func (x *ConnectionEvent) GetProbe() string {
+	if x != nil {
+		return x.Probe
+	}
+	return ""
+}
+
+func (x *ConnectionEvent) GetEgressIp() string {
+	if x != nil {
+		return x.EgressIp
+	}
+	return ""
+}
+
+type ProbeSubscribeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProbeSubscribeRequest) Reset() {
+	*x = ProbeSubscribeRequest{}
+	mi := &file_telemetry_v1_main_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProbeSubscribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProbeSubscribeRequest) ProtoMessage() {}
+
+func (x *ProbeSubscribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_telemetry_v1_main_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProbeSubscribeRequest.ProtoReflect.Descriptor instead.
+func (*ProbeSubscribeRequest) Descriptor() ([]byte, []int) {
+	return file_telemetry_v1_main_proto_rawDescGZIP(), []int{6}
+}
+
+type ProbeSubscribeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Events        []*ProbeEvent          `protobuf:"bytes,1,rep,name=events,proto3" json:"events,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProbeSubscribeResponse) Reset() {
+	*x = ProbeSubscribeResponse{}
+	mi := &file_telemetry_v1_main_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProbeSubscribeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProbeSubscribeResponse) ProtoMessage() {}
+
+func (x *ProbeSubscribeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_telemetry_v1_main_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProbeSubscribeResponse.ProtoReflect.Descriptor instead.
+func (*ProbeSubscribeResponse) Descriptor() ([]byte, []int) {
+	return file_telemetry_v1_main_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ProbeSubscribeResponse) GetEvents() []*ProbeEvent {
+	if x != nil {
+		return x.Events
+	}
+	return nil
+}
+
+type ProbeEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Probe string                 `protobuf:"bytes,1,opt,name=probe,proto3" json:"probe,omitempty"`
+	State ProbeEvent_State       `protobuf:"varint,2,opt,name=state,proto3,enum=telemetry.v1.ProbeEvent_State" json:"state,omitempty"`
+	// Human-readable reason for the state change
+	Reason string `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+	// Unix epoch ns
+	ChangedAt     uint64 `protobuf:"varint,4,opt,name=changed_at,json=changedAt,proto3" json:"changed_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProbeEvent) Reset() {
+	*x = ProbeEvent{}
+	mi := &file_telemetry_v1_main_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProbeEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProbeEvent) ProtoMessage() {}
+
+func (x *ProbeEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_telemetry_v1_main_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProbeEvent.ProtoReflect.Descriptor instead.
+func (*ProbeEvent) Descriptor() ([]byte, []int) {
+	return file_telemetry_v1_main_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ProbeEvent) GetProbe() string {
+	if x != nil {
+		return x.Probe
+	}
+	return ""
+}
+
+func (x *ProbeEvent) GetState() ProbeEvent_State {
+	if x != nil {
+		return x.State
+	}
+	return ProbeEvent_STATE_UNSPECIFIED
+}
+
+func (x *ProbeEvent) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *ProbeEvent) GetChangedAt() uint64 {
+	if x != nil {
+		return x.ChangedAt
+	}
+	return 0
+}
+
+type QueueSubscribeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueueSubscribeRequest) Reset() {
+	*x = QueueSubscribeRequest{}
+	mi := &file_telemetry_v1_main_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueueSubscribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueueSubscribeRequest) ProtoMessage() {}
+
+func (x *QueueSubscribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_telemetry_v1_main_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueueSubscribeRequest.ProtoReflect.Descriptor instead.
+func (*QueueSubscribeRequest) Descriptor() ([]byte, []int) {
+	return file_telemetry_v1_main_proto_rawDescGZIP(), []int{9}
+}
+
+type QueueSubscribeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Events        []*QueueEvent          `protobuf:"bytes,1,rep,name=events,proto3" json:"events,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueueSubscribeResponse) Reset() {
+	*x = QueueSubscribeResponse{}
+	mi := &file_telemetry_v1_main_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueueSubscribeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueueSubscribeResponse) ProtoMessage() {}
+
+func (x *QueueSubscribeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_telemetry_v1_main_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueueSubscribeResponse.ProtoReflect.Descriptor instead.
+func (*QueueSubscribeResponse) Descriptor() ([]byte, []int) {
+	return file_telemetry_v1_main_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *QueueSubscribeResponse) GetEvents() []*QueueEvent {
+	if x != nil {
+		return x.Events
+	}
+	return nil
+}
+
+// A connection that left the queue of connections waiting for a probe
+type QueueEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TargetAddress string                 `protobuf:"bytes,1,opt,name=target_address,json=targetAddress,proto3" json:"target_address,omitempty"`
+	Outcome       QueueEvent_Outcome     `protobuf:"varint,2,opt,name=outcome,proto3,enum=telemetry.v1.QueueEvent_Outcome" json:"outcome,omitempty"`
+	// Number of connections still waiting after this one left
+	Depth uint64 `protobuf:"varint,3,opt,name=depth,proto3" json:"depth,omitempty"`
+	// Unix epoch ns
+	EnqueuedAt uint64 `protobuf:"varint,4,opt,name=enqueued_at,json=enqueuedAt,proto3" json:"enqueued_at,omitempty"`
+	// Unix epoch ns
+	DequeuedAt    uint64 `protobuf:"varint,5,opt,name=dequeued_at,json=dequeuedAt,proto3" json:"dequeued_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueueEvent) Reset() {
+	*x = QueueEvent{}
+	mi := &file_telemetry_v1_main_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueueEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueueEvent) ProtoMessage() {}
+
+func (x *QueueEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_telemetry_v1_main_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueueEvent.ProtoReflect.Descriptor instead.
+func (*QueueEvent) Descriptor() ([]byte, []int) {
+	return file_telemetry_v1_main_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *QueueEvent) GetTargetAddress() string {
+	if x != nil {
+		return x.TargetAddress
+	}
+	return ""
+}
+
+func (x *QueueEvent) GetOutcome() QueueEvent_Outcome {
+	if x != nil {
+		return x.Outcome
+	}
+	return QueueEvent_OUTCOME_UNSPECIFIED
+}
+
+func (x *QueueEvent) GetDepth() uint64 {
+	if x != nil {
+		return x.Depth
+	}
+	return 0
+}
+
+func (x *QueueEvent) GetEnqueuedAt() uint64 {
+	if x != nil {
+		return x.EnqueuedAt
+	}
+	return 0
+}
+
+func (x *QueueEvent) GetDequeuedAt() uint64 {
+	if x != nil {
+		return x.DequeuedAt
+	}
+	return 0
+}
+
+type PolicySubscribeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PolicySubscribeRequest) Reset() {
+	*x = PolicySubscribeRequest{}
+	mi := &file_telemetry_v1_main_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PolicySubscribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PolicySubscribeRequest) ProtoMessage() {}
+
+func (x *PolicySubscribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_telemetry_v1_main_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PolicySubscribeRequest.ProtoReflect.Descriptor instead.
+func (*PolicySubscribeRequest) Descriptor() ([]byte, []int) {
+	return file_telemetry_v1_main_proto_rawDescGZIP(), []int{12}
+}
+
+type PolicySubscribeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Events        []*PolicyEvent         `protobuf:"bytes,1,rep,name=events,proto3" json:"events,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PolicySubscribeResponse) Reset() {
+	*x = PolicySubscribeResponse{}
+	mi := &file_telemetry_v1_main_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PolicySubscribeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PolicySubscribeResponse) ProtoMessage() {}
+
+func (x *PolicySubscribeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_telemetry_v1_main_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PolicySubscribeResponse.ProtoReflect.Descriptor instead.
+func (*PolicySubscribeResponse) Descriptor() ([]byte, []int) {
+	return file_telemetry_v1_main_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *PolicySubscribeResponse) GetEvents() []*PolicyEvent {
+	if x != nil {
+		return x.Events
+	}
+	return nil
+}
+
+// A connection denied by the access policy of the hub
+type PolicyEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TargetAddress string                 `protobuf:"bytes,1,opt,name=target_address,json=targetAddress,proto3" json:"target_address,omitempty"`
+	Violation     PolicyEvent_Violation  `protobuf:"varint,2,opt,name=violation,proto3,enum=telemetry.v1.PolicyEvent_Violation" json:"violation,omitempty"`
+	// Denied port, domain pattern or IP range that matched
+	Rule string `protobuf:"bytes,3,opt,name=rule,proto3" json:"rule,omitempty"`
+	// Unix epoch ns
+	DeniedAt      uint64 `protobuf:"varint,4,opt,name=denied_at,json=deniedAt,proto3" json:"denied_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PolicyEvent) Reset() {
+	*x = PolicyEvent{}
+	mi := &file_telemetry_v1_main_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PolicyEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PolicyEvent) ProtoMessage() {}
+
+func (x *PolicyEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_telemetry_v1_main_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PolicyEvent.ProtoReflect.Descriptor instead.
+func (*PolicyEvent) Descriptor() ([]byte, []int) {
+	return file_telemetry_v1_main_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *PolicyEvent) GetTargetAddress() string {
+	if x != nil {
+		return x.TargetAddress
+	}
+	return ""
+}
+
+func (x *PolicyEvent) GetViolation() PolicyEvent_Violation {
+	if x != nil {
+		return x.Violation
+	}
+	return PolicyEvent_VIOLATION_UNSPECIFIED
+}
+
+func (x *PolicyEvent) GetRule() string {
+	if x != nil {
+		return x.Rule
+	}
+	return ""
+}
+
+func (x *PolicyEvent) GetDeniedAt() uint64 {
+	if x != nil {
+		return x.DeniedAt
+	}
+	return 0
+}
+
 var File_telemetry_v1_main_proto protoreflect.FileDescriptor
 
 const file_telemetry_v1_main_proto_rawDesc = "" +
@@ -348,16 +982,66 @@ const file_telemetry_v1_main_proto_rawDesc = "" +
 	"bytesCount\"\x1c\n" +
 	"\x1aConnectionSubscribeRequest\"T\n" +
 	"\x1bConnectionSubscribeResponse\x125\n" +
-	"\x06events\x18\x01 \x03(\v2\x1d.telemetry.v1.ConnectionEventR\x06events\"\xbe\x01\n" +
+	"\x06events\x18\x01 \x03(\v2\x1d.telemetry.v1.ConnectionEventR\x06events\"\xf1\x01\n" +
 	"\x0fConnectionEvent\x12#\n" +
 	"\rconnection_id\x18\x01 \x01(\tR\fconnectionId\x12%\n" +
 	"\x0eclient_address\x18\x02 \x01(\tR\rclientAddress\x12%\n" +
 	"\x0etarget_address\x18\x03 \x01(\tR\rtargetAddress\x12\x1b\n" +
 	"\topened_at\x18\x04 \x01(\x04R\bopenedAt\x12\x1b\n" +
-	"\tclosed_at\x18\x05 \x01(\x04R\bclosedAt2\xe8\x01\n" +
+	"\tclosed_at\x18\x05 \x01(\x04R\bclosedAt\x12\x14\n" +
+	"\x05probe\x18\x06 \x01(\tR\x05probe\x12\x1b\n" +
+	"\tegress_ip\x18\a \x01(\tR\begressIp\"\x17\n" +
+	"\x15ProbeSubscribeRequest\"J\n" +
+	"\x16ProbeSubscribeResponse\x120\n" +
+	"\x06events\x18\x01 \x03(\v2\x18.telemetry.v1.ProbeEventR\x06events\"\xd5\x01\n" +
+	"\n" +
+	"ProbeEvent\x12\x14\n" +
+	"\x05probe\x18\x01 \x01(\tR\x05probe\x124\n" +
+	"\x05state\x18\x02 \x01(\x0e2\x1e.telemetry.v1.ProbeEvent.StateR\x05state\x12\x16\n" +
+	"\x06reason\x18\x03 \x01(\tR\x06reason\x12\x1d\n" +
+	"\n" +
+	"changed_at\x18\x04 \x01(\x04R\tchangedAt\"D\n" +
+	"\x05State\x12\x15\n" +
+	"\x11STATE_UNSPECIFIED\x10\x00\x12\x11\n" +
+	"\rSTATE_HEALTHY\x10\x01\x12\x11\n" +
+	"\rSTATE_EJECTED\x10\x02\"\x17\n" +
+	"\x15QueueSubscribeRequest\"J\n" +
+	"\x16QueueSubscribeResponse\x120\n" +
+	"\x06events\x18\x01 \x03(\v2\x18.telemetry.v1.QueueEventR\x06events\"\xc2\x02\n" +
+	"\n" +
+	"QueueEvent\x12%\n" +
+	"\x0etarget_address\x18\x01 \x01(\tR\rtargetAddress\x12:\n" +
+	"\aoutcome\x18\x02 \x01(\x0e2 .telemetry.v1.QueueEvent.OutcomeR\aoutcome\x12\x14\n" +
+	"\x05depth\x18\x03 \x01(\x04R\x05depth\x12\x1f\n" +
+	"\venqueued_at\x18\x04 \x01(\x04R\n" +
+	"enqueuedAt\x12\x1f\n" +
+	"\vdequeued_at\x18\x05 \x01(\x04R\n" +
+	"dequeuedAt\"y\n" +
+	"\aOutcome\x12\x17\n" +
+	"\x13OUTCOME_UNSPECIFIED\x10\x00\x12\x12\n" +
+	"\x0eOUTCOME_SERVED\x10\x01\x12\x15\n" +
+	"\x11OUTCOME_TIMED_OUT\x10\x02\x12\x14\n" +
+	"\x10OUTCOME_REJECTED\x10\x03\x12\x14\n" +
+	"\x10OUTCOME_CANCELED\x10\x04\"\x18\n" +
+	"\x16PolicySubscribeRequest\"L\n" +
+	"\x17PolicySubscribeResponse\x121\n" +
+	"\x06events\x18\x01 \x03(\v2\x19.telemetry.v1.PolicyEventR\x06events\"\xad\x02\n" +
+	"\vPolicyEvent\x12%\n" +
+	"\x0etarget_address\x18\x01 \x01(\tR\rtargetAddress\x12A\n" +
+	"\tviolation\x18\x02 \x01(\x0e2#.telemetry.v1.PolicyEvent.ViolationR\tviolation\x12\x12\n" +
+	"\x04rule\x18\x03 \x01(\tR\x04rule\x12\x1b\n" +
+	"\tdenied_at\x18\x04 \x01(\x04R\bdeniedAt\"\x82\x01\n" +
+	"\tViolation\x12\x19\n" +
+	"\x15VIOLATION_UNSPECIFIED\x10\x00\x12\x1e\n" +
+	"\x1aVIOLATION_PORT_NOT_ALLOWED\x10\x01\x12\x1b\n" +
+	"\x17VIOLATION_DOMAIN_DENIED\x10\x02\x12\x1d\n" +
+	"\x19VIOLATION_IP_RANGE_DENIED\x10\x032\x88\x04\n" +
 	"\x10TelemetryService\x12f\n" +
 	"\x11TransferSubscribe\x12&.telemetry.v1.TransferSubscribeRequest\x1a'.telemetry.v1.TransferSubscribeResponse0\x01\x12l\n" +
-	"\x13ConnectionSubscribe\x12(.telemetry.v1.ConnectionSubscribeRequest\x1a).telemetry.v1.ConnectionSubscribeResponse0\x01B\xa7\x01\n" +
+	"\x13ConnectionSubscribe\x12(.telemetry.v1.ConnectionSubscribeRequest\x1a).telemetry.v1.ConnectionSubscribeResponse0\x01\x12]\n" +
+	"\x0eProbeSubscribe\x12#.telemetry.v1.ProbeSubscribeRequest\x1a$.telemetry.v1.ProbeSubscribeResponse0\x01\x12]\n" +
+	"\x0eQueueSubscribe\x12#.telemetry.v1.QueueSubscribeRequest\x1a$.telemetry.v1.QueueSubscribeResponse0\x01\x12`\n" +
+	"\x0fPolicySubscribe\x12$.telemetry.v1.PolicySubscribeRequest\x1a%.telemetry.v1.PolicySubscribeResponse0\x01B\xa7\x01\n" +
 	"\x10com.telemetry.v1B\tMainProtoP\x01Z7github.com/isacskoglund/goroxy/telemetry/v1;telemetryv1\xa2\x02\x03TXX\xaa\x02\fTelemetry.V1\xca\x02\fTelemetry\\V1\xe2\x02\x18Telemetry\\V1\\GPBMetadata\xea\x02\rTelemetry::V1b\x06proto3"
 
 var (
@@ -372,27 +1056,52 @@ func file_telemetry_v1_main_proto_rawDescGZIP() []byte {
 	return file_telemetry_v1_main_proto_rawDescData
 }
 
-var file_telemetry_v1_main_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_telemetry_v1_main_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_telemetry_v1_main_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
 var file_telemetry_v1_main_proto_goTypes = []any{
-	(*TransferSubscribeRequest)(nil),    // 0: telemetry.v1.TransferSubscribeRequest
-	(*TransferSubscribeResponse)(nil),   // 1: telemetry.v1.TransferSubscribeResponse
-	(*TransferEvent)(nil),               // 2: telemetry.v1.TransferEvent
-	(*ConnectionSubscribeRequest)(nil),  // 3: telemetry.v1.ConnectionSubscribeRequest
-	(*ConnectionSubscribeResponse)(nil), // 4: telemetry.v1.ConnectionSubscribeResponse
-	(*ConnectionEvent)(nil),             // 5: telemetry.v1.ConnectionEvent
+	(ProbeEvent_State)(0),               // 0: telemetry.v1.ProbeEvent.State
+	(QueueEvent_Outcome)(0),             // 1: telemetry.v1.QueueEvent.Outcome
+	(PolicyEvent_Violation)(0),          // 2: telemetry.v1.PolicyEvent.Violation
+	(*TransferSubscribeRequest)(nil),    // 3: telemetry.v1.TransferSubscribeRequest
+	(*TransferSubscribeResponse)(nil),   // 4: telemetry.v1.TransferSubscribeResponse
+	(*TransferEvent)(nil),               // 5: telemetry.v1.TransferEvent
+	(*ConnectionSubscribeRequest)(nil),  // 6: telemetry.v1.ConnectionSubscribeRequest
+	(*ConnectionSubscribeResponse)(nil), // 7: telemetry.v1.ConnectionSubscribeResponse
+	(*ConnectionEvent)(nil),             // 8: telemetry.v1.ConnectionEvent
+	(*ProbeSubscribeRequest)(nil),       // 9: telemetry.v1.ProbeSubscribeRequest
+	(*ProbeSubscribeResponse)(nil),      // 10: telemetry.v1.ProbeSubscribeResponse
+	(*ProbeEvent)(nil),                  // 11: telemetry.v1.ProbeEvent
+	(*QueueSubscribeRequest)(nil),       // 12: telemetry.v1.QueueSubscribeRequest
+	(*QueueSubscribeResponse)(nil),      // 13: telemetry.v1.QueueSubscribeResponse
+	(*QueueEvent)(nil),                  // 14: telemetry.v1.QueueEvent
+	(*PolicySubscribeRequest)(nil),      // 15: telemetry.v1.PolicySubscribeRequest
+	(*PolicySubscribeResponse)(nil),     // 16: telemetry.v1.PolicySubscribeResponse
+	(*PolicyEvent)(nil),                 // 17: telemetry.v1.PolicyEvent
 }
 var file_telemetry_v1_main_proto_depIdxs = []int32{
-	2, // 0: telemetry.v1.TransferSubscribeResponse.events:type_name -> telemetry.v1.TransferEvent
-	5, // 1: telemetry.v1.ConnectionSubscribeResponse.events:type_name -> telemetry.v1.ConnectionEvent
-	0, // 2: telemetry.v1.TelemetryService.TransferSubscribe:input_type -> telemetry.v1.TransferSubscribeRequest
-	3, // 3: telemetry.v1.TelemetryService.ConnectionSubscribe:input_type -> telemetry.v1.ConnectionSubscribeRequest
-	1, // 4: telemetry.v1.TelemetryService.TransferSubscribe:output_type -> telemetry.v1.TransferSubscribeResponse
-	4, // 5: telemetry.v1.TelemetryService.ConnectionSubscribe:output_type -> telemetry.v1.ConnectionSubscribeResponse
-	4, // [4:6] is the sub-list for method output_type
-	2, // [2:4] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	5,  // 0: telemetry.v1.TransferSubscribeResponse.events:type_name -> telemetry.v1.TransferEvent
+	8,  // 1: telemetry.v1.ConnectionSubscribeResponse.events:type_name -> telemetry.v1.ConnectionEvent
+	11, // 2: telemetry.v1.ProbeSubscribeResponse.events:type_name -> telemetry.v1.ProbeEvent
+	0,  // 3: telemetry.v1.ProbeEvent.state:type_name -> telemetry.v1.ProbeEvent.State
+	14, // 4: telemetry.v1.QueueSubscribeResponse.events:type_name -> telemetry.v1.QueueEvent
+	1,  // 5: telemetry.v1.QueueEvent.outcome:type_name -> telemetry.v1.QueueEvent.Outcome
+	17, // 6: telemetry.v1.PolicySubscribeResponse.events:type_name -> telemetry.v1.PolicyEvent
+	2,  // 7: telemetry.v1.PolicyEvent.violation:type_name -> telemetry.v1.PolicyEvent.Violation
+	3,  // 8: telemetry.v1.TelemetryService.TransferSubscribe:input_type -> telemetry.v1.TransferSubscribeRequest
+	6,  // 9: telemetry.v1.TelemetryService.ConnectionSubscribe:input_type -> telemetry.v1.ConnectionSubscribeRequest
+	9,  // 10: telemetry.v1.TelemetryService.ProbeSubscribe:input_type -> telemetry.v1.ProbeSubscribeRequest
+	12, // 11: telemetry.v1.TelemetryService.QueueSubscribe:input_type -> telemetry.v1.QueueSubscribeRequest
+	15, // 12: telemetry.v1.TelemetryService.PolicySubscribe:input_type -> telemetry.v1.PolicySubscribeRequest
+	4,  // 13: telemetry.v1.TelemetryService.TransferSubscribe:output_type -> telemetry.v1.TransferSubscribeResponse
+	7,  // 14: telemetry.v1.TelemetryService.ConnectionSubscribe:output_type -> telemetry.v1.ConnectionSubscribeResponse
+	10, // 15: telemetry.v1.TelemetryService.ProbeSubscribe:output_type -> telemetry.v1.ProbeSubscribeResponse
+	13, // 16: telemetry.v1.TelemetryService.QueueSubscribe:output_type -> telemetry.v1.QueueSubscribeResponse
+	16, // 17: telemetry.v1.TelemetryService.PolicySubscribe:output_type -> telemetry.v1.PolicySubscribeResponse
+	13, // [13:18] is the sub-list for method output_type
+	8,  // [8:13] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
 }
 
 func init() { file_telemetry_v1_main_proto_init() }
@@ -405,13 +1114,14 @@ func file_telemetry_v1_main_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_telemetry_v1_main_proto_rawDesc), len(file_telemetry_v1_main_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   6,
+			NumEnums:      3,
+			NumMessages:   15,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_telemetry_v1_main_proto_goTypes,
 		DependencyIndexes: file_telemetry_v1_main_proto_depIdxs,
+		EnumInfos:         file_telemetry_v1_main_proto_enumTypes,
 		MessageInfos:      file_telemetry_v1_main_proto_msgTypes,
 	}.Build()
 	File_telemetry_v1_main_proto = out.File
@@ -21,6 +21,9 @@ const _ = grpc.SupportPackageIsVersion9
 const (
 	TelemetryService_TransferSubscribe_FullMethodName   = "/telemetry.v1.TelemetryService/TransferSubscribe"
 	TelemetryService_ConnectionSubscribe_FullMethodName = "/telemetry.v1.TelemetryService/ConnectionSubscribe"
+	TelemetryService_ProbeSubscribe_FullMethodName      = "/telemetry.v1.TelemetryService/ProbeSubscribe"
+	TelemetryService_QueueSubscribe_FullMethodName      = "/telemetry.v1.TelemetryService/QueueSubscribe"
+	TelemetryService_PolicySubscribe_FullMethodName     = "/telemetry.v1.TelemetryService/PolicySubscribe"
 )
 
 // TelemetryServiceClient is the client API for TelemetryService service.
@@ -29,6 +32,9 @@ const (
 type TelemetryServiceClient interface {
 	TransferSubscribe(ctx context.Context, in *TransferSubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TransferSubscribeResponse], error)
 	ConnectionSubscribe(ctx context.Context, in *ConnectionSubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ConnectionSubscribeResponse], error)
+	ProbeSubscribe(ctx context.Context, in *ProbeSubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ProbeSubscribeResponse], error)
+	QueueSubscribe(ctx context.Context, in *QueueSubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[QueueSubscribeResponse], error)
+	PolicySubscribe(ctx context.Context, in *PolicySubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PolicySubscribeResponse], error)
 }
 
 type telemetryServiceClient struct {
@@ -77,12 +83,72 @@ func (c *telemetryServiceClient) ConnectionSubscribe(ctx context.Context, in *Co
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type TelemetryService_ConnectionSubscribeClient = grpc.ServerStreamingClient[ConnectionSubscribeResponse]
 
+func (c *telemetryServiceClient) ProbeSubscribe(ctx context.Context, in *ProbeSubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ProbeSubscribeResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &TelemetryService_ServiceDesc.Streams[2], TelemetryService_ProbeSubscribe_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ProbeSubscribeRequest, ProbeSubscribeResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TelemetryService_ProbeSubscribeClient = grpc.ServerStreamingClient[ProbeSubscribeResponse]
+
+func (c *telemetryServiceClient) QueueSubscribe(ctx context.Context, in *QueueSubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[QueueSubscribeResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &TelemetryService_ServiceDesc.Streams[3], TelemetryService_QueueSubscribe_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[QueueSubscribeRequest, QueueSubscribeResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TelemetryService_QueueSubscribeClient = grpc.ServerStreamingClient[QueueSubscribeResponse]
+
+func (c *telemetryServiceClient) PolicySubscribe(ctx context.Context, in *PolicySubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PolicySubscribeResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &TelemetryService_ServiceDesc.Streams[4], TelemetryService_PolicySubscribe_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[PolicySubscribeRequest, PolicySubscribeResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TelemetryService_PolicySubscribeClient = grpc.ServerStreamingClient[PolicySubscribeResponse]
+
 // TelemetryServiceServer is the server API for TelemetryService service.
 // All implementations must embed UnimplementedTelemetryServiceServer
 // for forward compatibility.
 type TelemetryServiceServer interface {
 	TransferSubscribe(*TransferSubscribeRequest, grpc.ServerStreamingServer[TransferSubscribeResponse]) error
 	ConnectionSubscribe(*ConnectionSubscribeRequest, grpc.ServerStreamingServer[ConnectionSubscribeResponse]) error
+	ProbeSubscribe(*ProbeSubscribeRequest, grpc.ServerStreamingServer[ProbeSubscribeResponse]) error
+	QueueSubscribe(*QueueSubscribeRequest, grpc.ServerStreamingServer[QueueSubscribeResponse]) error
+	PolicySubscribe(*PolicySubscribeRequest, grpc.ServerStreamingServer[PolicySubscribeResponse]) error
 	mustEmbedUnimplementedTelemetryServiceServer()
 }
 
@@ -99,6 +165,15 @@ func (UnimplementedTelemetryServiceServer) TransferSubscribe(*TransferSubscribeR
 func (UnimplementedTelemetryServiceServer) ConnectionSubscribe(*ConnectionSubscribeRequest, grpc.ServerStreamingServer[ConnectionSubscribeResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method ConnectionSubscribe not implemented")
 }
+func (UnimplementedTelemetryServiceServer) ProbeSubscribe(*ProbeSubscribeRequest, grpc.ServerStreamingServer[ProbeSubscribeResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method ProbeSubscribe not implemented")
+}
+func (UnimplementedTelemetryServiceServer) QueueSubscribe(*QueueSubscribeRequest, grpc.ServerStreamingServer[QueueSubscribeResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method QueueSubscribe not implemented")
+}
+func (UnimplementedTelemetryServiceServer) PolicySubscribe(*PolicySubscribeRequest, grpc.ServerStreamingServer[PolicySubscribeResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method PolicySubscribe not implemented")
+}
 func (UnimplementedTelemetryServiceServer) mustEmbedUnimplementedTelemetryServiceServer() {}
 func (UnimplementedTelemetryServiceServer) testEmbeddedByValue()                          {}
 
@@ -142,6 +217,39 @@ func _TelemetryService_ConnectionSubscribe_Handler(srv interface{}, stream grpc.
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type TelemetryService_ConnectionSubscribeServer = grpc.ServerStreamingServer[ConnectionSubscribeResponse]
 
+func _TelemetryService_ProbeSubscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ProbeSubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TelemetryServiceServer).ProbeSubscribe(m, &grpc.GenericServerStream[ProbeSubscribeRequest, ProbeSubscribeResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TelemetryService_ProbeSubscribeServer = grpc.ServerStreamingServer[ProbeSubscribeResponse]
+
+func _TelemetryService_QueueSubscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(QueueSubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TelemetryServiceServer).QueueSubscribe(m, &grpc.GenericServerStream[QueueSubscribeRequest, QueueSubscribeResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TelemetryService_QueueSubscribeServer = grpc.ServerStreamingServer[QueueSubscribeResponse]
+
+func _TelemetryService_PolicySubscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PolicySubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TelemetryServiceServer).PolicySubscribe(m, &grpc.GenericServerStream[PolicySubscribeRequest, PolicySubscribeResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TelemetryService_PolicySubscribeServer = grpc.ServerStreamingServer[PolicySubscribeResponse]
+
 // TelemetryService_ServiceDesc is the grpc.ServiceDesc for TelemetryService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -160,6 +268,21 @@ var TelemetryService_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _TelemetryService_ConnectionSubscribe_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "ProbeSubscribe",
+			Handler:       _TelemetryService_ProbeSubscribe_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "QueueSubscribe",
+			Handler:       _TelemetryService_QueueSubscribe_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "PolicySubscribe",
+			Handler:       _TelemetryService_PolicySubscribe_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "telemetry/v1/main.proto",
 }
@@ -0,0 +1,113 @@
+package common
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/isacskoglund/rotox/internal/fault"
+)
+
+// DialInfo describes how a target was dialed.
+type DialInfo struct {
+	RemoteIp     string        // IP address the target host resolved to, empty if unknown
+	LocalAddress string        // Local address of the connection to the target, empty if not connected
+	Duration     time.Duration // Time spent dialing the target
+}
+
+// DialInfoConn is a connection that knows how its target was dialed.
+type DialInfoConn interface {
+	Conn
+	DialInfo() DialInfo
+}
+
+// dialInfoError attaches a DialInfo to a dial error.
+type dialInfoError struct {
+	err  error
+	info DialInfo
+}
+
+func (err *dialInfoError) Error() string {
+	return err.err.Error()
+}
+
+func (err *dialInfoError) Unwrap() error {
+	return err.err
+}
+
+// WithDialInfo attaches info about a failed dial to err. It returns nil if
+// err is nil. The error code of err is kept.
+func WithDialInfo(err error, info DialInfo) error {
+	if err == nil {
+		return nil
+	}
+	return &dialInfoError{err: err, info: info}
+}
+
+// DialInfoOf returns the DialInfo attached to err with WithDialInfo, if any.
+func DialInfoOf(err error) (DialInfo, bool) {
+	var infoErr *dialInfoError
+	if errors.As(err, &infoErr) {
+		return infoErr.info, true
+	}
+	return DialInfo{}, false
+}
+
+// InterpretDialError converts an error of dialing a target with the net
+// package into an error with the matching ForwardErrorCode.
+func InterpretDialError(err error, prefix string) error {
+	if err == nil {
+		return nil
+	}
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &dnsErr):
+		return fault.Wrap(err, prefix, ForwardFailedToResolveHost)
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return fault.Wrap(err, prefix, ForwardTimeout)
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return fault.Wrap(err, prefix, ForwardConnectionRefused)
+	case errors.Is(err, syscall.ENETUNREACH):
+		return fault.Wrap(err, prefix, ForwardNetworkUnreachable)
+	case isTlsError(err):
+		return fault.Wrap(err, prefix, ForwardTlsFailure)
+	case errors.As(err, &opErr):
+		return fault.Wrap(err, prefix, ForwardHostUnreachable)
+	}
+	return fault.Wrap(err, prefix, ForwardUnknown)
+}
+
+// isTlsError reports whether err was caused by a failed TLS handshake, e.g.
+// when dialing with a tls.Dialer.
+func isTlsError(err error) bool {
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var verifyErr *tls.CertificateVerificationError
+	var hostnameErr x509.HostnameError
+	var authorityErr x509.UnknownAuthorityError
+	var invalidErr x509.CertificateInvalidError
+	return errors.As(err, &recordErr) ||
+		errors.As(err, &alertErr) ||
+		errors.As(err, &verifyErr) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &authorityErr) ||
+		errors.As(err, &invalidErr)
+}
+
+// IpOf returns the IP address of a net.Addr, or an empty string if it
+// has none.
+func IpOf(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return ""
+	}
+	return host
+}
@@ -28,6 +28,16 @@ const (
 	ForwardInternal            ForwardErrorCode = "INTERNAL"               // Internal system error
 	ForwardFailedToResolveHost ForwardErrorCode = "FAILED_TO_RESOLVE_HOST" // DNS resolution failed
 	ForwardHostUnreachable     ForwardErrorCode = "HOST_UNREACHABLE"       // Target host is unreachable
+	ForwardConnectionRefused   ForwardErrorCode = "CONNECTION_REFUSED"     // Target host refused the connection
+	ForwardTimeout             ForwardErrorCode = "TIMEOUT"                // Dialing the target timed out
+	ForwardNetworkUnreachable  ForwardErrorCode = "NETWORK_UNREACHABLE"    // No route to the target network
+	ForwardTlsFailure          ForwardErrorCode = "TLS_FAILURE"            // TLS handshake with the target failed
+	ForwardProbeUnavailable    ForwardErrorCode = "PROBE_UNAVAILABLE"      // Probe is unreachable or rejected the request
+	ForwardCooldown            ForwardErrorCode = "COOLDOWN"               // Every probe is in cooldown for the target host
+	ForwardRateLimited         ForwardErrorCode = "RATE_LIMITED"           // Rate limit of the target host exceeded
+	ForwardProbesBusy          ForwardErrorCode = "PROBES_BUSY"            // Every probe is at its connection limit
+	ForwardRejected            ForwardErrorCode = "REJECTED"               // The hub's configuration does not allow the connection
+	ForwardBlockedByPolicy     ForwardErrorCode = "BLOCKED_BY_POLICY"      // The probe's policy does not allow the target address
 )
 
 // Conn represents a network connection with additional metadata.
@@ -38,6 +48,22 @@ type Conn interface {
 	Name() string // Returns a human-readable name for logging/debugging
 }
 
+// EgressConn is a connection that knows the public IP address
+// its traffic egresses from.
+type EgressConn interface {
+	Conn
+	EgressIp() string // Returns the public IP address, or an empty string if unknown
+}
+
+// EgressIpSource provides the public IP address that outbound connections
+// egress from.
+type EgressIpSource interface {
+	// EgressIp returns the public IP address, or an empty string if it is not
+	// known (yet). The address may be discovered using echoURL, the URL of a
+	// service responding with the caller's IP address.
+	EgressIp(echoURL string) string
+}
+
 // Dialer provides the ability to establish connections to remote addresses.
 type Dialer interface {
 	// Dial establishes a connection to the specified address.
@@ -45,14 +71,21 @@ type Dialer interface {
 	Dial(ctx context.Context, address string) (Conn, error)
 }
 
+// Pinger provides the ability to check the health of a remote peer.
+type Pinger interface {
+	// Ping returns an error if the peer is unreachable or unhealthy.
+	Ping(ctx context.Context) error
+}
+
 // Forwarder provides the ability to forward connections to target addresses.
 // The accept function is called to establish the client connection after
-// the target connection has been successfully established.
+// the target connection has been successfully established, with info about
+// how it was dialed. Dial errors may carry such info too, see DialInfoOf.
 type Forwarder interface {
 	Forward(
 		ctx context.Context,
 		targetAddress string,
-		accept func() (Conn, error),
+		accept func(info DialInfo) (Conn, error),
 	) error
 }
 
@@ -20,6 +20,15 @@ func LoggerFromConfig(
 	level string,
 	format string,
 ) (*slog.Logger, error) {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	return NewLogger(lvl, format)
+}
+
+// ParseLevel parses a log level name: debug, info, warn or error.
+func ParseLevel(level string) (slog.Level, error) {
 	lvl, ok := map[string]slog.Level{
 		"debug": slog.LevelDebug,
 		"info":  slog.LevelInfo,
@@ -27,15 +36,24 @@ func LoggerFromConfig(
 		"error": slog.LevelError,
 	}[level]
 	if !ok {
-		return nil, fmt.Errorf("invalid log level: %s", level)
+		return 0, fmt.Errorf("invalid log level: %s", level)
 	}
+	return lvl, nil
+}
 
+// NewLogger creates a slog.Logger with the provided level and format.
+// Passing a *slog.LevelVar allows the level to be changed after creation.
+// The logger includes tracing integration for request correlation.
+func NewLogger(
+	level slog.Leveler,
+	format string,
+) (*slog.Logger, error) {
 	var handler slog.Handler
 	switch format {
 	case "json":
-		handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: lvl})
+		handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level})
 	case "text":
-		handler = slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: lvl})
+		handler = slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level})
 	default:
 		return nil, fmt.Errorf("invalid log format: %s", format)
 	}
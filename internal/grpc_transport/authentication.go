@@ -25,36 +25,56 @@ func NewServerInterceptor(
 		// TODO:
 		// Better logging.
 		// Extract peer information with peer.FromContext or from the "x-forwarded-for", depending on deployment.
-		ctx := ss.Context()
-		logger.LogAttrs(
-			ctx,
-			slog.LevelDebug,
-			"authenticating incoming request",
-		)
-		md, ok := metadata.FromIncomingContext(ctx)
-		if !ok {
-			return status.Error(codes.Unauthenticated, "missing metadata")
-		}
-		header, ok := md["authorization"]
-		if !ok {
-			return status.Error(codes.Unauthenticated, "missing authorization header")
+		if err := authenticate(ss.Context(), logger, secret); err != nil {
+			return err
 		}
+		return handler(srv, ss)
+	}
+}
 
-		if len(header) == 0 {
-			return status.Error(codes.Unauthenticated, "missing authorization token")
+// NewUnaryServerInterceptor creates the unary counterpart of NewServerInterceptor,
+// validating the same shared secret for unary calls such as health checks.
+func NewUnaryServerInterceptor(
+	logger *slog.Logger,
+	secret string,
+) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := authenticate(ctx, logger, secret); err != nil {
+			return nil, err
 		}
+		return handler(ctx, req)
+	}
+}
 
-		bearer, token, found := strings.Cut(header[0], " ")
-		if !found || strings.ToLower(bearer) != "bearer" {
-			return status.Error(codes.Unauthenticated, "invalid authorization header")
-		}
+// authenticate verifies the bearer token in the incoming metadata of ctx.
+func authenticate(ctx context.Context, logger *slog.Logger, secret string) error {
+	logger.LogAttrs(
+		ctx,
+		slog.LevelDebug,
+		"authenticating incoming request",
+	)
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "missing metadata")
+	}
+	header, ok := md["authorization"]
+	if !ok {
+		return status.Error(codes.Unauthenticated, "missing authorization header")
+	}
 
-		if token != secret {
-			return status.Error(codes.Unauthenticated, "invalid token")
-		}
+	if len(header) == 0 {
+		return status.Error(codes.Unauthenticated, "missing authorization token")
+	}
 
-		return handler(srv, ss)
+	bearer, token, found := strings.Cut(header[0], " ")
+	if !found || strings.ToLower(bearer) != "bearer" {
+		return status.Error(codes.Unauthenticated, "invalid authorization header")
+	}
+
+	if token != secret {
+		return status.Error(codes.Unauthenticated, "invalid token")
 	}
+	return nil
 }
 
 // NewClientInterceptor creates a gRPC client interceptor that automatically
@@ -78,3 +98,21 @@ func NewClientInterceptor(
 		return streamer(ctx, desc, cc, method, opts...)
 	}
 }
+
+// NewUnaryClientInterceptor creates the unary counterpart of NewClientInterceptor.
+func NewUnaryClientInterceptor(
+	token string,
+) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req any,
+		reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "bearer "+token)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
@@ -19,12 +19,14 @@ const defaultReadFromBufSize = 32 * 1024
 // It provides a connection-like interface over gRPC streaming, with
 // buffering for efficient data transfer operations.
 type grpcConn struct {
-	stream          bidiStream // Underlying gRPC stream
-	closed          bool       // Connection state
-	buf             []byte     // Internal buffer for reads
-	offset          int        // Current buffer offset
-	readFromBufSize uint       // Buffer size for ReadFrom operations
-	name            string     // Connection name for logging
+	stream          bidiStream      // Underlying gRPC stream
+	closed          bool            // Connection state
+	buf             []byte          // Internal buffer for reads
+	offset          int             // Current buffer offset
+	readFromBufSize uint            // Buffer size for ReadFrom operations
+	name            string          // Connection name for logging
+	egressIp        string          // Public IP address of the probe, empty if unknown
+	dialInfo        common.DialInfo // How the probe dialed the target, on client connections
 }
 
 // newClientConn creates a new client-side gRPC connection wrapper.
@@ -34,7 +36,7 @@ func newClientConn(
 	stream grpc.BidiStreamingClient[forward_pb.ForwardRequest, forward_pb.ForwardResponse],
 	name string,
 	readFromBufSize uint,
-) common.Conn {
+) *grpcConn {
 	return &grpcConn{
 		stream:          &bidiClientStream{stream: stream},
 		closed:          false,
@@ -182,3 +184,15 @@ func (conn *grpcConn) Close() error {
 func (conn *grpcConn) Name() string {
 	return conn.name
 }
+
+// EgressIp returns the public IP address of the probe at the other end of a
+// client connection, or an empty string if the probe did not report it.
+func (conn *grpcConn) EgressIp() string {
+	return conn.egressIp
+}
+
+// DialInfo returns how the probe at the other end of a client connection
+// dialed the target. Probes that predate it report no info.
+func (conn *grpcConn) DialInfo() common.DialInfo {
+	return conn.dialInfo
+}
@@ -17,33 +17,54 @@ package grpc_transport
 import (
 	"context"
 	"fmt"
+	"io"
+	"time"
 
 	forward_pb "github.com/isacskoglund/rotox/gen/go/forward/v1"
 	"github.com/isacskoglund/rotox/internal/common"
 	"github.com/isacskoglund/rotox/internal/fault"
 	"github.com/isacskoglund/rotox/internal/tracing"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 // traceKey is the metadata key used for propagating trace IDs in gRPC calls.
 const traceKey = "trace_id"
 
-// forwardClient implements common.Dialer using gRPC to communicate with probes.
+// forwardClient implements common.Dialer and common.Pinger using gRPC to communicate with probes.
 // It establishes connections through the ForwardService gRPC service.
 type forwardClient struct {
 	client              forward_pb.ForwardServiceClient // gRPC client for probe communication
 	connReadFromBufSize uint                            // Buffer size for connection reads
+	ipEchoUrl           string                          // Echo URL sent to probes for egress IP discovery, optional
+}
+
+// ForwardClientOption configures a dialer created by NewForwardClient.
+type ForwardClientOption func(*forwardClient)
+
+// WithIpEchoUrl sets the URL of an echo service that probes may use to
+// discover their egress IP, which they report in dial responses.
+func WithIpEchoUrl(url string) ForwardClientOption {
+	return func(client *forwardClient) {
+		client.ipEchoUrl = url
+	}
 }
 
 // NewForwardClient creates a new gRPC-based dialer for connecting to probes.
 // The client should be configured with appropriate authentication and transport settings.
 func NewForwardClient(
 	client forward_pb.ForwardServiceClient,
+	opts ...ForwardClientOption,
 ) common.Dialer {
-	return &forwardClient{
+	dialer := &forwardClient{
 		client:              client,
 		connReadFromBufSize: defaultReadFromBufSize,
 	}
+	for _, opt := range opts {
+		opt(dialer)
+	}
+	return dialer
 }
 
 // Dial establishes a connection to the specified address through a probe.
@@ -58,36 +79,94 @@ func (dialer *forwardClient) Dial(ctx context.Context, address string) (common.C
 		),
 	)
 	if err != nil {
-		return nil, err
+		return nil, interpretTransportError(err)
 	}
 	err = stream.Send(&forward_pb.ForwardRequest{
 		Request: &forward_pb.ForwardRequest_DialRequest{
 			DialRequest: &forward_pb.DialRequest{
 				Destination: address,
+				IpEchoUrl:   dialer.ipEchoUrl,
 			},
 		},
 	})
+	if err == io.EOF {
+		// The stream was aborted by the server, the status is found by receiving.
+		_, err = stream.Recv()
+	}
 	if err != nil {
-		return nil, err
+		return nil, interpretTransportError(err)
 	}
 
 	msg, err := stream.Recv()
 	if err != nil {
-		return nil, err
+		return nil, interpretTransportError(err)
 	}
 	dialResponse := msg.GetDialResponse()
 	if dialResponse == nil {
 		return nil, fmt.Errorf("dial response was nil")
 	}
+	info := common.DialInfo{
+		RemoteIp:     dialResponse.RemoteIp,
+		LocalAddress: dialResponse.LocalAddress,
+		Duration:     time.Duration(dialResponse.DialDuration),
+	}
+	var dialErr error
 	switch dialResponse.Code {
 	case forward_pb.DialResponse_CODE_UNSPECIFIED:
-		return newClientConn(stream, "target", dialer.connReadFromBufSize), nil
+		conn := newClientConn(stream, "target", dialer.connReadFromBufSize)
+		conn.egressIp = dialResponse.EgressIp
+		conn.dialInfo = info
+		return conn, nil
 	case forward_pb.DialResponse_CODE_FAILED_TO_RESOLVE_HOST:
-		return nil, fault.New("failed to resolve host", common.ForwardFailedToResolveHost)
+		dialErr = fault.New("failed to resolve host", common.ForwardFailedToResolveHost)
 	case forward_pb.DialResponse_CODE_HOST_UNREACHABLE:
-		return nil, fault.New("host unreachable", common.ForwardHostUnreachable)
+		dialErr = fault.New("host unreachable", common.ForwardHostUnreachable)
+	case forward_pb.DialResponse_CODE_BLOCKED_BY_POLICY:
+		dialErr = fault.New("target address blocked by the probe's policy", common.ForwardBlockedByPolicy)
+	case forward_pb.DialResponse_CODE_CONNECTION_REFUSED:
+		dialErr = fault.New("connection refused", common.ForwardConnectionRefused)
+	case forward_pb.DialResponse_CODE_TIMEOUT:
+		dialErr = fault.New("dial timed out", common.ForwardTimeout)
+	case forward_pb.DialResponse_CODE_NETWORK_UNREACHABLE:
+		dialErr = fault.New("network unreachable", common.ForwardNetworkUnreachable)
+	case forward_pb.DialResponse_CODE_TLS_FAILURE:
+		dialErr = fault.New("tls handshake failed", common.ForwardTlsFailure)
+	default:
+		// Sent by a newer probe.
+		dialErr = fault.New(fmt.Sprintf("unknown dial response code %d", dialResponse.Code), common.ForwardUnknown)
+	}
+	return nil, common.WithDialInfo(dialErr, info)
+}
+
+// Ping checks that the probe is reachable and accepts the hub's credentials.
+// Probes that predate the Ping RPC are considered healthy if reachable.
+func (dialer *forwardClient) Ping(ctx context.Context) error {
+	_, err := dialer.client.Ping(
+		metadata.AppendToOutgoingContext(
+			ctx,
+			traceKey,
+			tracing.GetTraceId(ctx),
+		),
+		&forward_pb.PingRequest{},
+	)
+	if status.Code(err) == codes.Unimplemented {
+		return nil
+	}
+	return err
+}
+
+// interpretTransportError marks errors showing that the probe itself could not
+// be used, as opposed to errors reported by the probe about the target.
+func interpretTransportError(err error) error {
+	switch status.Code(err) {
+	case codes.Unavailable,
+		codes.Unauthenticated,
+		codes.PermissionDenied,
+		codes.ResourceExhausted,
+		codes.Internal:
+		return fault.Wrap(err, "probe unavailable", common.ForwardProbeUnavailable)
 	}
-	panic("unreachable")
+	return err
 }
 
 func (client *forwardClient) SetReadFromBufSize(size uint) {
@@ -7,6 +7,7 @@ import (
 	"io"
 	"strings"
 	"testing"
+	"time"
 
 	forward_pb "github.com/isacskoglund/rotox/gen/go/forward/v1"
 	"github.com/isacskoglund/rotox/internal/common"
@@ -454,12 +455,112 @@ func TestForwardClient_Dial_ErrorCodes(t *testing.T) {
 			expectErr:     true,
 			expectErrCode: common.ForwardHostUnreachable,
 		},
+		{
+			name:   "connection refused",
+			target: "target.com:80",
+			dialResp: &forward_pb.ForwardResponse{
+				Response: &forward_pb.ForwardResponse_DialResponse{
+					DialResponse: &forward_pb.DialResponse{
+						Code: forward_pb.DialResponse_CODE_CONNECTION_REFUSED,
+					},
+				},
+			},
+			recvErr:       nil,
+			expectErr:     true,
+			expectErrCode: common.ForwardConnectionRefused,
+		},
+		{
+			name:   "timeout",
+			target: "target.com:80",
+			dialResp: &forward_pb.ForwardResponse{
+				Response: &forward_pb.ForwardResponse_DialResponse{
+					DialResponse: &forward_pb.DialResponse{
+						Code: forward_pb.DialResponse_CODE_TIMEOUT,
+					},
+				},
+			},
+			recvErr:       nil,
+			expectErr:     true,
+			expectErrCode: common.ForwardTimeout,
+		},
+		{
+			name:   "network unreachable",
+			target: "target.com:80",
+			dialResp: &forward_pb.ForwardResponse{
+				Response: &forward_pb.ForwardResponse_DialResponse{
+					DialResponse: &forward_pb.DialResponse{
+						Code: forward_pb.DialResponse_CODE_NETWORK_UNREACHABLE,
+					},
+				},
+			},
+			recvErr:       nil,
+			expectErr:     true,
+			expectErrCode: common.ForwardNetworkUnreachable,
+		},
+		{
+			name:   "blocked by policy",
+			target: "target.com:80",
+			dialResp: &forward_pb.ForwardResponse{
+				Response: &forward_pb.ForwardResponse_DialResponse{
+					DialResponse: &forward_pb.DialResponse{
+						Code: forward_pb.DialResponse_CODE_BLOCKED_BY_POLICY,
+					},
+				},
+			},
+			recvErr:       nil,
+			expectErr:     true,
+			expectErrCode: common.ForwardBlockedByPolicy,
+		},
+		{
+			name:   "tls failure",
+			target: "target.com:80",
+			dialResp: &forward_pb.ForwardResponse{
+				Response: &forward_pb.ForwardResponse_DialResponse{
+					DialResponse: &forward_pb.DialResponse{
+						Code: forward_pb.DialResponse_CODE_TLS_FAILURE,
+					},
+				},
+			},
+			recvErr:       nil,
+			expectErr:     true,
+			expectErrCode: common.ForwardTlsFailure,
+		},
+		{
+			name:   "unknown code from a newer probe",
+			target: "target.com:80",
+			dialResp: &forward_pb.ForwardResponse{
+				Response: &forward_pb.ForwardResponse_DialResponse{
+					DialResponse: &forward_pb.DialResponse{
+						Code: forward_pb.DialResponse_Code(99),
+					},
+				},
+			},
+			recvErr:       nil,
+			expectErr:     true,
+			expectErrCode: common.ForwardUnknown,
+		},
 		{
 			name:          "grpc error (Unavailable)",
 			target:        "grpcerror.com:80",
 			dialResp:      nil,
 			recvErr:       status.Error(codes.Unavailable, "unavailable"),
 			expectErr:     true,
+			expectErrCode: common.ForwardProbeUnavailable,
+		},
+		{
+			name:          "grpc error (Unauthenticated)",
+			target:        "grpcerror.com:80",
+			dialResp:      nil,
+			recvErr:       status.Error(codes.Unauthenticated, "unauthenticated"),
+			expectErr:     true,
+			expectErrCode: common.ForwardProbeUnavailable,
+		},
+		{
+			name:          "grpc error (Unknown)",
+			target:        "grpcerror.com:80",
+			dialResp:      nil,
+			recvErr:       status.Error(codes.Unknown, "unknown"),
+			expectErr:     true,
 			expectErrCode: common.ForwardUnknown,
 		},
 	}
@@ -499,3 +600,61 @@ func TestForwardClient_Dial_ErrorCodes(t *testing.T) {
 		})
 	}
 }
+
+func TestForwardClient_Dial_DialInfo(t *testing.T) {
+	ctx := context.Background()
+	info := common.DialInfo{
+		RemoteIp:     "93.184.216.34",
+		LocalAddress: "10.0.0.2:40000",
+		Duration:     25 * time.Millisecond,
+	}
+	for _, code := range []forward_pb.DialResponse_Code{
+		forward_pb.DialResponse_CODE_UNSPECIFIED,
+		forward_pb.DialResponse_CODE_CONNECTION_REFUSED,
+	} {
+		mockClient := newMockForwardServiceClient()
+		mockStream := newMockForwardBidiStreamingClient()
+		client := grpc_transport.NewForwardClient(mockClient)
+		mockClient.onForward(nil, mockStream, nil).Once()
+		mockStream.On("Send", mock.Anything).Return(nil)
+		mockStream.On("Recv").Once().Return(&forward_pb.ForwardResponse{
+			Response: &forward_pb.ForwardResponse_DialResponse{
+				DialResponse: &forward_pb.DialResponse{
+					Code:         code,
+					RemoteIp:     info.RemoteIp,
+					LocalAddress: info.LocalAddress,
+					DialDuration: uint64(info.Duration),
+				},
+			},
+		}, nil)
+
+		conn, err := client.Dial(ctx, "target.com:443")
+		if code == forward_pb.DialResponse_CODE_UNSPECIFIED {
+			assert.NoError(t, err)
+			assert.Equal(t, info, conn.(common.DialInfoConn).DialInfo())
+		} else {
+			got, ok := common.DialInfoOf(err)
+			assert.True(t, ok)
+			assert.Equal(t, info, got)
+		}
+	}
+}
+
+func TestForwardClient_Dial_IpEchoUrl(t *testing.T) {
+	mockClient := newMockForwardServiceClient()
+	mockStream := newMockForwardBidiStreamingClient()
+	client := grpc_transport.NewForwardClient(mockClient, grpc_transport.WithIpEchoUrl("https://echo.example.com"))
+	mockClient.onForward(nil, mockStream, nil).Once()
+	mockStream.On("Send", mock.MatchedBy(func(req *forward_pb.ForwardRequest) bool {
+		return req.GetDialRequest().GetIpEchoUrl() == "https://echo.example.com"
+	})).Return(nil)
+	mockStream.On("Recv").Once().Return(&forward_pb.ForwardResponse{
+		Response: &forward_pb.ForwardResponse_DialResponse{
+			DialResponse: &forward_pb.DialResponse{},
+		},
+	}, nil)
+
+	_, err := client.Dial(context.Background(), "target.com:443")
+	assert.NoError(t, err)
+	mockStream.Mock.AssertExpectations(t)
+}
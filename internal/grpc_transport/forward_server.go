@@ -1,6 +1,7 @@
 package grpc_transport
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 
@@ -19,9 +20,10 @@ import (
 // underlying forwarder implementation (typically a probe service).
 type ForwardServer struct {
 	forward_pb.UnimplementedForwardServiceServer
-	logger              *slog.Logger     // Logger for server operations
-	svc                 common.Forwarder // Underlying forwarding service
-	connReadFromBufSize uint             // Buffer size for connection operations
+	logger              *slog.Logger          // Logger for server operations
+	svc                 common.Forwarder      // Underlying forwarding service
+	connReadFromBufSize uint                  // Buffer size for connection operations
+	egress              common.EgressIpSource // Reports the egress IP in dial responses, optional
 }
 
 // NewForwardServer creates a new gRPC forward server that wraps the provided
@@ -80,11 +82,19 @@ func (srv *ForwardServer) Forward(stream grpc.BidiStreamingServer[forward_pb.For
 		)
 	}
 
-	accept := func() (common.Conn, error) {
+	accept := func(info common.DialInfo) (common.Conn, error) {
+		var egressIp string
+		if srv.egress != nil {
+			egressIp = srv.egress.EgressIp(dialRequest.IpEchoUrl)
+		}
 		err := stream.Send(&forward_pb.ForwardResponse{
 			Response: &forward_pb.ForwardResponse_DialResponse{
 				DialResponse: &forward_pb.DialResponse{
-					Code: forward_pb.DialResponse_CODE_UNSPECIFIED,
+					Code:         forward_pb.DialResponse_CODE_UNSPECIFIED,
+					EgressIp:     egressIp,
+					RemoteIp:     info.RemoteIp,
+					LocalAddress: info.LocalAddress,
+					DialDuration: uint64(info.Duration),
 				},
 			},
 		})
@@ -111,11 +121,27 @@ func (srv *ForwardServer) Forward(stream grpc.BidiStreamingServer[forward_pb.For
 		pbCode = forward_pb.DialResponse_CODE_FAILED_TO_RESOLVE_HOST
 	case common.ForwardHostUnreachable:
 		pbCode = forward_pb.DialResponse_CODE_HOST_UNREACHABLE
+	case common.ForwardBlockedByPolicy:
+		pbCode = forward_pb.DialResponse_CODE_BLOCKED_BY_POLICY
+	case common.ForwardConnectionRefused:
+		pbCode = forward_pb.DialResponse_CODE_CONNECTION_REFUSED
+	case common.ForwardTimeout:
+		pbCode = forward_pb.DialResponse_CODE_TIMEOUT
+	case common.ForwardNetworkUnreachable:
+		pbCode = forward_pb.DialResponse_CODE_NETWORK_UNREACHABLE
+	case common.ForwardTlsFailure:
+		pbCode = forward_pb.DialResponse_CODE_TLS_FAILURE
+	default:
+		// The unspecified code would report success.
+		return status.Error(codes.Unknown, "")
 	}
+	info, _ := common.DialInfoOf(err)
 	err = stream.Send(&forward_pb.ForwardResponse{
 		Response: &forward_pb.ForwardResponse_DialResponse{
 			DialResponse: &forward_pb.DialResponse{
-				Code: pbCode,
+				Code:         pbCode,
+				RemoteIp:     info.RemoteIp,
+				DialDuration: uint64(info.Duration),
 			},
 		},
 	})
@@ -131,7 +157,18 @@ func (srv *ForwardServer) Forward(stream grpc.BidiStreamingServer[forward_pb.For
 	return nil
 }
 
+// Ping responds to health checks from the hub.
+func (srv *ForwardServer) Ping(ctx context.Context, req *forward_pb.PingRequest) (*forward_pb.PingResponse, error) {
+	return &forward_pb.PingResponse{}, nil
+}
+
 // Config
 func (srv *ForwardServer) SetReadFromBufSize(size uint) {
 	srv.connReadFromBufSize = size
 }
+
+// SetEgressIpSource enables reporting the probe's egress IP to the hub
+// in dial responses.
+func (srv *ForwardServer) SetEgressIpSource(egress common.EgressIpSource) {
+	srv.egress = egress
+}
@@ -175,8 +175,8 @@ func TestForwardServer_ReadAndWriteTo(t *testing.T) {
 						io.EOF,
 					).Once()
 					mockForwarder.On("Forward", mock.Anything, c.target, mock.Anything).Run(func(args mock.Arguments) {
-						accept := args.Get(2).(func() (common.Conn, error))
-						conn, err := accept()
+						accept := args.Get(2).(func(common.DialInfo) (common.Conn, error))
+						conn, err := accept(common.DialInfo{})
 						assert.NoError(t, err)
 						connTest.read(t, conn, c.messageParts)
 					}).Return(nil).Once()
@@ -340,8 +340,8 @@ func TestForwardServer_WriteAndReadFrom(t *testing.T) {
 						},
 					).Return(nil)
 					mockForwarder.On("Forward", mock.Anything, c.target, mock.Anything).Run(func(args mock.Arguments) {
-						accept := args.Get(2).(func() (common.Conn, error))
-						conn, err := accept()
+						accept := args.Get(2).(func(common.DialInfo) (common.Conn, error))
+						conn, err := accept(common.DialInfo{})
 						assert.NoError(t, err)
 						connTest.write(t, conn, c.messageParts)
 					}).Return(nil).Once()
@@ -104,6 +104,11 @@ func (m *mockForwardServiceClient) Forward(ctx context.Context, opts ...grpc.Cal
 	return args.Get(0).(forward_pb.ForwardService_ForwardClient), args.Error(1)
 }
 
+func (m *mockForwardServiceClient) Ping(ctx context.Context, in *forward_pb.PingRequest, opts ...grpc.CallOption) (*forward_pb.PingResponse, error) {
+	args := m.Called(ctx, in, opts)
+	return args.Get(0).(*forward_pb.PingResponse), args.Error(1)
+}
+
 type mockForwarder struct {
 	mock.Mock
 }
@@ -115,7 +120,7 @@ func newMockForwarder() *mockForwarder {
 func (m *mockForwarder) Forward(
 	ctx context.Context,
 	targetAddress string,
-	accept func() (common.Conn, error),
+	accept func(info common.DialInfo) (common.Conn, error),
 ) error {
 	args := m.Called(ctx, targetAddress, accept)
 	return args.Error(0)
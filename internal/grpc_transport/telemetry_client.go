@@ -13,6 +13,9 @@ import (
 type TelemetryClient struct {
 	transferEvents   *grpcTransferSubscriber
 	connectionEvents *grpcConnectionSubscriber
+	probeEvents      *grpcProbeSubscriber
+	queueEvents      *grpcQueueSubscriber
+	policyEvents     *grpcPolicySubscriber
 }
 
 func NewTelemetryClient(
@@ -25,6 +28,15 @@ func NewTelemetryClient(
 		connectionEvents: &grpcConnectionSubscriber{
 			client: client,
 		},
+		probeEvents: &grpcProbeSubscriber{
+			client: client,
+		},
+		queueEvents: &grpcQueueSubscriber{
+			client: client,
+		},
+		policyEvents: &grpcPolicySubscriber{
+			client: client,
+		},
 	}
 }
 
@@ -34,6 +46,15 @@ func (client *TelemetryClient) TransferSubscriber() common.Subscriber[telemetry.
 func (client *TelemetryClient) ConnectionSubscriber() common.Subscriber[telemetry.ConnectionEvent] {
 	return client.connectionEvents
 }
+func (client *TelemetryClient) ProbeSubscriber() common.Subscriber[telemetry.ProbeEvent] {
+	return client.probeEvents
+}
+func (client *TelemetryClient) QueueSubscriber() common.Subscriber[telemetry.QueueEvent] {
+	return client.queueEvents
+}
+func (client *TelemetryClient) PolicySubscriber() common.Subscriber[telemetry.PolicyEvent] {
+	return client.policyEvents
+}
 
 type grpcTransferSubscriber struct {
 	client telemetry_pb.TelemetryServiceClient
@@ -82,6 +103,8 @@ func (s *grpcConnectionSubscriber) Subscribe(ctx context.Context) (common.Subscr
 				ConnectionId:  event.ConnectionId,
 				ClientAddress: event.ClientAddress,
 				TargetAddress: event.TargetAddress,
+				Probe:         event.Probe,
+				EgressIp:      event.EgressIp,
 				OpenedAt:      time.Unix(0, int64(event.OpenedAt)),
 				ClosedAt:      time.Unix(0, int64(event.ClosedAt)),
 			}
@@ -96,6 +119,133 @@ func (s *grpcConnectionSubscriber) Subscribe(ctx context.Context) (common.Subscr
 	}, nil
 }
 
+type grpcProbeSubscriber struct {
+	client telemetry_pb.TelemetryServiceClient
+}
+
+func (s *grpcProbeSubscriber) Subscribe(ctx context.Context) (common.Subscription[telemetry.ProbeEvent], error) {
+	stream, err := s.client.ProbeSubscribe(ctx, &telemetry_pb.ProbeSubscribeRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	convert := func(resp *telemetry_pb.ProbeSubscribeResponse) ([]telemetry.ProbeEvent, error) {
+		converted := make([]telemetry.ProbeEvent, len(resp.Events))
+		for i, event := range resp.Events {
+			converted[i] = telemetry.ProbeEvent{
+				Probe:     event.Probe,
+				State:     probeStateFromPb(event.State),
+				Reason:    event.Reason,
+				ChangedAt: time.Unix(0, int64(event.ChangedAt)),
+			}
+		}
+		return converted, nil
+	}
+
+	return &grpcServerStreamSubscription[telemetry_pb.ProbeSubscribeResponse, telemetry.ProbeEvent]{
+		stream:  stream,
+		cache:   make([]telemetry.ProbeEvent, 0),
+		convert: convert,
+	}, nil
+}
+
+func probeStateFromPb(state telemetry_pb.ProbeEvent_State) telemetry.ProbeState {
+	switch state {
+	case telemetry_pb.ProbeEvent_STATE_HEALTHY:
+		return telemetry.ProbeHealthy
+	case telemetry_pb.ProbeEvent_STATE_EJECTED:
+		return telemetry.ProbeEjected
+	}
+	return ""
+}
+
+type grpcQueueSubscriber struct {
+	client telemetry_pb.TelemetryServiceClient
+}
+
+func (s *grpcQueueSubscriber) Subscribe(ctx context.Context) (common.Subscription[telemetry.QueueEvent], error) {
+	stream, err := s.client.QueueSubscribe(ctx, &telemetry_pb.QueueSubscribeRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	convert := func(resp *telemetry_pb.QueueSubscribeResponse) ([]telemetry.QueueEvent, error) {
+		converted := make([]telemetry.QueueEvent, len(resp.Events))
+		for i, event := range resp.Events {
+			converted[i] = telemetry.QueueEvent{
+				TargetAddress: event.TargetAddress,
+				Outcome:       queueOutcomeFromPb(event.Outcome),
+				Depth:         int(event.Depth),
+				EnqueuedAt:    time.Unix(0, int64(event.EnqueuedAt)),
+				DequeuedAt:    time.Unix(0, int64(event.DequeuedAt)),
+			}
+		}
+		return converted, nil
+	}
+
+	return &grpcServerStreamSubscription[telemetry_pb.QueueSubscribeResponse, telemetry.QueueEvent]{
+		stream:  stream,
+		cache:   make([]telemetry.QueueEvent, 0),
+		convert: convert,
+	}, nil
+}
+
+func queueOutcomeFromPb(outcome telemetry_pb.QueueEvent_Outcome) telemetry.QueueOutcome {
+	switch outcome {
+	case telemetry_pb.QueueEvent_OUTCOME_SERVED:
+		return telemetry.QueueServed
+	case telemetry_pb.QueueEvent_OUTCOME_TIMED_OUT:
+		return telemetry.QueueTimedOut
+	case telemetry_pb.QueueEvent_OUTCOME_REJECTED:
+		return telemetry.QueueRejected
+	case telemetry_pb.QueueEvent_OUTCOME_CANCELED:
+		return telemetry.QueueCanceled
+	}
+	return ""
+}
+
+type grpcPolicySubscriber struct {
+	client telemetry_pb.TelemetryServiceClient
+}
+
+func (s *grpcPolicySubscriber) Subscribe(ctx context.Context) (common.Subscription[telemetry.PolicyEvent], error) {
+	stream, err := s.client.PolicySubscribe(ctx, &telemetry_pb.PolicySubscribeRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	convert := func(resp *telemetry_pb.PolicySubscribeResponse) ([]telemetry.PolicyEvent, error) {
+		converted := make([]telemetry.PolicyEvent, len(resp.Events))
+		for i, event := range resp.Events {
+			converted[i] = telemetry.PolicyEvent{
+				TargetAddress: event.TargetAddress,
+				Violation:     policyViolationFromPb(event.Violation),
+				Rule:          event.Rule,
+				DeniedAt:      time.Unix(0, int64(event.DeniedAt)),
+			}
+		}
+		return converted, nil
+	}
+
+	return &grpcServerStreamSubscription[telemetry_pb.PolicySubscribeResponse, telemetry.PolicyEvent]{
+		stream:  stream,
+		cache:   make([]telemetry.PolicyEvent, 0),
+		convert: convert,
+	}, nil
+}
+
+func policyViolationFromPb(violation telemetry_pb.PolicyEvent_Violation) telemetry.PolicyViolation {
+	switch violation {
+	case telemetry_pb.PolicyEvent_VIOLATION_PORT_NOT_ALLOWED:
+		return telemetry.PolicyPortNotAllowed
+	case telemetry_pb.PolicyEvent_VIOLATION_DOMAIN_DENIED:
+		return telemetry.PolicyDomainDenied
+	case telemetry_pb.PolicyEvent_VIOLATION_IP_RANGE_DENIED:
+		return telemetry.PolicyIpRangeDenied
+	}
+	return ""
+}
+
 // Generic subscription interface for gRPC server streaming
 type grpcServerStreamSubscription[M any, T any] struct {
 	stream  grpc.ServerStreamingClient[M]
@@ -16,6 +16,9 @@ type TelemetryServer struct {
 	logger           *slog.Logger
 	transferEvents   *broadcast.Broadcaster[telemetry.TransferEvent]
 	connectionEvents *broadcast.Broadcaster[telemetry.ConnectionEvent]
+	probeEvents      *broadcast.Broadcaster[telemetry.ProbeEvent]
+	queueEvents      *broadcast.Broadcaster[telemetry.QueueEvent]
+	policyEvents     *broadcast.Broadcaster[telemetry.PolicyEvent]
 }
 
 func NewTelemetryServer(
@@ -25,6 +28,9 @@ func NewTelemetryServer(
 		logger:           logger,
 		transferEvents:   broadcast.NewBroadcaster[telemetry.TransferEvent](),
 		connectionEvents: broadcast.NewBroadcaster[telemetry.ConnectionEvent](),
+		probeEvents:      broadcast.NewBroadcaster[telemetry.ProbeEvent](),
+		queueEvents:      broadcast.NewBroadcaster[telemetry.QueueEvent](),
+		policyEvents:     broadcast.NewBroadcaster[telemetry.PolicyEvent](),
 	}
 }
 
@@ -34,6 +40,18 @@ func (srv *TelemetryServer) StartBroadcasting(ctx context.Context) error {
 		return err
 	}
 	err = srv.connectionEvents.Start(ctx)
+	if err != nil {
+		return err
+	}
+	err = srv.probeEvents.Start(ctx)
+	if err != nil {
+		return err
+	}
+	err = srv.queueEvents.Start(ctx)
+	if err != nil {
+		return err
+	}
+	err = srv.policyEvents.Start(ctx)
 	return err
 }
 
@@ -45,6 +63,18 @@ func (srv *TelemetryServer) ConnectionPublisher() common.Publisher[telemetry.Con
 	return srv.connectionEvents
 }
 
+func (srv *TelemetryServer) ProbePublisher() common.Publisher[telemetry.ProbeEvent] {
+	return srv.probeEvents
+}
+
+func (srv *TelemetryServer) QueuePublisher() common.Publisher[telemetry.QueueEvent] {
+	return srv.queueEvents
+}
+
+func (srv *TelemetryServer) PolicyPublisher() common.Publisher[telemetry.PolicyEvent] {
+	return srv.policyEvents
+}
+
 func (srv *TelemetryServer) TransferSubscribe(req *telemetry_pb.TransferSubscribeRequest, stream grpc.ServerStreamingServer[telemetry_pb.TransferSubscribeResponse]) error {
 	ctx := stream.Context()
 	srv.logger.LogAttrs(
@@ -143,6 +173,8 @@ func (srv *TelemetryServer) ConnectionSubscribe(req *telemetry_pb.ConnectionSubs
 						ClosedAt:      uint64(event.ClosedAt.UnixNano()),
 						ClientAddress: event.ClientAddress,
 						TargetAddress: event.TargetAddress,
+						Probe:         event.Probe,
+						EgressIp:      event.EgressIp,
 					},
 				},
 			},
@@ -160,3 +192,205 @@ func (srv *TelemetryServer) ConnectionSubscribe(req *telemetry_pb.ConnectionSubs
 	}
 
 }
+
+func (srv *TelemetryServer) ProbeSubscribe(req *telemetry_pb.ProbeSubscribeRequest, stream grpc.ServerStreamingServer[telemetry_pb.ProbeSubscribeResponse]) error {
+	ctx := stream.Context()
+	srv.logger.LogAttrs(
+		ctx,
+		slog.LevelInfo,
+		"Handling probe subscribe request.",
+	)
+
+	sub, err := srv.probeEvents.Subscribe(ctx)
+	if err != nil {
+		srv.logger.LogAttrs(
+			ctx,
+			slog.LevelError,
+			"Failed to subscribe to probe events.",
+			slog.String("error", err.Error()),
+		)
+		return err
+	}
+	defer sub.Close()
+	for {
+		event, err := sub.Receive()
+		if err != nil {
+			srv.logger.LogAttrs(
+				ctx,
+				slog.LevelError,
+				"Failed to receive probe event.",
+				slog.String("error", err.Error()),
+			)
+			return err
+		}
+
+		err = stream.Send(
+			&telemetry_pb.ProbeSubscribeResponse{
+				Events: []*telemetry_pb.ProbeEvent{
+					{
+						Probe:     event.Probe,
+						State:     probeStateToPb(event.State),
+						Reason:    event.Reason,
+						ChangedAt: uint64(event.ChangedAt.UnixNano()),
+					},
+				},
+			},
+		)
+		if err != nil {
+			srv.logger.LogAttrs(
+				ctx,
+				slog.LevelError,
+				"Failed to send probe event.",
+				slog.String("error", err.Error()),
+			)
+			return err
+		}
+	}
+}
+
+func (srv *TelemetryServer) QueueSubscribe(req *telemetry_pb.QueueSubscribeRequest, stream grpc.ServerStreamingServer[telemetry_pb.QueueSubscribeResponse]) error {
+	ctx := stream.Context()
+	srv.logger.LogAttrs(
+		ctx,
+		slog.LevelInfo,
+		"Handling queue subscribe request.",
+	)
+
+	sub, err := srv.queueEvents.Subscribe(ctx)
+	if err != nil {
+		srv.logger.LogAttrs(
+			ctx,
+			slog.LevelError,
+			"Failed to subscribe to queue events.",
+			slog.String("error", err.Error()),
+		)
+		return err
+	}
+	defer sub.Close()
+	for {
+		event, err := sub.Receive()
+		if err != nil {
+			srv.logger.LogAttrs(
+				ctx,
+				slog.LevelError,
+				"Failed to receive queue event.",
+				slog.String("error", err.Error()),
+			)
+			return err
+		}
+
+		err = stream.Send(
+			&telemetry_pb.QueueSubscribeResponse{
+				Events: []*telemetry_pb.QueueEvent{
+					{
+						TargetAddress: event.TargetAddress,
+						Outcome:       queueOutcomeToPb(event.Outcome),
+						Depth:         uint64(event.Depth),
+						EnqueuedAt:    uint64(event.EnqueuedAt.UnixNano()),
+						DequeuedAt:    uint64(event.DequeuedAt.UnixNano()),
+					},
+				},
+			},
+		)
+		if err != nil {
+			srv.logger.LogAttrs(
+				ctx,
+				slog.LevelError,
+				"Failed to send queue event.",
+				slog.String("error", err.Error()),
+			)
+			return err
+		}
+	}
+}
+
+func (srv *TelemetryServer) PolicySubscribe(req *telemetry_pb.PolicySubscribeRequest, stream grpc.ServerStreamingServer[telemetry_pb.PolicySubscribeResponse]) error {
+	ctx := stream.Context()
+	srv.logger.LogAttrs(
+		ctx,
+		slog.LevelInfo,
+		"Handling policy subscribe request.",
+	)
+
+	sub, err := srv.policyEvents.Subscribe(ctx)
+	if err != nil {
+		srv.logger.LogAttrs(
+			ctx,
+			slog.LevelError,
+			"Failed to subscribe to policy events.",
+			slog.String("error", err.Error()),
+		)
+		return err
+	}
+	defer sub.Close()
+	for {
+		event, err := sub.Receive()
+		if err != nil {
+			srv.logger.LogAttrs(
+				ctx,
+				slog.LevelError,
+				"Failed to receive policy event.",
+				slog.String("error", err.Error()),
+			)
+			return err
+		}
+
+		err = stream.Send(
+			&telemetry_pb.PolicySubscribeResponse{
+				Events: []*telemetry_pb.PolicyEvent{
+					{
+						TargetAddress: event.TargetAddress,
+						Violation:     policyViolationToPb(event.Violation),
+						Rule:          event.Rule,
+						DeniedAt:      uint64(event.DeniedAt.UnixNano()),
+					},
+				},
+			},
+		)
+		if err != nil {
+			srv.logger.LogAttrs(
+				ctx,
+				slog.LevelError,
+				"Failed to send policy event.",
+				slog.String("error", err.Error()),
+			)
+			return err
+		}
+	}
+}
+
+func probeStateToPb(state telemetry.ProbeState) telemetry_pb.ProbeEvent_State {
+	switch state {
+	case telemetry.ProbeHealthy:
+		return telemetry_pb.ProbeEvent_STATE_HEALTHY
+	case telemetry.ProbeEjected:
+		return telemetry_pb.ProbeEvent_STATE_EJECTED
+	}
+	return telemetry_pb.ProbeEvent_STATE_UNSPECIFIED
+}
+
+func queueOutcomeToPb(outcome telemetry.QueueOutcome) telemetry_pb.QueueEvent_Outcome {
+	switch outcome {
+	case telemetry.QueueServed:
+		return telemetry_pb.QueueEvent_OUTCOME_SERVED
+	case telemetry.QueueTimedOut:
+		return telemetry_pb.QueueEvent_OUTCOME_TIMED_OUT
+	case telemetry.QueueRejected:
+		return telemetry_pb.QueueEvent_OUTCOME_REJECTED
+	case telemetry.QueueCanceled:
+		return telemetry_pb.QueueEvent_OUTCOME_CANCELED
+	}
+	return telemetry_pb.QueueEvent_OUTCOME_UNSPECIFIED
+}
+
+func policyViolationToPb(violation telemetry.PolicyViolation) telemetry_pb.PolicyEvent_Violation {
+	switch violation {
+	case telemetry.PolicyPortNotAllowed:
+		return telemetry_pb.PolicyEvent_VIOLATION_PORT_NOT_ALLOWED
+	case telemetry.PolicyDomainDenied:
+		return telemetry_pb.PolicyEvent_VIOLATION_DOMAIN_DENIED
+	case telemetry.PolicyIpRangeDenied:
+		return telemetry_pb.PolicyEvent_VIOLATION_IP_RANGE_DENIED
+	}
+	return telemetry_pb.PolicyEvent_VIOLATION_UNSPECIFIED
+}
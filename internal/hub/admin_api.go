@@ -0,0 +1,277 @@
+package hub
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/isacskoglund/rotox/internal/fault"
+)
+
+// States of a probe reported by the admin API.
+const (
+	probeStateHealthy  = "healthy"
+	probeStateEjected  = "ejected"
+	probeStateDraining = "draining"
+)
+
+// AddProbeRequest is the body of a request to add a probe through the admin API.
+// The probe has a single gRPC connection to its host, named by the host; hosts
+// with several connections are only set up through the config file.
+type AddProbeRequest struct {
+	Host       string `json:"host"`        // Address of the probe
+	SecretEnv  string `json:"secret_env"`  // Secret env of a configured probe group, holding the probe secret, optional
+	RequireTls bool   `json:"require_tls"` // Whether TLS is required for the connection
+	Weight     int    `json:"weight"`      // Relative weight used by the weighted strategy, optional
+
+	MaxConcurrentConnections int               `json:"max_concurrent_connections"` // Connection limit of the probe, optional
+	Group                    string            `json:"group"`                      // Name of the group the probe belongs to, optional
+	Labels                   map[string]string `json:"labels"`                     // Labels matched by label selectors, optional
+}
+
+// ProbeManager adds probes to and removes probes from the pool on behalf of
+// the admin API. It owns the dialers of the probes, creating them when probes
+// are added and releasing them once removed probes are idle.
+type ProbeManager interface {
+	AddProbe(req AddProbeRequest) (*Probe, error)
+	RemoveProbe(name string) error
+}
+
+// AdminApi implements an HTTP API for inspecting and managing the pool of
+// probes at runtime. Requests must carry the admin secret as a bearer token.
+//
+// Probe names are the probe hosts, and must be URL-encoded in paths
+// (e.g. "https:%2F%2Fprobe.example.com").
+type AdminApi struct {
+	logger  *slog.Logger   // Logger for admin API operations
+	core    *Core          // Core hub service owning the pool of probes
+	secret  string         // Secret expected as bearer token
+	manager ProbeManager   // Adds and removes probes, nil disables adding and removing
+	mux     *http.ServeMux // Routes requests to the handlers
+}
+
+// NewAdminApi creates a new admin API for the pool of probes of core.
+func NewAdminApi(
+	logger *slog.Logger,
+	core *Core,
+	secret string,
+) *AdminApi {
+	api := &AdminApi{
+		logger: logger,
+		core:   core,
+		secret: secret,
+		mux:    http.NewServeMux(),
+	}
+	api.mux.HandleFunc("GET /probes", api.listProbes)
+	api.mux.HandleFunc("POST /probes", api.addProbe)
+	api.mux.HandleFunc("DELETE /probes/{name}", api.removeProbe)
+	api.mux.HandleFunc("POST /probes/{name}/drain", api.drainProbe)
+	api.mux.HandleFunc("POST /probes/{name}/enable", api.enableProbe)
+	api.mux.HandleFunc("GET /egress-ips", api.listEgressIps)
+	return api
+}
+
+// SetProbeManager enables adding and removing probes through the admin API.
+func (api *AdminApi) SetProbeManager(manager ProbeManager) {
+	api.manager = manager
+}
+
+func (api *AdminApi) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(api.secret)) != 1 {
+		api.logger.LogAttrs(
+			req.Context(),
+			slog.LevelInfo,
+			"Admin client failed authentication.",
+			slog.String("client", req.RemoteAddr),
+		)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="rotox"`)
+		writeJsonError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+		return
+	}
+	api.mux.ServeHTTP(w, req)
+}
+
+// probeView is the representation of a probe in admin API responses.
+type probeView struct {
+	Name              string            `json:"name"`
+	Weight            int               `json:"weight"`
+	Group             string            `json:"group,omitempty"`
+	Labels            map[string]string `json:"labels"`
+	State             string            `json:"state"`
+	Connectivity      string            `json:"connectivity,omitempty"`
+	ActiveConnections int64             `json:"active_connections"`
+	MaxConnections    int64             `json:"max_concurrent_connections"` // Zero if unlimited
+	LatencyMs         float64           `json:"latency_ms"`
+	RecentErrors      []ProbeError      `json:"recent_errors"`
+	EgressIps         []string          `json:"egress_ips"` // Live public IP addresses of the probe
+}
+
+func (api *AdminApi) newProbeView(probe *Probe) probeView {
+	state := probeStateHealthy
+	if probe.Draining() {
+		state = probeStateDraining
+	} else if probe.Ejected() {
+		state = probeStateEjected
+	}
+	recentErrors := probe.RecentErrors()
+	if recentErrors == nil {
+		recentErrors = []ProbeError{}
+	}
+	labels := probe.Labels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	egressIps := []string{}
+	for _, ip := range api.core.EgressIps() {
+		if ip.Live && slices.Contains(ip.Probes, probe.Name()) {
+			egressIps = append(egressIps, ip.Ip)
+		}
+	}
+	return probeView{
+		Name:              probe.Name(),
+		Weight:            probe.Weight(),
+		Group:             probe.Group(),
+		Labels:            labels,
+		State:             state,
+		Connectivity:      probe.Connectivity(),
+		ActiveConnections: probe.ActiveConnections(),
+		MaxConnections:    probe.MaxConnections(),
+		LatencyMs:         float64(probe.Latency().Microseconds()) / 1000,
+		RecentErrors:      recentErrors,
+		EgressIps:         egressIps,
+	}
+}
+
+func (api *AdminApi) listProbes(w http.ResponseWriter, req *http.Request) {
+	probes := api.core.Probes()
+	views := make([]probeView, len(probes))
+	for i, probe := range probes {
+		views[i] = api.newProbeView(probe)
+	}
+	writeJson(w, http.StatusOK, map[string]any{"probes": views})
+}
+
+func (api *AdminApi) listEgressIps(w http.ResponseWriter, req *http.Request) {
+	ips := api.core.EgressIps()
+	live := 0
+	for _, ip := range ips {
+		if ip.Live {
+			live++
+		}
+	}
+	writeJson(w, http.StatusOK, map[string]any{
+		"live_ips": live,
+		"ips":      ips,
+	})
+}
+
+func (api *AdminApi) addProbe(w http.ResponseWriter, req *http.Request) {
+	if api.manager == nil {
+		writeJsonError(w, http.StatusNotImplemented, errors.New("adding probes is not supported"))
+		return
+	}
+	var body AddProbeRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeJsonError(w, http.StatusBadRequest, err)
+		return
+	}
+	if body.Host == "" {
+		writeJsonError(w, http.StatusBadRequest, errors.New("host must not be empty"))
+		return
+	}
+	if body.MaxConcurrentConnections < 0 {
+		writeJsonError(w, http.StatusBadRequest, errors.New("max_concurrent_connections must not be negative"))
+		return
+	}
+	probe, err := api.manager.AddProbe(body)
+	if err != nil {
+		api.handlePoolError(w, req, err)
+		return
+	}
+	api.logger.LogAttrs(
+		req.Context(),
+		slog.LevelInfo,
+		"Probe added through admin API.",
+		slog.String("probe", probe.Name()),
+	)
+	writeJson(w, http.StatusCreated, api.newProbeView(probe))
+}
+
+func (api *AdminApi) removeProbe(w http.ResponseWriter, req *http.Request) {
+	if api.manager == nil {
+		writeJsonError(w, http.StatusNotImplemented, errors.New("removing probes is not supported"))
+		return
+	}
+	name := req.PathValue("name")
+	if err := api.manager.RemoveProbe(name); err != nil {
+		api.handlePoolError(w, req, err)
+		return
+	}
+	api.logger.LogAttrs(
+		req.Context(),
+		slog.LevelInfo,
+		"Probe removed through admin API.",
+		slog.String("probe", name),
+	)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *AdminApi) drainProbe(w http.ResponseWriter, req *http.Request) {
+	api.setDraining(w, req, true)
+}
+
+func (api *AdminApi) enableProbe(w http.ResponseWriter, req *http.Request) {
+	api.setDraining(w, req, false)
+}
+
+func (api *AdminApi) setDraining(w http.ResponseWriter, req *http.Request, draining bool) {
+	probe, err := api.core.SetDraining(req.PathValue("name"), draining)
+	if err != nil {
+		api.handlePoolError(w, req, err)
+		return
+	}
+	api.logger.LogAttrs(
+		req.Context(),
+		slog.LevelInfo,
+		"Probe draining changed through admin API.",
+		slog.String("probe", probe.Name()),
+		slog.Bool("draining", draining),
+	)
+	writeJson(w, http.StatusOK, api.newProbeView(probe))
+}
+
+func (api *AdminApi) handlePoolError(w http.ResponseWriter, req *http.Request, err error) {
+	switch fault.Code[PoolErrorCode](err) {
+	case PoolInvalidProbe:
+		writeJsonError(w, http.StatusBadRequest, err)
+	case PoolProbeNotFound:
+		writeJsonError(w, http.StatusNotFound, err)
+	case PoolProbeExists, PoolLastProbe:
+		writeJsonError(w, http.StatusConflict, err)
+	default:
+		api.logger.LogAttrs(
+			req.Context(),
+			slog.LevelError,
+			"Unknown error when changing the pool of probes.",
+			slog.Any("error", err),
+		)
+		writeJsonError(w, http.StatusInternalServerError, err)
+	}
+}
+
+// writeJson writes v as a JSON response with the given status code.
+func writeJson(w http.ResponseWriter, statusCode int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeJsonError writes err as a JSON error response with the given status code.
+func writeJsonError(w http.ResponseWriter, statusCode int, err error) {
+	writeJson(w, statusCode, map[string]string{"error": err.Error()})
+}
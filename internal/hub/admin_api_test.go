@@ -0,0 +1,83 @@
+package hub
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testProbeManager adds and removes probes with stub dialers.
+type testProbeManager struct {
+	core *Core
+}
+
+func (m testProbeManager) AddProbe(req AddProbeRequest) (*Probe, error) {
+	return m.core.AddProbe(ProbeSpec{Name: req.Host, Weight: req.Weight, Dialer: &stubDialer{req.Host}})
+}
+
+func (m testProbeManager) RemoveProbe(name string) error {
+	_, err := m.core.RemoveProbe(name)
+	return err
+}
+
+func TestAdminApi(t *testing.T) {
+	core := NewCore(slog.New(slog.DiscardHandler), []ProbeSpec{
+		{Name: "a", Dialer: &stubDialer{"a"}},
+		{Name: "https://b", Dialer: &stubDialer{"b"}},
+	})
+	api := NewAdminApi(slog.New(slog.DiscardHandler), core, "secret")
+	api.SetProbeManager(testProbeManager{core})
+
+	do := func(method string, path string, body string, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		return rec
+	}
+	listStates := func() map[string]string {
+		rec := do("GET", "/probes", "", "secret")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var res struct {
+			Probes []probeView `json:"probes"`
+		}
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+		states := map[string]string{}
+		for _, probe := range res.Probes {
+			states[probe.Name] = probe.State
+		}
+		return states
+	}
+
+	// Requests without the secret are rejected.
+	assert.Equal(t, http.StatusUnauthorized, do("GET", "/probes", "", "wrong").Code)
+	assert.Equal(t, map[string]string{"a": "healthy", "https://b": "healthy"}, listStates())
+
+	// Draining excludes a probe from new connections until it is enabled.
+	assert.Equal(t, http.StatusOK, do("POST", "/probes/https:%2F%2Fb/drain", "", "secret").Code)
+	assert.Equal(t, map[string]string{"a": "healthy", "https://b": "draining"}, listStates())
+	for range 3 {
+		probe, err := core.selectProbe(t.Context(), "target:80", routingHints{}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "a", probe.Name())
+	}
+	assert.Equal(t, http.StatusOK, do("POST", "/probes/https:%2F%2Fb/enable", "", "secret").Code)
+	assert.Equal(t, map[string]string{"a": "healthy", "https://b": "healthy"}, listStates())
+	assert.Equal(t, http.StatusNotFound, do("POST", "/probes/c/drain", "", "secret").Code)
+
+	// Probes are added and removed through the manager.
+	assert.Equal(t, http.StatusCreated, do("POST", "/probes", `{"host": "c", "weight": 2}`, "secret").Code)
+	assert.Equal(t, http.StatusConflict, do("POST", "/probes", `{"host": "c"}`, "secret").Code)
+	assert.Equal(t, http.StatusBadRequest, do("POST", "/probes", `{}`, "secret").Code)
+	assert.Len(t, listStates(), 3)
+	assert.Equal(t, http.StatusNoContent, do("DELETE", "/probes/a", "", "secret").Code)
+	assert.Equal(t, http.StatusNoContent, do("DELETE", "/probes/c", "", "secret").Code)
+	assert.Equal(t, http.StatusNotFound, do("DELETE", "/probes/c", "", "secret").Code)
+	assert.Equal(t, http.StatusConflict, do("DELETE", "/probes/https:%2F%2Fb", "", "secret").Code)
+	assert.Equal(t, map[string]string{"https://b": "healthy"}, listStates())
+}
@@ -0,0 +1,135 @@
+package hub
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// proxyAuthRealm is the realm announced to clients in authentication challenges.
+const proxyAuthRealm = "rotox"
+
+// Authenticator verifies the credentials presented by proxy clients.
+type Authenticator interface {
+	// Authenticate reports whether the username and password are valid.
+	Authenticate(username string, password string) bool
+}
+
+// NewSecretAuthenticator creates an Authenticator that accepts any username
+// as long as the password equals the shared secret.
+func NewSecretAuthenticator(secret string) Authenticator {
+	return &secretAuthenticator{secret: []byte(secret)}
+}
+
+type secretAuthenticator struct {
+	secret []byte
+}
+
+func (auth *secretAuthenticator) Authenticate(_ string, password string) bool {
+	return subtle.ConstantTimeCompare([]byte(password), auth.secret) == 1
+}
+
+// NewUsersAuthenticator creates an Authenticator from a htpasswd-style users file.
+// Each non-empty line that does not start with '#' must have the form
+// "username:hash", where hash is a bcrypt hash (as produced by `htpasswd -B`).
+func NewUsersAuthenticator(filename string) (Authenticator, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open users file: %w", err)
+	}
+	defer file.Close()
+
+	users := make(map[string][]byte)
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		username, hash, found := strings.Cut(line, ":")
+		if !found || username == "" {
+			return nil, fmt.Errorf("invalid entry on line %d of users file", lineNo)
+		}
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("invalid bcrypt hash for user %q on line %d: %w", username, lineNo, err)
+		}
+		users[username] = []byte(hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read users file: %w", err)
+	}
+	return &usersAuthenticator{
+		users:    users,
+		verified: make(map[string][sha256.Size]byte),
+	}, nil
+}
+
+// usersAuthenticator verifies credentials against bcrypt hashes.
+// Since bcrypt is deliberately slow, a digest of the last successfully
+// verified password is remembered per user.
+type usersAuthenticator struct {
+	users    map[string][]byte            // Bcrypt hash per username
+	mu       sync.Mutex                   // Protects verified
+	verified map[string][sha256.Size]byte // Digest of last verified password per username
+}
+
+func (auth *usersAuthenticator) Authenticate(username string, password string) bool {
+	hash, ok := auth.users[username]
+	if !ok {
+		return false
+	}
+	digest := sha256.Sum256([]byte(password))
+
+	auth.mu.Lock()
+	cached, ok := auth.verified[username]
+	auth.mu.Unlock()
+	if ok && subtle.ConstantTimeCompare(cached[:], digest[:]) == 1 {
+		return true
+	}
+
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil {
+		return false
+	}
+	auth.mu.Lock()
+	auth.verified[username] = digest
+	auth.mu.Unlock()
+	return true
+}
+
+// NewMultiAuthenticator creates an Authenticator that accepts credentials
+// accepted by any of the provided authenticators.
+func NewMultiAuthenticator(auths ...Authenticator) Authenticator {
+	return multiAuthenticator(auths)
+}
+
+type multiAuthenticator []Authenticator
+
+func (auths multiAuthenticator) Authenticate(username string, password string) bool {
+	for _, auth := range auths {
+		if auth.Authenticate(username, password) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseProxyAuthorization extracts the credentials from a
+// "Proxy-Authorization: Basic ..." header value.
+func parseProxyAuthorization(header string) (string, string, bool) {
+	scheme, encoded, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "basic") {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return "", "", false
+	}
+	return strings.Cut(string(decoded), ":")
+}
@@ -0,0 +1,65 @@
+package hub
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// maxWatchedBytes bounds the data a clientWatch keeps for the target. Once a
+// client sent more before being accepted, its connection is no longer watched.
+const maxWatchedBytes = 64 * 1024
+
+// clientWatch detects that a client's connection fails while the hub is
+// still waiting to accept it, e.g. for a rate limit, a cooldown or the queue,
+// so that the wait can be cancelled. Proxy clients that are not answered over
+// HTTP have no request context for this. Data the client sends early is kept,
+// to be forwarded to the target. A client that closes its side of the
+// connection (io.EOF) may still expect the target's response, so that only
+// ends the watch.
+type clientWatch struct {
+	conn     net.Conn
+	done     chan struct{}
+	stopOnce sync.Once
+	early    []byte // Data read from the client while watching
+}
+
+// watchClient watches conn until stop is called, calling cancel if the
+// connection fails in the meantime.
+func watchClient(conn net.Conn, cancel func()) *clientWatch {
+	watch := &clientWatch{conn: conn, done: make(chan struct{})}
+	go func() {
+		defer close(watch.done)
+		buf := make([]byte, 4096)
+		for len(watch.early) < maxWatchedBytes {
+			n, err := conn.Read(buf)
+			watch.early = append(watch.early, buf[:n]...)
+			var netErr net.Error
+			if errors.Is(err, io.EOF) || errors.As(err, &netErr) && netErr.Timeout() {
+				return
+			}
+			if err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+	return watch
+}
+
+// stop ends the watch and returns a reader of the client connection that
+// starts with the data read while watching. Later calls return nil.
+func (watch *clientWatch) stop() io.Reader {
+	var reader io.Reader
+	watch.stopOnce.Do(func() {
+		// A deadline in the past interrupts the pending read.
+		watch.conn.SetReadDeadline(time.Unix(1, 0))
+		<-watch.done
+		watch.conn.SetReadDeadline(time.Time{})
+		reader = io.MultiReader(bytes.NewReader(watch.early), watch.conn)
+	})
+	return reader
+}
@@ -0,0 +1,80 @@
+package hub
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientWatch_CancelsWhenClientLeaves(t *testing.T) {
+	client, hubSide := tcpPipe(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watch := watchClient(hubSide, cancel)
+	defer watch.stop()
+
+	// A client that goes away resets its connection.
+	client.SetLinger(0)
+	client.Close()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the context must be cancelled when the client leaves")
+	}
+}
+
+func TestClientWatch_KeepsHalfClosedClient(t *testing.T) {
+	client, hubSide := tcpPipe(t)
+	defer client.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watch := watchClient(hubSide, cancel)
+
+	// A client that is done sending still waits for the response.
+	_, err := client.Write([]byte("request"))
+	assert.NoError(t, err)
+	assert.NoError(t, client.CloseWrite())
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, ctx.Err())
+
+	received, err := io.ReadAll(watch.stop())
+	assert.NoError(t, err)
+	assert.Equal(t, "request", string(received))
+}
+
+// tcpPipe returns both ends of a loopback TCP connection.
+func tcpPipe(t *testing.T) (*net.TCPConn, net.Conn) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer lis.Close()
+	client, err := net.Dial("tcp", lis.Addr().String())
+	assert.NoError(t, err)
+	hubSide, err := lis.Accept()
+	assert.NoError(t, err)
+	t.Cleanup(func() { hubSide.Close() })
+	return client.(*net.TCPConn), hubSide
+}
+
+func TestClientWatch_KeepsEarlyData(t *testing.T) {
+	client, hubSide := net.Pipe()
+	defer client.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watch := watchClient(hubSide, cancel)
+
+	_, err := client.Write([]byte("early"))
+	assert.NoError(t, err)
+	reader := watch.stop()
+	assert.NoError(t, ctx.Err())
+	assert.Nil(t, watch.stop())
+
+	go client.Write([]byte(" data"))
+	received := make([]byte, len("early data"))
+	_, err = io.ReadFull(reader, received)
+	assert.NoError(t, err)
+	assert.Equal(t, "early data", string(received))
+}
@@ -0,0 +1,181 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CooldownConfig configures the per-target cooldown of probes. After a
+// connection to a host, the probe and its egress IP are not used for another
+// connection to the same host until the cooldown has passed.
+type CooldownConfig struct {
+	Duration time.Duration // Minimum time between two connections to the same host from the same egress, zero disables the cooldown
+	MaxWait  time.Duration // How long a connection waits for a probe to leave its cooldown, zero fails fast
+}
+
+// cooldownError reports that every probe is in cooldown for the target host.
+type cooldownError struct {
+	retryAfter time.Duration // Time until the first probe leaves its cooldown
+}
+
+func (err *cooldownError) Error() string {
+	return fmt.Sprintf(
+		"every probe is in cooldown for the target host, retry after %s",
+		err.retryAfter.Round(time.Millisecond),
+	)
+}
+
+// cooldownKey identifies an egress (a probe or an egress IP) and a target host.
+type cooldownKey struct {
+	egress string
+	host   string
+}
+
+// cooldownTracker remembers until when each egress is in cooldown for each
+// target host. Probes are identified both by name and by their last known
+// egress IP, so that probes sharing an egress IP share their cooldown.
+type cooldownTracker struct {
+	mu      sync.Mutex                // Protects the fields below
+	cfg     CooldownConfig            // Current configuration
+	until   map[cooldownKey]time.Time // End of the cooldown per egress and host
+	sweptAt time.Time                 // When expired cooldowns were last removed
+	now     func() time.Time
+}
+
+func newCooldownTracker() *cooldownTracker {
+	return &cooldownTracker{
+		until: map[cooldownKey]time.Time{},
+		now:   time.Now,
+	}
+}
+
+// configure replaces the configuration, keeping the current cooldowns.
+func (t *cooldownTracker) configure(cfg CooldownConfig) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cfg = cfg
+}
+
+// pick selects one of the candidates that is not in cooldown for the host of
+// targetAddress, and starts its cooldown. If every candidate is in cooldown, a
+// *cooldownError is returned. Choosing and starting the cooldown is atomic, so
+// that concurrent connections never pick the same egress for the same host.
+// If the pick function returns nil, because the probe it chose cannot be used
+// after all, no cooldown is started and nil is returned.
+func (t *cooldownTracker) pick(
+	candidates []*Probe,
+	targetAddress string,
+	pick func(eligible []*Probe) *Probe,
+) (*Probe, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cfg.Duration <= 0 {
+		return pick(candidates), nil
+	}
+
+	now := t.now()
+	t.sweep(now)
+	host := targetHost(targetAddress)
+	eligible := make([]*Probe, 0, len(candidates))
+	retryAfter := t.cfg.Duration
+	for _, probe := range candidates {
+		remaining := t.remaining(probe, host, now)
+		if remaining <= 0 {
+			eligible = append(eligible, probe)
+		} else {
+			retryAfter = min(retryAfter, remaining)
+		}
+	}
+	if len(eligible) == 0 {
+		return nil, &cooldownError{retryAfter: retryAfter}
+	}
+
+	probe := pick(eligible)
+	if probe == nil {
+		return nil, nil
+	}
+	for _, egress := range egressKeys(probe) {
+		t.until[cooldownKey{egress, host}] = now.Add(t.cfg.Duration)
+	}
+	return probe, nil
+}
+
+// observeEgressIp starts the cooldown of an egress IP reported for a
+// connection to targetAddress, in case the probe was picked before its
+// egress IP was known.
+func (t *cooldownTracker) observeEgressIp(ip string, targetAddress string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cfg.Duration <= 0 {
+		return
+	}
+	key := cooldownKey{egressIpKey(ip), targetHost(targetAddress)}
+	if until := t.now().Add(t.cfg.Duration); until.After(t.until[key]) {
+		t.until[key] = until
+	}
+}
+
+// maxWait returns how long a connection may wait for a probe to leave its cooldown.
+func (t *cooldownTracker) maxWait() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cfg.MaxWait
+}
+
+// remaining returns how long probe is still in cooldown for host.
+// The caller must hold t.mu.
+func (t *cooldownTracker) remaining(probe *Probe, host string, now time.Time) time.Duration {
+	var remaining time.Duration
+	for _, egress := range egressKeys(probe) {
+		remaining = max(remaining, t.until[cooldownKey{egress, host}].Sub(now))
+	}
+	return remaining
+}
+
+// sweep removes expired cooldowns, at most once per cooldown duration.
+// The caller must hold t.mu.
+func (t *cooldownTracker) sweep(now time.Time) {
+	if now.Sub(t.sweptAt) < t.cfg.Duration {
+		return
+	}
+	t.sweptAt = now
+	for key, until := range t.until {
+		if !until.After(now) {
+			delete(t.until, key)
+		}
+	}
+}
+
+// egressKeys returns the keys identifying the egress of a probe.
+func egressKeys(probe *Probe) []string {
+	keys := []string{"probe:" + probe.Name()}
+	if ip := probe.EgressIp(); ip != "" {
+		keys = append(keys, egressIpKey(ip))
+	}
+	return keys
+}
+
+func egressIpKey(ip string) string {
+	return "ip:" + ip
+}
+
+// awaitCooldown waits until a probe may leave its cooldown if err is a
+// *cooldownError and the wait ends before deadline. It reports whether the
+// caller should select a probe again.
+func awaitCooldown(ctx context.Context, err error, deadline time.Time) bool {
+	var cdErr *cooldownError
+	if !errors.As(err, &cdErr) || time.Now().Add(cdErr.retryAfter).After(deadline) {
+		return false
+	}
+	timer := time.NewTimer(cdErr.retryAfter)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,71 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCooldownTracker(t *testing.T) {
+	now := time.Unix(0, 0)
+	tracker := newCooldownTracker()
+	tracker.now = func() time.Time { return now }
+	tracker.configure(CooldownConfig{Duration: 10 * time.Second})
+	probes := newTestProbes(1, 1, 1)
+	probes[2].observeEgressIp("198.51.100.1")
+	first := func(eligible []*Probe) *Probe { return eligible[0] }
+
+	// Each probe is used once per host within the cooldown.
+	probe, err := tracker.pick(probes[:2], "example.com:443", first)
+	assert.NoError(t, err)
+	assert.Equal(t, probes[0], probe)
+	now = now.Add(4 * time.Second)
+	probe, err = tracker.pick(probes[:2], "EXAMPLE.com:80", first)
+	assert.NoError(t, err)
+	assert.Equal(t, probes[1], probe)
+	_, err = tracker.pick(probes[:2], "example.com:443", first)
+	var cdErr *cooldownError
+	assert.True(t, errors.As(err, &cdErr))
+	assert.Equal(t, 6*time.Second, cdErr.retryAfter)
+
+	// Other hosts are not affected.
+	probe, err = tracker.pick(probes[:2], "example.org:443", first)
+	assert.NoError(t, err)
+	assert.Equal(t, probes[0], probe)
+
+	// Probes sharing an egress IP share their cooldown.
+	tracker.observeEgressIp("198.51.100.1", "example.net:443")
+	_, err = tracker.pick(probes[2:], "example.net:443", first)
+	assert.Error(t, err)
+
+	// The cooldown ends after its duration.
+	now = now.Add(6 * time.Second)
+	probe, err = tracker.pick(probes[:2], "example.com:443", first)
+	assert.NoError(t, err)
+	assert.Equal(t, probes[0], probe)
+
+	// A probe that is not used after all is not put on cooldown.
+	probe, err = tracker.pick(probes[:2], "example.edu:443", func([]*Probe) *Probe { return nil })
+	assert.NoError(t, err)
+	assert.Nil(t, probe)
+	probe, err = tracker.pick(probes[:1], "example.edu:443", first)
+	assert.NoError(t, err)
+	assert.Equal(t, probes[0], probe)
+
+	// A zero duration disables the cooldown.
+	tracker.configure(CooldownConfig{})
+	probe, err = tracker.pick(probes[:1], "example.com:443", first)
+	assert.NoError(t, err)
+	assert.Equal(t, probes[0], probe)
+}
+
+func TestAwaitCooldown(t *testing.T) {
+	ctx := context.Background()
+	err := &cooldownError{retryAfter: 10 * time.Millisecond}
+	assert.True(t, awaitCooldown(ctx, err, time.Now().Add(time.Second)))
+	assert.False(t, awaitCooldown(ctx, err, time.Now()))
+	assert.False(t, awaitCooldown(ctx, errors.New("other"), time.Now().Add(time.Second)))
+}
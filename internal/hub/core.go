@@ -1,8 +1,9 @@
 // Package hub implements the central coordinator of the rotox proxy system.
 //
 // The hub receives incoming proxy requests from clients and distributes them
-// across a pool of probes using round-robin load balancing. It manages the
-// lifecycle of connections and provides telemetry about traffic flow.
+// across a pool of probes using a pluggable selection strategy (round-robin by
+// default), optionally pinning client-named sessions to a single probe. It
+// manages the lifecycle of connections and provides telemetry about traffic flow.
 //
 // The hub is responsible for:
 //   - Accepting HTTP proxy requests (both plaintext and CONNECT)
@@ -14,45 +15,289 @@ package hub
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/isacskoglund/rotox/internal/common"
+	"github.com/isacskoglund/rotox/internal/fault"
 	"github.com/isacskoglund/rotox/internal/telemetry"
 )
 
+// defaultMaxDialAttempts is the default number of probes tried per connection.
+const defaultMaxDialAttempts = 3
+
 // Core represents the central hub service that coordinates proxy requests
-// across multiple probes. It implements round-robin load balancing and
-// provides telemetry integration.
+// across multiple probes. It implements load balancing through a Selector
+// and provides telemetry integration.
 type Core struct {
-	logger *slog.Logger             // Logger for hub operations
-	tel    *multiTelemetryPublisher // Telemetry publisher for events
-	probes []common.Dialer          // Pool of available probes
-	next   chan int                 // Channel for round-robin probe selection
+	logger   *slog.Logger                    // Logger for hub operations
+	tel      *multiTelemetryPublisher        // Telemetry publisher for events
+	sessions *sessionStore                   // Sticky sessions pinned to a probe
+	health   *HealthConfig                   // Outlier ejection settings, nil disables ejection
+	egress   *egressTracker                  // Usage of the public IP addresses of the probes
+	cooldown *cooldownTracker                // Per-target cooldown of the probes
+	limits   *rateLimiter                    // Per-target rate limits across all probes
+	queue    *connectionQueue                // Connections waiting for a probe below its connection limit
+	grammar  atomic.Pointer[UsernameGrammar] // Encoding of routing hints in proxy usernames
+	policy   *accessPolicy                   // Targets that clients may connect to
+	routes   *router                         // Ordered rules routing targets to groups, the hub itself or nowhere
+	direct   common.Dialer                   // Dials targets routed directly, bypassing the probes
+	headers  atomic.Pointer[HeaderPolicy]    // Rewriting of the headers of plaintext HTTP requests
+
+	mu              sync.RWMutex // Protects the fields below, which may change while forwarding
+	probes          []*Probe     // Pool of available probes
+	selector        Selector     // Strategy for choosing a probe per connection
+	maxDialAttempts int          // Maximum number of probes tried per connection
 }
 
 // NewCore creates a new hub core instance with the provided logger and probes.
 // At least one probe must be provided or the function will panic.
-// The core uses round-robin load balancing to distribute requests across probes.
+// The core uses round-robin load balancing to distribute requests across
+// probes unless another selector is set.
 func NewCore(
 	logger *slog.Logger,
-	probes []common.Dialer,
+	probes []ProbeSpec,
 ) *Core {
 	if len(probes) == 0 {
 		panic("Probes must not be empty")
 	}
 
-	var next = make(chan int, 1)
-	next <- 0 // Initialize with starting value
+	pool := make([]*Probe, len(probes))
+	for i, spec := range probes {
+		pool[i] = newProbe(spec)
+	}
+
+	tel := newMultiTelemetryPublisher()
+	core := &Core{
+		logger:          logger,
+		probes:          pool,
+		tel:             tel,
+		selector:        &roundRobinSelector{},
+		sessions:        newSessionStore(defaultSessionTTL, defaultMaxSessions),
+		egress:          newEgressTracker(),
+		cooldown:        newCooldownTracker(),
+		limits:          newRateLimiter(),
+		queue:           newConnectionQueue(tel),
+		policy:          newAccessPolicy(tel),
+		routes:          &router{},
+		direct:          &directDialer{dialer: net.Dialer{Timeout: directDialTimeout}},
+		maxDialAttempts: defaultMaxDialAttempts,
+	}
+	core.grammar.Store(&UsernameGrammar{})
+	core.headers.Store(&HeaderPolicy{})
+	return core
+}
+
+// UpdateProbes replaces the pool of probes while the core is forwarding.
+// Probes with the same name, weight, group, labels and dialer as before are
+// kept along with their statistics and health state, taking on the new
+// connection limit. Connections already using a removed probe are not
+// affected; the removed probes are returned so that the caller can release
+// their dialers once they are idle (see Probe.WaitIdle).
+func (core *Core) UpdateProbes(probes []ProbeSpec) ([]*Probe, error) {
+	if len(probes) == 0 {
+		return nil, fmt.Errorf("probes must not be empty")
+	}
+
+	core.mu.Lock()
+	defer core.mu.Unlock()
+	pool := make([]*Probe, len(probes))
+	for i, spec := range probes {
+		idx := slices.IndexFunc(core.probes, func(probe *Probe) bool {
+			return probe.name == spec.Name &&
+				probe.weight == max(spec.Weight, 1) &&
+				probe.group == spec.Group &&
+				maps.Equal(probe.labels, spec.Labels) &&
+				probe.dialer == spec.Dialer
+		})
+		if idx >= 0 {
+			pool[i] = core.probes[idx]
+			pool[i].maxActive.Store(int64(max(spec.MaxConnections, 0)))
+		} else {
+			pool[i] = newProbe(spec)
+		}
+	}
+	var removed []*Probe
+	for _, probe := range core.probes {
+		if !slices.Contains(pool, probe) {
+			removed = append(removed, probe)
+		}
+	}
+	core.probes = pool
+	core.queue.notify()
+	return removed, nil
+}
+
+// Probes returns the current pool of probes.
+func (core *Core) Probes() []*Probe {
+	core.mu.RLock()
+	defer core.mu.RUnlock()
+	return slices.Clone(core.probes)
+}
+
+// SetMaxDialAttempts configures how many probes are tried per connection
+// when dials fail because the probe itself is unavailable.
+// Values below 1 restore the default of 3 attempts.
+func (core *Core) SetMaxDialAttempts(attempts int) {
+	if attempts < 1 {
+		attempts = defaultMaxDialAttempts
+	}
+	core.mu.Lock()
+	defer core.mu.Unlock()
+	core.maxDialAttempts = attempts
+}
+
+// SetSelector replaces the strategy used to choose a probe per connection.
+func (core *Core) SetSelector(selector Selector) {
+	core.mu.Lock()
+	defer core.mu.Unlock()
+	core.selector = selector
+}
+
+// SetSessionLimits configures the lifetime of sticky sessions and the
+// maximum number of sessions kept at the same time.
+// It must be called before the core starts forwarding requests.
+func (core *Core) SetSessionLimits(ttl time.Duration, maxSessions int) {
+	core.sessions = newSessionStore(ttl, maxSessions)
+}
+
+// SetCooldown configures the per-target cooldown of probes.
+// A zero duration disables the cooldown.
+func (core *Core) SetCooldown(cfg CooldownConfig) {
+	core.cooldown.configure(cfg)
+}
+
+// SetRateLimits replaces the rate limits of target hosts. The first rule
+// matching a host applies to it. Changed limits start afresh: connections
+// allowed under the previous rules do not count against the new ones.
+func (core *Core) SetRateLimits(rules []RateLimitRule) error {
+	return core.limits.configure(rules)
+}
+
+// SetQueue configures the queue of connections waiting for a probe when every
+// probe is at its connection limit. A zero maximum depth uses the default,
+// while the timeout is used as it is. Neither may be negative.
+func (core *Core) SetQueue(cfg QueueConfig) {
+	if cfg.MaxDepth == 0 {
+		cfg.MaxDepth = DefaultQueueDepth
+	}
+	core.queue.configure(cfg)
+}
 
-	return &Core{
-		logger: logger,
-		probes: probes,
-		tel:    newMultiTelemetryPublisher(),
-		next:   next,
+// SetUsernameGrammar replaces the grammar of the routing hints that clients
+// encode in proxy usernames. Usernames carry no hints until it is set.
+func (core *Core) SetUsernameGrammar(grammar UsernameGrammar) error {
+	if err := grammar.validate(); err != nil {
+		return err
 	}
+	core.grammar.Store(&grammar)
+	return nil
+}
+
+// SetAccessPolicy replaces the policy restricting the targets that clients may
+// connect to. Connections denied by the policy fail with code
+// common.ForwardRejected before any probe is used.
+func (core *Core) SetAccessPolicy(policy AccessPolicy) error {
+	return core.policy.configure(policy)
+}
+
+// SetRoutes replaces the ordered routing rules. The first rule matching a
+// target decides whether connections to it use a given probe group, are
+// rejected or are dialed from the hub directly. Targets matching no rule use
+// any probe.
+func (core *Core) SetRoutes(rules []RouteRule) error {
+	return core.routes.configure(rules)
+}
+
+// SetHeaderPolicy replaces the policy rewriting the headers of plaintext HTTP
+// requests before they are forwarded.
+func (core *Core) SetHeaderPolicy(policy HeaderPolicy) error {
+	if err := policy.validate(); err != nil {
+		return err
+	}
+	core.headers.Store(&policy)
+	return nil
+}
+
+// Settings are the settings of a Core that may change while it is
+// forwarding, applied together by Configure. See the setters of Core for
+// their meaning.
+type Settings struct {
+	Selector        Selector
+	MaxDialAttempts int
+	Cooldown        CooldownConfig
+	Queue           QueueConfig
+	UsernameGrammar UsernameGrammar
+	RateLimits      []RateLimitRule
+	Routes          []RouteRule
+	AccessPolicy    AccessPolicy
+	HeaderPolicy    HeaderPolicy
+}
+
+// Validate reports whether the settings can be applied by Configure.
+func (settings Settings) Validate() error {
+	if settings.Selector == nil {
+		return errors.New("selector must be set")
+	}
+	if settings.Queue.MaxDepth < 0 || settings.Queue.Timeout < 0 {
+		return errors.New("invalid queue: max depth and timeout must not be negative")
+	}
+	if err := settings.UsernameGrammar.validate(); err != nil {
+		return fmt.Errorf("invalid username hints: %w", err)
+	}
+	if _, err := validateRateLimits(settings.RateLimits); err != nil {
+		return fmt.Errorf("invalid rate limits: %w", err)
+	}
+	if err := (&router{}).configure(settings.Routes); err != nil {
+		return fmt.Errorf("invalid routes: %w", err)
+	}
+	if _, err := settings.AccessPolicy.compile(); err != nil {
+		return fmt.Errorf("invalid access policy: %w", err)
+	}
+	if err := settings.HeaderPolicy.validate(); err != nil {
+		return fmt.Errorf("invalid header policy: %w", err)
+	}
+	return nil
+}
+
+// Configure applies settings. If any of them is invalid, none is applied.
+func (core *Core) Configure(settings Settings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+	core.SetSelector(settings.Selector)
+	core.SetMaxDialAttempts(settings.MaxDialAttempts)
+	core.SetCooldown(settings.Cooldown)
+	core.SetQueue(settings.Queue)
+	// The settings are valid, so the setters below cannot fail.
+	core.SetUsernameGrammar(settings.UsernameGrammar)
+	core.SetRateLimits(settings.RateLimits)
+	core.SetRoutes(settings.Routes)
+	core.SetAccessPolicy(settings.AccessPolicy)
+	core.SetHeaderPolicy(settings.HeaderPolicy)
+	return nil
+}
+
+// rewriteHeaders rewrites the headers of a plaintext HTTP request to
+// targetAddress according to the header policy and the routing rule
+// matching the target.
+func (core *Core) rewriteHeaders(req *http.Request, targetAddress string) {
+	core.headers.Load().apply(req, core.routes.match(targetAddress))
+}
+
+// parseUsername splits a proxy username into the actual username, used for
+// authentication, and the routing hints encoded in it.
+func (core *Core) parseUsername(username string) (string, routingHints, error) {
+	return core.grammar.Load().parse(username)
 }
 
 // RegisterTelemetryDispatcher adds a telemetry publisher to receive hub events.
@@ -62,36 +307,76 @@ func (core *Core) RegisterTelemetryDispatcher(dis telemetryPublisher) {
 }
 
 // forward handles a single proxy request by selecting a probe and establishing
-// the necessary connections. It uses round-robin load balancing to select the next
-// available probe and publishes telemetry events about the connection lifecycle.
+// the necessary connections. Requests denied by the access policy fail right
+// away. The routing rules are applied next, and may restrict the probes to a
+// group, reject the request or bypass the probes.
+// Requests that are part of a sticky session use the probe pinned to the
+// session; other requests use the selector to choose a probe. Telemetry events
+// about the connection lifecycle are published.
 func (core *Core) forward(
 	ctx context.Context,
 	targetAddress string,
+	hints routingHints,
 	accept func() (common.Conn, error),
 ) error {
-	// Get the next probe to be used (round robin)
-	probeIdx := <-core.next
-	if probeIdx >= len(core.probes)-1 {
-		core.next <- 0
-	} else {
-		core.next <- probeIdx + 1
+	if err := core.policy.check(targetAddress); err != nil {
+		return err
 	}
-	probe := core.probes[probeIdx]
 
-	core.logger.LogAttrs(
-		ctx,
-		slog.LevelDebug,
-		"Forwarding connection.",
-		slog.Int("probeIdx", probeIdx),
-	)
+	route := core.routes.match(targetAddress)
+	if route != nil {
+		core.logger.LogAttrs(
+			ctx,
+			slog.LevelInfo,
+			"Routing rule matched",
+			slog.String("rule", route.Name),
+			slog.String("action", string(route.Action)),
+			slog.String("target", targetAddress),
+		)
+		switch route.Action {
+		case RouteReject:
+			return fault.New(fmt.Sprintf("rejected by routing rule %q", route.Name), common.ForwardRejected)
+		case RouteToGroup:
+			hints.group = route.Group
+		}
+	}
 
-	// Dial to the target
-	targetConn, err := probe.Dial(ctx, targetAddress)
+	// Apply the rate limits before choosing a probe, so that rejected
+	// connections never reach a probe.
+	release, err := core.limits.acquire(ctx, targetAddress)
 	if err != nil {
 		return err
 	}
+	defer release()
+
+	var probe *Probe
+	var targetConn common.Conn
+	if route != nil && route.Action == RouteDirect {
+		targetConn, err = core.direct.Dial(ctx, targetAddress)
+		err = withProbe(err, directProbeName)
+	} else {
+		probe, targetConn, err = core.dial(ctx, targetAddress, hints)
+	}
+	if err != nil {
+		return err
+	}
+	if probe != nil {
+		defer core.releaseProbe(probe)
+	}
 	defer targetConn.Close()
 
+	probeName := directProbeName
+	egressIp := egressIpOf(targetConn)
+	if probe != nil {
+		probeName = probe.Name()
+		if egressIp != "" {
+			probe.observeEgressIp(egressIp)
+			core.cooldown.observeEgressIp(egressIp, targetAddress)
+			core.egress.open(egressIp, probe.Name())
+			defer core.egress.close(egressIp)
+		}
+	}
+
 	// Accept the client connection
 	// (only once connection to target has been established)
 	clientConn, err := accept()
@@ -117,24 +402,20 @@ func (core *Core) forward(
 		)
 	}
 	openedAt := time.Now()
-	core.tel.ConnectionPublisher().Publish(
-		telemetry.ConnectionEvent{
-			ConnectionId:  connectionId.String(),
-			ClientAddress: "not set",
-			TargetAddress: targetAddress,
-			OpenedAt:      openedAt,
-			ClosedAt:      time.Unix(0, 0),
-		},
-	)
-	defer core.tel.ConnectionPublisher().Publish(
-		telemetry.ConnectionEvent{
-			ConnectionId:  connectionId.String(),
-			ClientAddress: "not set",
-			TargetAddress: targetAddress,
-			OpenedAt:      openedAt,
-			ClosedAt:      time.Now(),
-		},
-	)
+	event := telemetry.ConnectionEvent{
+		ConnectionId:  connectionId.String(),
+		ClientAddress: "not set",
+		TargetAddress: targetAddress,
+		Probe:         probeName,
+		EgressIp:      egressIp,
+		OpenedAt:      openedAt,
+		ClosedAt:      time.Unix(0, 0),
+	}
+	core.tel.ConnectionPublisher().Publish(event)
+	defer func() {
+		event.ClosedAt = time.Now()
+		core.tel.ConnectionPublisher().Publish(event)
+	}()
 	common.Duplex(
 		ctx,
 		core.logger,
@@ -146,6 +427,314 @@ func (core *Core) forward(
 		ctx,
 		slog.LevelInfo,
 		"Connection closed",
+		slog.String("probe", probeName),
+		slog.String("egress_ip", egressIp),
+		dialInfoAttr(dialInfoOf(targetConn)),
 	)
 	return nil
 }
+
+// dial selects a probe and dials the target through it. If the probe itself
+// is unavailable, the dial is retried on another probe, up to the maximum
+// number of attempts and as long as ctx is not done. Errors reported by the
+// probe about the target are returned without retrying.
+//
+// On success, the probe's active connection count has been incremented
+// and must be released by the caller once the connection is closed.
+func (core *Core) dial(
+	ctx context.Context,
+	targetAddress string,
+	hints routingHints,
+) (*Probe, common.Conn, error) {
+	core.mu.RLock()
+	maxAttempts := core.maxDialAttempts
+	core.mu.RUnlock()
+
+	var tried []*Probe
+	for attempt := 1; ; attempt++ {
+		probe, err := core.awaitProbe(ctx, targetAddress, hints, tried)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		core.logger.LogAttrs(
+			ctx,
+			slog.LevelDebug,
+			"Forwarding connection.",
+			slog.String("probe", probe.Name()),
+			slog.String("session", hints.session),
+			slog.Int("attempt", attempt),
+		)
+
+		dialStart := time.Now()
+		targetConn, err := probe.dialer.Dial(ctx, targetAddress)
+		if ctx.Err() == nil {
+			// Target-side failures still prove that the probe itself works.
+			var probeErr error
+			if isProbeFailure(err) {
+				probeErr = err
+				probe.recordError(time.Now(), err)
+			}
+			core.reportHealth(ctx, probe, healthReport{err: probeErr})
+		}
+		if err == nil {
+			probe.observeLatency(time.Since(dialStart))
+			core.logger.LogAttrs(
+				ctx,
+				slog.LevelDebug,
+				"Dialed target.",
+				slog.String("probe", probe.Name()),
+				dialInfoAttr(dialInfoOf(targetConn)),
+			)
+			return probe, targetConn, nil
+		}
+		core.releaseProbe(probe)
+
+		if hints.session != "" && isProbeFailure(err) {
+			// Let the session continue on another probe.
+			core.sessions.remove(hints.session)
+		}
+		if !isRetryable(err) || attempt >= maxAttempts || ctx.Err() != nil {
+			return nil, nil, withProbe(err, probe.Name())
+		}
+		core.logger.LogAttrs(
+			ctx,
+			slog.LevelWarn,
+			"Probe unavailable, retrying on another probe.",
+			slog.String("probe", probe.Name()),
+			slog.Int("attempt", attempt),
+			slog.Any("error", err),
+		)
+		tried = append(tried, probe)
+	}
+}
+
+// awaitProbe selects the probe to use for a request like selectProbe. If
+// every probe is in cooldown for the target, it waits for a probe to leave
+// its cooldown, unless that takes longer than the configured maximum wait.
+// If every probe is at its connection limit, it waits in the queue. While
+// connections are waiting, new ones join the back of the queue rather than
+// taking the slots released for the waiting ones.
+func (core *Core) awaitProbe(
+	ctx context.Context,
+	targetAddress string,
+	hints routingHints,
+	exclude []*Probe,
+) (*Probe, error) {
+	deadline := time.Now().Add(core.cooldown.maxWait())
+	var waiter *queueWaiter
+	if core.queue.len() > 0 {
+		var err error
+		waiter, err = core.queue.enter(targetAddress)
+		if err != nil {
+			return nil, err
+		}
+		if err := core.queue.wait(ctx, waiter); err != nil {
+			return nil, err
+		}
+	}
+	for {
+		probe, err := core.selectProbe(ctx, targetAddress, hints, exclude)
+		if fault.Code[common.ForwardErrorCode](err) == common.ForwardProbesBusy {
+			if waiter == nil {
+				waiter, err = core.queue.enter(targetAddress)
+				if err != nil {
+					return nil, err
+				}
+			} else {
+				core.queue.retry(waiter)
+			}
+			if err := core.queue.wait(ctx, waiter); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if waiter != nil {
+			core.queue.leave(waiter, telemetry.QueueServed)
+			waiter = nil
+		}
+		if err == nil || !awaitCooldown(ctx, err, deadline) {
+			return probe, err
+		}
+	}
+}
+
+// releaseProbe decrements the active connection count of a probe returned by
+// selectProbe, and lets the next waiting connection select a probe.
+func (core *Core) releaseProbe(probe *Probe) {
+	probe.active.Add(-1)
+	core.queue.notify()
+}
+
+// selectProbe returns the probe to use for a request, or an error if every
+// probe is excluded, ruled out by the hints, draining, at its connection limit
+// or in cooldown for the target. Ejected probes are skipped, unless every
+// probe is ejected.
+// The active connection count of the returned probe is incremented while the
+// pool is locked, so that a probe removed from the pool is not seen as idle
+// before a connection that selected it has started.
+func (core *Core) selectProbe(
+	ctx context.Context,
+	targetAddress string,
+	hints routingHints,
+	exclude []*Probe,
+) (*Probe, error) {
+	core.mu.RLock()
+	defer core.mu.RUnlock()
+
+	now := time.Now()
+	candidates := make([]*Probe, 0, len(core.probes))
+	fallback := make([]*Probe, 0, len(core.probes))
+	for _, probe := range core.probes {
+		if probe.Draining() || slices.Contains(exclude, probe) || !hints.allows(probe) {
+			continue
+		}
+		fallback = append(fallback, probe)
+		if probe.health.available(now) {
+			candidates = append(candidates, probe)
+		}
+	}
+	if len(fallback) == 0 {
+		if hints.restricted() && len(exclude) == 0 {
+			return nil, fault.New("no probe matches the routing hints", common.ForwardProbeUnavailable)
+		}
+		return nil, fault.New("no probe available", common.ForwardProbeUnavailable)
+	}
+	if len(candidates) == 0 {
+		core.logger.LogAttrs(
+			ctx,
+			slog.LevelWarn,
+			"All probes are ejected, selecting among all of them.",
+		)
+		candidates = fallback
+	}
+
+	open := slices.DeleteFunc(slices.Clone(candidates), (*Probe).full)
+	busy := fault.New("every probe is at its connection limit", common.ForwardProbesBusy)
+	if hints.session == "" {
+		if len(open) == 0 {
+			return nil, busy
+		}
+		// Sessions are exempt from the cooldown, as they ask for the same egress.
+		// The probe is reserved before its cooldown starts, so that a probe
+		// that is not used is not put on cooldown either.
+		probe, err := core.cooldown.pick(open, targetAddress, func(eligible []*Probe) *Probe {
+			probe := core.selector.Select(eligible, targetAddress)
+			if !probe.reserve() {
+				return nil
+			}
+			return probe
+		})
+		if err != nil {
+			return nil, fault.Wrap(err, "no probe available", common.ForwardCooldown)
+		}
+		if probe == nil {
+			// The probe became full since the candidates were collected.
+			return nil, busy
+		}
+		return probe, nil
+	}
+
+	// A session waits for its probe rather than moving when it is full.
+	isCandidate := func(probe *Probe) bool {
+		return slices.Contains(candidates, probe)
+	}
+	pick := func() *Probe {
+		if len(open) == 0 {
+			return core.selector.Select(candidates, targetAddress)
+		}
+		return core.selector.Select(open, targetAddress)
+	}
+	probe := core.sessions.getOrPut(hints.session, hints.sessionTtl, isCandidate, pick)
+	if !probe.reserve() {
+		// The probe became full since the candidates were collected.
+		return nil, busy
+	}
+	return probe, nil
+}
+
+// isRetryable reports whether a dial error shows that the probe could not be
+// used at all, so that the dial may be retried on another probe.
+func isRetryable(err error) bool {
+	return fault.Code[common.ForwardErrorCode](err) == common.ForwardProbeUnavailable
+}
+
+// isProbeFailure reports whether a dial error was caused by the probe itself
+// rather than by the target (e.g. a target host that cannot be resolved).
+func isProbeFailure(err error) bool {
+	switch fault.Code[common.ForwardErrorCode](err) {
+	case fault.Ok,
+		common.ForwardFailedToResolveHost,
+		common.ForwardHostUnreachable,
+		common.ForwardConnectionRefused,
+		common.ForwardTimeout,
+		common.ForwardNetworkUnreachable,
+		common.ForwardTlsFailure,
+		common.ForwardBlockedByPolicy:
+		return false
+	default:
+		return true
+	}
+}
+
+// dialInfoOf returns how the target of conn was dialed, if known.
+func dialInfoOf(conn common.Conn) common.DialInfo {
+	if conn, ok := conn.(common.DialInfoConn); ok {
+		return conn.DialInfo()
+	}
+	return common.DialInfo{}
+}
+
+// dialInfoOfError returns how the target of a failed dial was dialed, if known.
+func dialInfoOfError(err error) common.DialInfo {
+	info, _ := common.DialInfoOf(err)
+	return info
+}
+
+// dialInfoAttr returns a log attribute describing a dial.
+func dialInfoAttr(info common.DialInfo) slog.Attr {
+	return slog.Group(
+		"dial",
+		slog.String("remote_ip", info.RemoteIp),
+		slog.String("local_address", info.LocalAddress),
+		slog.Duration("duration", info.Duration),
+	)
+}
+
+// directProbeName is the probe name reported for connections dialed by the
+// hub itself.
+const directProbeName = "direct"
+
+// probeError attaches the name of the probe that dialed the target to a
+// dial error.
+type probeError struct {
+	err   error
+	probe string
+}
+
+func (err *probeError) Error() string {
+	return err.err.Error()
+}
+
+func (err *probeError) Unwrap() error {
+	return err.err
+}
+
+// withProbe attaches the name of the probe that failed to dial the target
+// to err. It returns nil if err is nil.
+func withProbe(err error, probe string) error {
+	if err == nil {
+		return nil
+	}
+	return &probeError{err: err, probe: probe}
+}
+
+// probeOfError returns the name of the probe attached to err with withProbe,
+// or an empty string if no probe was used.
+func probeOfError(err error) string {
+	var probeErr *probeError
+	if errors.As(err, &probeErr) {
+		return probeErr.probe
+	}
+	return ""
+}
@@ -0,0 +1,92 @@
+package hub
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/isacskoglund/rotox/internal/common"
+	"github.com/isacskoglund/rotox/internal/fault"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubDialer is a distinct dialer that is never used for dialing.
+type stubDialer struct{ name string }
+
+func (d *stubDialer) Dial(context.Context, string) (common.Conn, error) {
+	panic("not implemented")
+}
+
+func TestCore_UpdateProbes(t *testing.T) {
+	a, b, c := &stubDialer{"a"}, &stubDialer{"b"}, &stubDialer{"c"}
+	core := NewCore(slog.New(slog.DiscardHandler), []ProbeSpec{
+		{Name: "a", Dialer: a},
+		{Name: "b", Dialer: b},
+	})
+	before := core.Probes()
+	before[0].active.Add(1)
+
+	// Unchanged probes are kept, changed and missing probes are removed.
+	removed, err := core.UpdateProbes([]ProbeSpec{
+		{Name: "a", Dialer: a},
+		{Name: "b", Weight: 2, Dialer: b},
+		{Name: "c", Dialer: c},
+	})
+	assert.NoError(t, err)
+	after := core.Probes()
+	assert.Len(t, after, 3)
+	assert.Same(t, before[0], after[0])
+	assert.Equal(t, int64(1), after[0].ActiveConnections())
+	assert.NotSame(t, before[1], after[1])
+	assert.Equal(t, 2, after[1].Weight())
+	assert.Equal(t, []*Probe{before[1]}, removed)
+
+	removed, err = core.UpdateProbes([]ProbeSpec{{Name: "c", Dialer: c}})
+	assert.NoError(t, err)
+	assert.Equal(t, after[:2], removed)
+	assert.Equal(t, after[2:], core.Probes())
+
+	// An empty pool is rejected.
+	_, err = core.UpdateProbes(nil)
+	assert.Error(t, err)
+	assert.Equal(t, after[2:], core.Probes())
+}
+
+func TestProbe_WaitIdle(t *testing.T) {
+	probe := newProbe(ProbeSpec{Name: "a"})
+	assert.NoError(t, probe.WaitIdle(context.Background()))
+
+	probe.active.Add(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, probe.WaitIdle(ctx), context.DeadlineExceeded)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		probe.active.Add(-1)
+	}()
+	assert.NoError(t, probe.WaitIdle(context.Background()))
+}
+
+func TestCore_ConfigureInvalid(t *testing.T) {
+	core := NewCore(slog.New(slog.DiscardHandler), []ProbeSpec{{Name: "a", Dialer: &stubDialer{"a"}}})
+	settings := Settings{
+		Selector:        &roundRobinSelector{},
+		UsernameGrammar: DefaultUsernameGrammar,
+		Routes:          []RouteRule{{Name: "block", Hosts: []string{"blocked.com"}, Action: RouteReject}},
+	}
+	assert.NoError(t, core.Configure(settings))
+
+	// Nothing is applied if any setting is invalid.
+	settings.Routes = nil
+	settings.HeaderPolicy = HeaderPolicy{Via: "append"}
+	assert.Error(t, core.Configure(settings))
+	err := core.forward(context.Background(), "blocked.com:443", routingHints{}, func() (common.Conn, error) {
+		t.Fatal("the client connection must not be accepted")
+		return nil, nil
+	})
+	assert.Equal(t, common.ForwardRejected, fault.Code[common.ForwardErrorCode](err))
+
+	assert.Error(t, core.Configure(Settings{}))
+}
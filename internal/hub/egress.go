@@ -0,0 +1,100 @@
+package hub
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/isacskoglund/rotox/internal/common"
+)
+
+// egressLiveTime is how long an egress IP is considered live after it was
+// last used, if it has no active connections.
+const egressLiveTime = 15 * time.Minute
+
+// EgressIpStats describes the usage of a public IP address that probes
+// egress from.
+type EgressIpStats struct {
+	Ip                string    `json:"ip"`
+	Live              bool      `json:"live"`               // Whether the address has been used recently
+	Probes            []string  `json:"probes"`             // Names of the probes that egressed from the address
+	ActiveConnections int64     `json:"active_connections"` // Number of connections currently using the address
+	TotalConnections  uint64    `json:"total_connections"`  // Number of connections that have used the address
+	FirstSeen         time.Time `json:"first_seen"`
+	LastSeen          time.Time `json:"last_seen"`
+}
+
+// egressTracker keeps track of the public IP addresses reported by probes
+// and the connections using them.
+type egressTracker struct {
+	mu  sync.Mutex
+	ips map[string]*EgressIpStats
+	now func() time.Time
+}
+
+func newEgressTracker() *egressTracker {
+	return &egressTracker{
+		ips: map[string]*EgressIpStats{},
+		now: time.Now,
+	}
+}
+
+// open records a connection through probe that egresses from ip.
+func (t *egressTracker) open(ip string, probe string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	stats, ok := t.ips[ip]
+	if !ok {
+		stats = &EgressIpStats{Ip: ip, FirstSeen: now}
+		t.ips[ip] = stats
+	}
+	if !slices.Contains(stats.Probes, probe) {
+		stats.Probes = append(stats.Probes, probe)
+	}
+	stats.ActiveConnections++
+	stats.TotalConnections++
+	stats.LastSeen = now
+}
+
+// close records the end of a connection opened with open.
+func (t *egressTracker) close(ip string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := t.ips[ip]
+	stats.ActiveConnections--
+	stats.LastSeen = t.now()
+}
+
+// stats returns the usage of every known address, ordered by address.
+func (t *egressTracker) stats() []EgressIpStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	result := make([]EgressIpStats, 0, len(t.ips))
+	for _, stats := range t.ips {
+		s := *stats
+		s.Probes = slices.Clone(stats.Probes)
+		s.Live = s.ActiveConnections > 0 || now.Sub(s.LastSeen) < egressLiveTime
+		result = append(result, s)
+	}
+	slices.SortFunc(result, func(a, b EgressIpStats) int {
+		return strings.Compare(a.Ip, b.Ip)
+	})
+	return result
+}
+
+// EgressIps returns the usage of every public IP address reported by the
+// probes, ordered by address.
+func (core *Core) EgressIps() []EgressIpStats {
+	return core.egress.stats()
+}
+
+// egressIpOf returns the egress IP reported for a connection, if any.
+func egressIpOf(conn common.Conn) string {
+	if conn, ok := conn.(common.EgressConn); ok {
+		return conn.EgressIp()
+	}
+	return ""
+}
@@ -0,0 +1,105 @@
+package hub
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/isacskoglund/rotox/internal/common"
+	"github.com/isacskoglund/rotox/internal/telemetry"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEgressTracker(t *testing.T) {
+	now := time.Unix(0, 0)
+	tracker := newEgressTracker()
+	tracker.now = func() time.Time { return now }
+
+	tracker.open("198.51.100.2", "a")
+	tracker.open("198.51.100.1", "a")
+	tracker.open("198.51.100.1", "b")
+	tracker.close("198.51.100.2")
+
+	stats := tracker.stats()
+	assert.Len(t, stats, 2)
+	assert.Equal(t, "198.51.100.1", stats[0].Ip)
+	assert.Equal(t, []string{"a", "b"}, stats[0].Probes)
+	assert.Equal(t, int64(2), stats[0].ActiveConnections)
+	assert.Equal(t, uint64(2), stats[0].TotalConnections)
+	assert.Equal(t, "198.51.100.2", stats[1].Ip)
+	assert.Equal(t, int64(0), stats[1].ActiveConnections)
+	assert.True(t, stats[1].Live)
+
+	// Addresses without connections are no longer live after a while.
+	now = now.Add(egressLiveTime)
+	stats = tracker.stats()
+	assert.True(t, stats[0].Live)
+	assert.False(t, stats[1].Live)
+}
+
+// connectionEventRecorder records the connection events a core publishes.
+type connectionEventRecorder struct {
+	mu     sync.Mutex
+	events []telemetry.ConnectionEvent
+}
+
+func (r *connectionEventRecorder) Publish(event telemetry.ConnectionEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+// egressDialer is a targetDialer whose connections know their egress IP.
+type egressDialer struct {
+	targetDialer
+	ip string
+}
+
+type egressConn struct {
+	common.Conn
+	ip string
+}
+
+func (conn egressConn) EgressIp() string {
+	return conn.ip
+}
+
+func (d *egressDialer) Dial(ctx context.Context, address string) (common.Conn, error) {
+	conn, err := d.targetDialer.Dial(ctx, address)
+	return egressConn{Conn: conn, ip: d.ip}, err
+}
+
+func TestCore_ConnectionEvents(t *testing.T) {
+	dialer := &egressDialer{targetDialer: targetDialer{addresses: make(chan string, 1)}, ip: "198.51.100.1"}
+	core := NewCore(slog.New(slog.DiscardHandler), []ProbeSpec{{Name: "a", Dialer: dialer}})
+	recorder := &connectionEventRecorder{}
+	core.tel.connectionEvents.register(recorder)
+
+	client, hubSide := net.Pipe()
+	accept := func() (common.Conn, error) {
+		return &customConn{Reader: hubSide, Writer: hubSide, Closer: hubSide, namer: &customNamer{name: "client"}}, nil
+	}
+	go func() {
+		<-dialer.addresses
+		dialer.target.Close()
+		client.Close()
+	}()
+	assert.NoError(t, core.forward(context.Background(), "example.com:443", routingHints{}, accept))
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if assert.Len(t, recorder.events, 2) {
+		for _, event := range recorder.events {
+			assert.Equal(t, "example.com:443", event.TargetAddress)
+			assert.Equal(t, "a", event.Probe)
+			assert.Equal(t, "198.51.100.1", event.EgressIp)
+		}
+		assert.Equal(t, time.Unix(0, 0), recorder.events[0].ClosedAt)
+		assert.False(t, recorder.events[1].ClosedAt.Before(recorder.events[1].OpenedAt))
+	}
+}
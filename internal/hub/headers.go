@@ -0,0 +1,150 @@
+package hub
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// controlHeaderPrefix is the prefix of the headers through which clients
+// control the hub, e.g. X-Rotox-Session. They never reach the target.
+const controlHeaderPrefix = "X-Rotox-"
+
+// viaPseudonym identifies the hub in added Via headers.
+const viaPseudonym = "rotox"
+
+// HeaderMode is what happens to a forwarding header, like Via, of a request.
+type HeaderMode string
+
+// Modes of forwarding headers.
+const (
+	HeaderKeep   HeaderMode = "keep"   // Forward the header as sent by the client
+	HeaderRemove HeaderMode = "remove" // Remove the header
+	HeaderAdd    HeaderMode = "add"    // Add the hub to the header
+)
+
+// HeaderPolicy decides how the headers of plaintext HTTP requests are
+// rewritten before the requests are forwarded. Hop-by-hop headers, Proxy-*
+// headers and the X-Rotox-* control headers are always removed. The zero
+// policy forwards the other headers as they are.
+type HeaderPolicy struct {
+	Via           HeaderMode        // What happens to the Via header, empty keeps it
+	XForwardedFor HeaderMode        // What happens to the X-Forwarded-For header, empty keeps it
+	Remove        []string          // Headers removed from every request, e.g. "User-Agent"
+	Set           map[string]string // Headers set on every request, replacing those sent by the client
+}
+
+// validate reports whether the policy can be applied.
+func (policy HeaderPolicy) validate() error {
+	for _, mode := range []HeaderMode{policy.Via, policy.XForwardedFor} {
+		switch mode {
+		case "", HeaderKeep, HeaderRemove, HeaderAdd:
+		default:
+			return fmt.Errorf("invalid header mode %q", mode)
+		}
+	}
+	for _, name := range policy.Remove {
+		if !httpguts.ValidHeaderFieldName(name) {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+	}
+	return validateSetHeaders(policy.Set)
+}
+
+// validateSetHeaders reports whether headers can be set on requests.
+func validateSetHeaders(headers map[string]string) error {
+	for name, value := range headers {
+		if !httpguts.ValidHeaderFieldName(name) {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+		if !httpguts.ValidHeaderFieldValue(value) {
+			return fmt.Errorf("invalid value of header %q", name)
+		}
+	}
+	return nil
+}
+
+// apply rewrites the headers of req, which is forwarded to a target matching
+// route. Headers set by the route override those of the policy. route may be
+// nil.
+func (policy *HeaderPolicy) apply(req *http.Request, route *RouteRule) {
+	header := req.Header
+	removeHopByHopHeaders(header)
+	for name := range header {
+		if strings.HasPrefix(name, "Proxy-") || strings.HasPrefix(name, controlHeaderPrefix) {
+			delete(header, name)
+		}
+	}
+
+	switch policy.Via {
+	case HeaderRemove:
+		header.Del("Via")
+	case HeaderAdd:
+		header.Add("Via", fmt.Sprintf("%d.%d %s", req.ProtoMajor, req.ProtoMinor, viaPseudonym))
+	}
+	switch policy.XForwardedFor {
+	case HeaderRemove:
+		header.Del("X-Forwarded-For")
+	case HeaderAdd:
+		clientIp, _, err := net.SplitHostPort(req.RemoteAddr)
+		if err != nil {
+			break
+		}
+		if prior := header.Values("X-Forwarded-For"); len(prior) > 0 {
+			clientIp = strings.Join(prior, ", ") + ", " + clientIp
+		}
+		header.Set("X-Forwarded-For", clientIp)
+	}
+
+	for _, name := range policy.Remove {
+		header.Del(name)
+	}
+	setHeaders(header, policy.Set)
+	if route != nil {
+		setHeaders(header, route.SetHeaders)
+	}
+}
+
+// setHeaders sets the headers of values on header. An empty value removes
+// the header.
+func setHeaders(header http.Header, values map[string]string) {
+	for name, value := range values {
+		if value == "" {
+			header.Del(name)
+		} else {
+			header.Set(name, value)
+		}
+	}
+}
+
+// hopByHopHeaders are the headers that apply to a single connection, and
+// are not forwarded (RFC 9110, section 7.6.1).
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// removeHopByHopHeaders removes the hop-by-hop headers from header,
+// including those listed in its Connection header.
+func removeHopByHopHeaders(header http.Header) {
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				header.Del(name)
+			}
+		}
+	}
+	for _, name := range hopByHopHeaders {
+		header.Del(name)
+	}
+}
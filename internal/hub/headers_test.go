@@ -0,0 +1,74 @@
+package hub
+
+import (
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderPolicy_Apply(t *testing.T) {
+	newRequest := func() *http.Request {
+		req, _ := http.NewRequest("GET", "http://example.com/", nil)
+		req.RemoteAddr = "203.0.113.7:51234"
+		req.Header = http.Header{
+			"Connection":          {"keep-alive, X-Trace"},
+			"X-Trace":             {"1"},
+			"Keep-Alive":          {"timeout=5"},
+			"Proxy-Authorization": {"Basic dXNlcjpwYXNz"},
+			"Proxy-Foo":           {"bar"},
+			"X-Rotox-Session":     {"abc"},
+			"X-Rotox-Other":       {"def"},
+			"Via":                 {"1.1 client-proxy"},
+			"X-Forwarded-For":     {"198.51.100.1"},
+			"Cookie":              {"id=1"},
+			"User-Agent":          {"curl/8.0"},
+			"Accept":              {"*/*"},
+		}
+		return req
+	}
+
+	// Control and hop-by-hop headers are removed even by the zero policy.
+	req := newRequest()
+	(&HeaderPolicy{}).apply(req, nil)
+	assert.Equal(t, http.Header{
+		"Via":             {"1.1 client-proxy"},
+		"X-Forwarded-For": {"198.51.100.1"},
+		"Cookie":          {"id=1"},
+		"User-Agent":      {"curl/8.0"},
+		"Accept":          {"*/*"},
+	}, req.Header)
+
+	req = newRequest()
+	(&HeaderPolicy{
+		Via:           HeaderAdd,
+		XForwardedFor: HeaderAdd,
+		Remove:        []string{"cookie"},
+		Set:           map[string]string{"User-Agent": "rotox", "Accept-Language": "en"},
+	}).apply(req, &RouteRule{SetHeaders: map[string]string{"Accept-Language": "de", "Accept": ""}})
+	assert.Equal(t, http.Header{
+		"Via":             {"1.1 client-proxy", "1.1 rotox"},
+		"X-Forwarded-For": {"198.51.100.1, 203.0.113.7"},
+		"User-Agent":      {"rotox"},
+		"Accept-Language": {"de"},
+	}, req.Header)
+
+	req = newRequest()
+	(&HeaderPolicy{Via: HeaderRemove, XForwardedFor: HeaderRemove}).apply(req, nil)
+	assert.Empty(t, req.Header.Values("Via"))
+	assert.Empty(t, req.Header.Values("X-Forwarded-For"))
+}
+
+func TestCore_SetHeaderPolicyInvalid(t *testing.T) {
+	core := NewCore(slog.New(slog.DiscardHandler), []ProbeSpec{{Name: "a", Dialer: &stubDialer{"a"}}})
+	for _, invalid := range []HeaderPolicy{
+		{Via: "append"},
+		{XForwardedFor: "drop"},
+		{Remove: []string{"Bad Name"}},
+		{Set: map[string]string{"X-Bad": "line\nbreak"}},
+	} {
+		assert.Error(t, core.SetHeaderPolicy(invalid), invalid)
+	}
+	assert.NoError(t, core.SetHeaderPolicy(HeaderPolicy{Via: HeaderKeep}))
+}
@@ -0,0 +1,223 @@
+package hub
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/isacskoglund/rotox/internal/common"
+	"github.com/isacskoglund/rotox/internal/telemetry"
+)
+
+// HealthConfig configures active health checking and outlier ejection of probes.
+// Zero values are replaced by the defaults of DefaultHealthConfig.
+type HealthConfig struct {
+	Interval           time.Duration // Time between two health checks of a probe
+	Timeout            time.Duration // Maximum duration of a single health check
+	FailureThreshold   int           // Consecutive failed health checks before a probe is ejected
+	DialErrorThreshold int           // Consecutive failed dials before a probe is ejected
+	BaseEjectionTime   time.Duration // Duration of the first ejection, doubled for each consecutive ejection
+	MaxEjectionTime    time.Duration // Upper bound of the ejection duration
+}
+
+// DefaultHealthConfig returns the default health checking configuration.
+func DefaultHealthConfig() HealthConfig {
+	return HealthConfig{
+		Interval:           10 * time.Second,
+		Timeout:            2 * time.Second,
+		FailureThreshold:   3,
+		DialErrorThreshold: 5,
+		BaseEjectionTime:   30 * time.Second,
+		MaxEjectionTime:    5 * time.Minute,
+	}
+}
+
+func (cfg HealthConfig) withDefaults() HealthConfig {
+	def := DefaultHealthConfig()
+	if cfg.Interval <= 0 {
+		cfg.Interval = def.Interval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = def.Timeout
+	}
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = def.FailureThreshold
+	}
+	if cfg.DialErrorThreshold <= 0 {
+		cfg.DialErrorThreshold = def.DialErrorThreshold
+	}
+	if cfg.BaseEjectionTime <= 0 {
+		cfg.BaseEjectionTime = def.BaseEjectionTime
+	}
+	if cfg.MaxEjectionTime <= 0 {
+		cfg.MaxEjectionTime = def.MaxEjectionTime
+	}
+	return cfg
+}
+
+// ejectionTime returns how long a probe is ejected the n:th consecutive time.
+func (cfg HealthConfig) ejectionTime(n int) time.Duration {
+	d := cfg.BaseEjectionTime
+	for i := 1; i < n && d < cfg.MaxEjectionTime; i++ {
+		d *= 2
+	}
+	return min(d, cfg.MaxEjectionTime)
+}
+
+// probeHealth tracks the health of a single probe.
+//
+// A probe is ejected after too many consecutive failed health checks or
+// dials. Once the ejection time has passed, the probe is on probation: it is
+// selectable again, and is re-admitted on its next success or ejected for
+// twice as long on its next failure.
+type probeHealth struct {
+	mu            sync.Mutex // Protects the fields below
+	ejected       bool       // Whether the probe is ejected (or on probation)
+	ejectedUntil  time.Time  // When the current ejection ends
+	ejections     int        // Number of consecutive ejections
+	checkFailures int        // Number of consecutive failed health checks
+	dialFailures  int        // Number of consecutive failed dials
+}
+
+// available reports whether the probe may be selected at the given time.
+func (h *probeHealth) available(now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return !h.ejected || !now.Before(h.ejectedUntil)
+}
+
+// healthReport is the outcome of a health check or a dial.
+type healthReport struct {
+	isCheck bool  // Whether the outcome is from a health check rather than a dial
+	err     error // Error if the check or dial failed
+}
+
+// report records the outcome of a health check or a dial. If it changes
+// the state of the probe, the new state and a reason are returned.
+func (h *probeHealth) report(
+	now time.Time,
+	cfg HealthConfig,
+	report healthReport,
+) (telemetry.ProbeState, string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	onProbation := h.ejected && !now.Before(h.ejectedUntil)
+
+	if report.err == nil {
+		h.checkFailures = 0
+		h.dialFailures = 0
+		if !onProbation {
+			return "", "", false
+		}
+		h.ejected = false
+		h.ejections = 0
+		return telemetry.ProbeHealthy, "probe recovered after ejection", true
+	}
+
+	var reason string
+	if report.isCheck {
+		h.checkFailures++
+		reason = fmt.Sprintf("%d consecutive failed health checks: %v", h.checkFailures, report.err)
+	} else {
+		h.dialFailures++
+		reason = fmt.Sprintf("%d consecutive failed dials: %v", h.dialFailures, report.err)
+	}
+	if h.ejected && !onProbation {
+		return "", "", false
+	}
+	if !onProbation && h.checkFailures < cfg.FailureThreshold && h.dialFailures < cfg.DialErrorThreshold {
+		return "", "", false
+	}
+
+	h.ejected = true
+	h.ejections++
+	h.ejectedUntil = now.Add(cfg.ejectionTime(h.ejections))
+	h.checkFailures = 0
+	h.dialFailures = 0
+	return telemetry.ProbeEjected, reason, true
+}
+
+// StartHealthChecks enables outlier ejection and starts checking every probe
+// that implements common.Pinger on an interval until ctx is canceled.
+// It must be called before the core starts forwarding requests.
+func (core *Core) StartHealthChecks(ctx context.Context, cfg HealthConfig) {
+	cfg = cfg.withDefaults()
+	core.health = &cfg
+
+	go func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				core.checkProbes(ctx)
+			}
+		}
+	}()
+}
+
+// checkProbes performs a health check of every probe concurrently
+// and waits for all of them to finish.
+func (core *Core) checkProbes(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, probe := range core.Probes() {
+		pinger, ok := probe.dialer.(common.Pinger)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, core.health.Timeout)
+			defer cancel()
+			err := pinger.Ping(checkCtx)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				probe.recordError(time.Now(), err)
+			}
+			core.reportHealth(ctx, probe, healthReport{isCheck: true, err: err})
+		}()
+	}
+	wg.Wait()
+}
+
+// reportHealth records the outcome of a health check or dial, and logs and
+// publishes any resulting change of the probe's state.
+// It does nothing unless health checking is enabled.
+func (core *Core) reportHealth(ctx context.Context, probe *Probe, report healthReport) {
+	if core.health == nil {
+		return
+	}
+	now := time.Now()
+	state, reason, changed := probe.health.report(now, *core.health, report)
+	if !changed {
+		return
+	}
+
+	level := slog.LevelInfo
+	if state == telemetry.ProbeEjected {
+		level = slog.LevelWarn
+	}
+	core.logger.LogAttrs(
+		ctx,
+		level,
+		"Probe state changed",
+		slog.String("probe", probe.Name()),
+		slog.String("state", string(state)),
+		slog.String("reason", reason),
+	)
+	core.tel.ProbePublisher().Publish(
+		telemetry.ProbeEvent{
+			Probe:     probe.Name(),
+			State:     state,
+			Reason:    reason,
+			ChangedAt: now,
+		},
+	)
+}
@@ -0,0 +1,78 @@
+package hub
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/isacskoglund/rotox/internal/telemetry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthConfig_EjectionTime(t *testing.T) {
+	cfg := HealthConfig{
+		BaseEjectionTime: 10 * time.Second,
+		MaxEjectionTime:  time.Minute,
+	}
+	assert.Equal(t, 10*time.Second, cfg.ejectionTime(1))
+	assert.Equal(t, 20*time.Second, cfg.ejectionTime(2))
+	assert.Equal(t, 40*time.Second, cfg.ejectionTime(3))
+	assert.Equal(t, time.Minute, cfg.ejectionTime(4))
+	assert.Equal(t, time.Minute, cfg.ejectionTime(100))
+}
+
+func TestProbeHealth(t *testing.T) {
+	cfg := HealthConfig{
+		FailureThreshold:   2,
+		DialErrorThreshold: 3,
+		BaseEjectionTime:   10 * time.Second,
+		MaxEjectionTime:    time.Minute,
+	}
+	failedCheck := healthReport{isCheck: true, err: errors.New("unavailable")}
+	failedDial := healthReport{err: errors.New("unavailable")}
+	success := healthReport{isCheck: true}
+	now := time.Unix(0, 0)
+	var h probeHealth
+
+	// Failures below the threshold keep the probe available.
+	_, _, changed := h.report(now, cfg, failedCheck)
+	assert.False(t, changed)
+	_, _, changed = h.report(now, cfg, failedDial)
+	assert.False(t, changed)
+	_, _, changed = h.report(now, cfg, failedDial)
+	assert.False(t, changed)
+	assert.True(t, h.available(now))
+
+	// A success resets the counters.
+	_, _, changed = h.report(now, cfg, success)
+	assert.False(t, changed)
+	_, _, changed = h.report(now, cfg, failedCheck)
+	assert.False(t, changed)
+
+	// Reaching the threshold ejects the probe.
+	state, _, changed := h.report(now, cfg, failedCheck)
+	assert.True(t, changed)
+	assert.Equal(t, telemetry.ProbeEjected, state)
+	assert.False(t, h.available(now.Add(9*time.Second)))
+
+	// Successes during the ejection do not re-admit the probe.
+	_, _, changed = h.report(now.Add(5*time.Second), cfg, success)
+	assert.False(t, changed)
+	assert.False(t, h.available(now.Add(9*time.Second)))
+
+	// A failure on probation ejects the probe for twice as long.
+	now = now.Add(10 * time.Second)
+	assert.True(t, h.available(now))
+	state, _, changed = h.report(now, cfg, failedDial)
+	assert.True(t, changed)
+	assert.Equal(t, telemetry.ProbeEjected, state)
+	assert.False(t, h.available(now.Add(19*time.Second)))
+
+	// A success on probation re-admits the probe.
+	now = now.Add(20 * time.Second)
+	state, _, changed = h.report(now, cfg, success)
+	assert.True(t, changed)
+	assert.Equal(t, telemetry.ProbeHealthy, state)
+	assert.True(t, h.available(now))
+	assert.Equal(t, 0, h.ejections)
+}
@@ -0,0 +1,167 @@
+package hub
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sessionHeader is the request header clients can use to name a sticky session.
+const sessionHeader = "X-Rotox-Session"
+
+// maxSessionTtl is the longest session lifetime a client may ask for.
+const maxSessionTtl = 24 * time.Hour
+
+// routingHints carries client provided preferences for how a request is routed.
+type routingHints struct {
+	session    string        // Sticky session id, empty if the request is not part of a session
+	sessionTtl time.Duration // Lifetime of a new session, zero uses the configured lifetime
+	group      string        // Probe group to use, empty allows every group
+	labels     LabelSelector // Labels the probe must match
+	exclude    []string      // Names of probes not to use
+}
+
+// allows reports whether the hints allow probe to be used.
+func (hints routingHints) allows(probe *Probe) bool {
+	return (hints.group == "" || probe.group == hints.group) &&
+		hints.labels.Matches(probe.labels) &&
+		!slices.Contains(hints.exclude, probe.name)
+}
+
+// restricted reports whether the hints limit which probes may be used.
+func (hints routingHints) restricted() bool {
+	return hints.group != "" || len(hints.labels) > 0 || len(hints.exclude) > 0
+}
+
+// UsernameGrammar describes how clients encode routing hints in the proxy
+// username. A username consists of the actual username, used for
+// authentication, followed by key/value parameters, all joined by the
+// separator. For example, with the default grammar the username
+// "team-region-eu-session-42-ttl-300" authenticates as "team", and asks for a
+// probe in region "eu" and a sticky session "42" lasting 300 seconds.
+//
+// The actual username ends before the first key. A value extends up to the
+// next key, so values (e.g. session ids) may contain the separator.
+//
+// The zero grammar encodes no hints, usernames are used as they are.
+type UsernameGrammar struct {
+	Separator  string // Separates the username, keys and values
+	Group      string // Key of the probe group to use
+	Region     string // Key of the region label of the probe to use
+	Labels     string // Key of a label selector the probe must match
+	Session    string // Key of the sticky session id
+	SessionTtl string // Key of the lifetime of a new sticky session, in seconds
+	Exclude    string // Key of the name of a probe not to use, may be repeated
+}
+
+// DefaultUsernameGrammar is a grammar with common keys. Like any grammar, it
+// is only used once it is set, as its keys may be part of existing usernames.
+var DefaultUsernameGrammar = UsernameGrammar{
+	Separator:  "-",
+	Group:      "group",
+	Region:     "region",
+	Labels:     "labels",
+	Session:    "session",
+	SessionTtl: "ttl",
+	Exclude:    "exclude",
+}
+
+// validate checks that the grammar can be parsed unambiguously.
+// Empty keys disable the corresponding hint.
+func (g UsernameGrammar) validate() error {
+	if g == (UsernameGrammar{}) {
+		return nil
+	}
+	if g.Separator == "" {
+		return errors.New("username separator must not be empty")
+	}
+	var keys []string
+	for _, key := range g.keys() {
+		if key == "" {
+			continue
+		}
+		if strings.Contains(key, g.Separator) {
+			return fmt.Errorf("username key %q must not contain the separator", key)
+		}
+		if slices.Contains(keys, key) {
+			return fmt.Errorf("username key %q is used more than once", key)
+		}
+		keys = append(keys, key)
+	}
+	return nil
+}
+
+// keys returns the keys of the grammar, including disabled (empty) ones.
+func (g UsernameGrammar) keys() []string {
+	return []string{g.Group, g.Region, g.Labels, g.Session, g.SessionTtl, g.Exclude}
+}
+
+// parse splits a proxy username into the actual username and the routing
+// hints encoded in it. Usernames without parameters have no hints. The actual
+// username is returned even if the parameters are invalid, so that the client
+// can be authenticated before it is told about them.
+func (g UsernameGrammar) parse(username string) (string, routingHints, error) {
+	var hints routingHints
+	if g.Separator == "" {
+		return username, hints, nil
+	}
+	tokens := strings.Split(username, g.Separator)
+	isKey := func(token string) bool {
+		return token != "" && slices.Contains(g.keys(), token)
+	}
+	start := slices.IndexFunc(tokens, isKey)
+	if start < 0 {
+		return username, hints, nil
+	}
+	user := strings.Join(tokens[:start], g.Separator)
+
+	seen := map[string]bool{}
+	for i := start; i < len(tokens); {
+		key := tokens[i]
+		end := i + 1
+		for end < len(tokens) && !isKey(tokens[end]) {
+			end++
+		}
+		value := strings.Join(tokens[i+1:end], g.Separator)
+		i = end
+		if value == "" {
+			return user, routingHints{}, fmt.Errorf("missing value for username parameter %q", key)
+		}
+		if seen[key] && key != g.Exclude {
+			return user, routingHints{}, fmt.Errorf("username parameter %q is given more than once", key)
+		}
+		seen[key] = true
+
+		switch key {
+		case g.Group:
+			hints.group = value
+		case g.Region:
+			hints.labels = append(hints.labels, labelRequirement{
+				key:      "region",
+				operator: labelEquals,
+				values:   []string{value},
+			})
+		case g.Labels:
+			selector, err := ParseLabelSelector(value)
+			if err != nil {
+				return user, routingHints{}, err
+			}
+			hints.labels = append(hints.labels, selector...)
+		case g.Session:
+			hints.session = value
+		case g.SessionTtl:
+			seconds, err := strconv.Atoi(value)
+			ttl := time.Duration(seconds) * time.Second
+			if err != nil || ttl <= 0 || ttl > maxSessionTtl {
+				return user, routingHints{}, fmt.Errorf("invalid session ttl %q", value)
+			}
+			hints.sessionTtl = ttl
+		case g.Exclude:
+			hints.exclude = append(hints.exclude, value)
+		}
+	}
+	return user, hints, nil
+}
@@ -0,0 +1,125 @@
+package hub
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/isacskoglund/rotox/internal/common"
+	"github.com/isacskoglund/rotox/internal/fault"
+	"github.com/stretchr/testify/assert"
+)
+
+func regionSelector(region string) LabelSelector {
+	return LabelSelector{{key: "region", operator: labelEquals, values: []string{region}}}
+}
+
+func TestUsernameGrammar_Parse(t *testing.T) {
+	tests := []struct {
+		username string
+		user     string
+		hints    routingHints
+		err      bool
+	}{
+		{username: "", user: ""},
+		{username: "team", user: "team"},
+		{username: "my-team", user: "my-team"},
+		{username: "user-session-abc123", user: "user", hints: routingHints{session: "abc123"}},
+		{username: "user-session-abc-123", user: "user", hints: routingHints{session: "abc-123"}},
+		{
+			username: "team-region-eu-session-42-ttl-300",
+			user:     "team",
+			hints:    routingHints{labels: regionSelector("eu"), session: "42", sessionTtl: 300 * time.Second},
+		},
+		{
+			username: "team-group-vm-exclude-a:8000-exclude-b:8000",
+			user:     "team",
+			hints:    routingHints{group: "vm", exclude: []string{"a:8000", "b:8000"}},
+		},
+		{username: "region-eu", user: "", hints: routingHints{labels: regionSelector("eu")}},
+		{
+			username: "team-labels-provider!=gcp,ipv6-region-eu",
+			user:     "team",
+			hints: routingHints{labels: LabelSelector{
+				{key: "provider", operator: labelNotEquals, values: []string{"gcp"}},
+				{key: "ipv6", operator: labelExists},
+				{key: "region", operator: labelEquals, values: []string{"eu"}},
+			}},
+		},
+		{username: "team-labels-a=", err: true},
+		{username: "team-region", err: true},
+		{username: "team-region-eu-region-us", err: true},
+		{username: "team-session-42-ttl-soon", err: true},
+		{username: "team-session-42-ttl-0", err: true},
+		{username: "team-session-42-ttl-86401", err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.username, func(t *testing.T) {
+			user, hints, err := DefaultUsernameGrammar.parse(test.username)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.user, user)
+			assert.Equal(t, test.hints, hints)
+		})
+	}
+
+	// Keys and the separator are configurable.
+	grammar := UsernameGrammar{Separator: "_", Session: "sid", Region: "country"}
+	user, hints, err := grammar.parse("team_country_de_sid_x-1_region_eu")
+	assert.NoError(t, err)
+	assert.Equal(t, "team", user)
+	assert.Equal(t, routingHints{labels: regionSelector("de"), session: "x-1_region_eu"}, hints)
+}
+
+func TestUsernameGrammar_Validate(t *testing.T) {
+	assert.NoError(t, DefaultUsernameGrammar.validate())
+	assert.NoError(t, UsernameGrammar{}.validate())
+	assert.Error(t, UsernameGrammar{Session: "session"}.validate())
+	assert.Error(t, UsernameGrammar{Separator: "-", Session: "s-id"}.validate())
+	assert.Error(t, UsernameGrammar{Separator: "-", Session: "s", Region: "s"}.validate())
+}
+
+func TestCore_ParseUsernameWithoutGrammar(t *testing.T) {
+	core := NewCore(slog.New(slog.DiscardHandler), []ProbeSpec{{Name: "a", Dialer: &stubDialer{"a"}}})
+
+	// Usernames are used as they are until a grammar is set.
+	for _, username := range []string{"dev-group", "ops-region-eu", "user-session-abc123"} {
+		user, hints, err := core.parseUsername(username)
+		assert.NoError(t, err, username)
+		assert.Equal(t, username, user)
+		assert.Equal(t, routingHints{}, hints, username)
+	}
+
+	assert.NoError(t, core.SetUsernameGrammar(DefaultUsernameGrammar))
+	user, hints, err := core.parseUsername("ops-region-eu")
+	assert.NoError(t, err)
+	assert.Equal(t, "ops", user)
+	assert.Equal(t, routingHints{labels: regionSelector("eu")}, hints)
+}
+
+func TestCore_SelectProbeWithHints(t *testing.T) {
+	ctx := context.Background()
+	core := NewCore(slog.New(slog.DiscardHandler), []ProbeSpec{
+		{Name: "a", Group: "vm", Labels: map[string]string{"region": "eu"}, Dialer: &stubDialer{"a"}},
+		{Name: "b", Group: "vm", Labels: map[string]string{"region": "us"}, Dialer: &stubDialer{"b"}},
+		{Name: "c", Group: "cloud-run", Labels: map[string]string{"region": "eu", "provider": "gcp"}, Dialer: &stubDialer{"c"}},
+	})
+	selectName := func(hints routingHints) string {
+		probe, err := core.selectProbe(ctx, "example.com:443", hints, nil)
+		if err != nil {
+			return string(fault.Code[common.ForwardErrorCode](err))
+		}
+		core.releaseProbe(probe)
+		return probe.Name()
+	}
+
+	assert.Equal(t, "c", selectName(routingHints{group: "cloud-run"}))
+	assert.Equal(t, "b", selectName(routingHints{labels: regionSelector("us")}))
+	assert.Equal(t, "a", selectName(routingHints{group: "vm", labels: regionSelector("eu")}))
+	assert.Equal(t, "c", selectName(routingHints{labels: regionSelector("eu"), exclude: []string{"a"}}))
+	assert.Equal(t, "PROBE_UNAVAILABLE", selectName(routingHints{labels: regionSelector("ap")}))
+}
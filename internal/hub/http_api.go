@@ -1,13 +1,20 @@
 package hub
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"net"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/isacskoglund/rotox/internal/common"
@@ -22,8 +29,10 @@ const regularDefaultPort = "80"
 // It handles both regular HTTP requests and HTTP CONNECT tunnel requests,
 // forwarding them through the hub's probe network.
 type HttpApi struct {
-	logger *slog.Logger // Logger for HTTP API operations
-	core   *Core        // Core hub service for request forwarding
+	logger *slog.Logger  // Logger for HTTP API operations
+	core   *Core         // Core hub service for request forwarding
+	auth   Authenticator // Verifies client credentials, nil disables authentication
+	labels LabelSelector // Default label selector for requests that do not send one
 }
 
 // NewHttpApi creates a new HTTP API instance that serves proxy requests.
@@ -39,6 +48,34 @@ func NewHttpApi(
 	}
 }
 
+// SetAuthenticator enables client authentication through the
+// "Proxy-Authorization: Basic" header, verified by auth.
+func (api *HttpApi) SetAuthenticator(auth Authenticator) {
+	api.auth = auth
+}
+
+// SetLabelSelector sets the label selector used for requests that send
+// neither the X-Rotox-Labels header nor labels in the username.
+func (api *HttpApi) SetLabelSelector(selector LabelSelector) {
+	api.labels = selector
+}
+
+// Serve accepts incoming connections on the listener and serves proxy
+// requests over HTTP/1.1 and HTTP/2. It blocks until the listener fails.
+// HTTP/2 is negotiated through ALPN on TLS listeners, and is used with prior
+// knowledge (h2c) otherwise.
+func (api *HttpApi) Serve(lis net.Listener) error {
+	protocols := &http.Protocols{}
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+	srv := &http.Server{
+		Handler:   api,
+		Protocols: protocols,
+	}
+	return srv.Serve(lis)
+}
+
 func (api *HttpApi) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	traceId, err := uuid.NewRandom()
 	if err != nil {
@@ -46,6 +83,52 @@ func (api *HttpApi) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 	ctx := tracing.WithTraceId(req.Context(), traceId.String())
 	req = req.WithContext(ctx)
+
+	hints, ok, err := api.authenticate(req)
+	if err != nil {
+		api.logger.LogAttrs(
+			ctx,
+			slog.LevelInfo,
+			"Rejecting request with invalid routing hints",
+			slog.Any("error", err),
+		)
+		proxyError{
+			status:  http.StatusBadRequest,
+			code:    errorInvalidRoutingHints,
+			message: err.Error(),
+			traceId: traceId.String(),
+		}.serve(w)
+		return
+	}
+	if !ok {
+		api.logger.LogAttrs(
+			ctx,
+			slog.LevelInfo,
+			"Rejecting request with missing or invalid proxy credentials",
+		)
+		w.Header().Set("Proxy-Authenticate", fmt.Sprintf("Basic realm=%q", proxyAuthRealm))
+		proxyError{
+			status:  http.StatusProxyAuthRequired,
+			code:    errorProxyAuthRequired,
+			traceId: traceId.String(),
+		}.serve(w)
+		return
+	}
+	// The credentials and hints are meant for the proxy only, never for the target.
+	req.Header.Del("Proxy-Authorization")
+	req.Header.Del(sessionHeader)
+	req.Header.Del(labelSelectorHeader)
+
+	if req.Method != "CONNECT" && req.Header.Get("Upgrade") == "" {
+		api.handlePlain(w, req, hints)
+		return
+	}
+	if req.Method == "CONNECT" && req.ProtoMajor == 2 {
+		// HTTP/2 connections cannot be hijacked, as they multiplex streams.
+		api.handleConnectStream(w, req, hints)
+		return
+	}
+
 	conn, err := hijack(w, "client")
 	if err != nil {
 		api.logger.LogAttrs(
@@ -54,20 +137,51 @@ func (api *HttpApi) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 			"Error when hijacking connection",
 			slog.Any("error", err),
 		)
-		http.Error(w, "", http.StatusInternalServerError)
+		newProxyError(ctx, http.StatusInternalServerError, err, "").serve(w)
 		return
 	}
 	defer conn.Close()
 
 	if req.Method == "CONNECT" {
-		api.handleConnect(conn, req)
+		api.handleConnect(conn, req, hints)
 	} else {
-		api.handlePlain(conn, req)
+		api.handleUpgrade(conn, req, hints)
 	}
 
 }
 
-func (api *HttpApi) handleConnect(conn common.Conn, req *http.Request) {
+// authenticate reports whether the request carries valid proxy credentials.
+// All requests are accepted when no authenticator is set.
+// It also returns the routing hints found in the username and headers, or an
+// error if the username does not follow the grammar or a header is malformed.
+// Errors are only returned for authenticated requests, so that strangers
+// learn nothing about the grammar.
+func (api *HttpApi) authenticate(req *http.Request) (routingHints, bool, error) {
+	username, password, found := parseProxyAuthorization(req.Header.Get("Proxy-Authorization"))
+	username, hints, err := api.core.parseUsername(username)
+	if api.auth != nil && (!found || !api.auth.Authenticate(username, password)) {
+		return routingHints{}, false, nil
+	}
+	if err != nil {
+		return routingHints{}, false, err
+	}
+	if session := req.Header.Get(sessionHeader); session != "" {
+		hints.session = session
+	}
+	if header := req.Header.Get(labelSelectorHeader); header != "" {
+		selector, err := ParseLabelSelector(header)
+		if err != nil {
+			return routingHints{}, false, err
+		}
+		hints.labels = append(hints.labels, selector...)
+	}
+	if len(hints.labels) == 0 {
+		hints.labels = api.labels
+	}
+	return hints, true, nil
+}
+
+func (api *HttpApi) handleConnect(conn common.Conn, req *http.Request, hints routingHints) {
 	ctx := req.Context()
 	api.logger.LogAttrs(
 		ctx,
@@ -86,12 +200,42 @@ func (api *HttpApi) handleConnect(conn common.Conn, req *http.Request) {
 	err := api.core.forward(
 		ctx,
 		req.URL.Host,
+		hints,
 		accept,
 	)
 	api.handleForwardError(ctx, conn, err)
 }
 
-func (api *HttpApi) handlePlain(conn common.Conn, req *http.Request) {
+// handleConnectStream handles a CONNECT request over HTTP/2, tunneling
+// through the request and response bodies of its stream.
+func (api *HttpApi) handleConnectStream(w http.ResponseWriter, req *http.Request, hints routingHints) {
+	ctx := req.Context()
+	api.logger.LogAttrs(
+		ctx,
+		slog.LevelInfo,
+		"Handling CONNECT request",
+		slog.String("proto", req.Proto),
+	)
+
+	conn := newStreamConn(w, req.Body)
+	defer conn.Close()
+	accept := func() (common.Conn, error) {
+		if err := conn.accept(); err != nil {
+			return nil, fmt.Errorf("failed to send 200 OK to client: %w", err)
+		}
+		return conn, nil
+	}
+
+	err := api.core.forward(ctx, req.Host, hints, accept)
+	if perr, ok := api.describeForwardError(ctx, err); ok && !conn.isAccepted() {
+		perr.serve(w)
+	}
+}
+
+// handlePlain forwards a regular request and writes the target's response.
+// Every request on a keep-alive client connection is handled separately, and
+// thereby routed on its own.
+func (api *HttpApi) handlePlain(w http.ResponseWriter, req *http.Request, hints routingHints) {
 	ctx := req.Context()
 	api.logger.LogAttrs(
 		ctx,
@@ -99,6 +243,124 @@ func (api *HttpApi) handlePlain(conn common.Conn, req *http.Request) {
 		"Handling regular request",
 	)
 
+	// The server sends "100 Continue" to the client once the body is read,
+	// so the target is not asked to.
+	req.Header.Del("Expect")
+	targetAddress := plainTargetAddress(req)
+	api.core.rewriteHeaders(req, targetAddress)
+
+	conn, done, err := api.dialPlain(ctx, targetAddress, hints)
+	if err != nil {
+		if perr, ok := api.describeForwardError(ctx, err); ok {
+			perr.serve(w)
+		}
+		return
+	}
+	written := make(chan error, 1)
+	defer func() {
+		done()
+		<-written
+	}()
+	go func() {
+		written <- req.WriteProxy(conn)
+	}()
+
+	resp, err := readResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		api.logger.LogAttrs(
+			ctx,
+			slog.LevelInfo,
+			"Failed to read response from target.",
+			slog.Any("error", err),
+		)
+		proxyError{
+			status:  http.StatusBadGateway,
+			code:    errorInvalidResponse,
+			traceId: tracing.GetTraceId(ctx),
+		}.serve(w)
+		return
+	}
+	defer resp.Body.Close()
+
+	removeHopByHopHeaders(resp.Header)
+	maps.Copy(w.Header(), resp.Header)
+	w.WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		api.logger.LogAttrs(
+			ctx,
+			slog.LevelDebug,
+			"Failed to copy response body to client.",
+			slog.Any("error", err),
+		)
+		return
+	}
+	for key, values := range resp.Trailer {
+		w.Header()[http.TrailerPrefix+key] = values
+	}
+}
+
+// dialPlain forwards a connection of its own to targetAddress for a single
+// regular request. The returned connection is the client side of the
+// forwarded connection. done closes it and waits for the forwarding to end.
+func (api *HttpApi) dialPlain(
+	ctx context.Context,
+	targetAddress string,
+	hints routingHints,
+) (net.Conn, func(), error) {
+	clientSide, hubSide := net.Pipe()
+	accepted := make(chan struct{})
+	forwarded := make(chan error, 1)
+	accept := func() (common.Conn, error) {
+		close(accepted)
+		return &customConn{
+			Reader: hubSide,
+			Writer: hubSide,
+			Closer: hubSide,
+			namer:  &customNamer{name: "client"},
+		}, nil
+	}
+	go func() {
+		forwarded <- api.core.forward(ctx, targetAddress, hints, accept)
+	}()
+
+	select {
+	case <-accepted:
+	case err := <-forwarded:
+		clientSide.Close()
+		return nil, nil, err
+	}
+	done := func() {
+		clientSide.Close()
+		if err := <-forwarded; err != nil {
+			api.logger.LogAttrs(
+				ctx,
+				slog.LevelError,
+				"Failed to forward regular request",
+				slog.Any("error", err),
+			)
+		}
+	}
+	return clientSide, done, nil
+}
+
+// handleUpgrade forwards a request upgrading the client connection to
+// another protocol, e.g. WebSocket. After the request, the rest of the client
+// connection is relayed to the target as is.
+func (api *HttpApi) handleUpgrade(conn common.Conn, req *http.Request, hints routingHints) {
+	ctx := req.Context()
+	api.logger.LogAttrs(
+		ctx,
+		slog.LevelDebug,
+		"Handling upgrade request",
+	)
+
+	// The upgrade headers are hop-by-hop, but meant for the target here.
+	targetAddress := plainTargetAddress(req)
+	upgrade := req.Header.Get("Upgrade")
+	api.core.rewriteHeaders(req, targetAddress)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", upgrade)
+
 	req.Body = http.NoBody
 	var headBuf bytes.Buffer
 	if err := req.WriteProxy(&headBuf); err != nil {
@@ -108,7 +370,7 @@ func (api *HttpApi) handlePlain(conn common.Conn, req *http.Request) {
 			"Failed to write req (without body) to buffer",
 			slog.Any("error", err),
 		)
-		writeHttpError(conn, http.StatusInternalServerError)
+		newProxyError(ctx, http.StatusInternalServerError, err, "").write(conn)
 		return
 	}
 	reader := io.MultiReader(&headBuf, conn)
@@ -124,6 +386,12 @@ func (api *HttpApi) handlePlain(conn common.Conn, req *http.Request) {
 		return wrappedConn, nil
 	}
 
+	err := api.core.forward(ctx, targetAddress, hints, accept)
+	api.handleForwardError(ctx, conn, err)
+}
+
+// plainTargetAddress returns the address of the target of a regular request.
+func plainTargetAddress(req *http.Request) string {
 	host := req.URL.Host
 	if host == "" {
 		host = req.Host
@@ -131,14 +399,57 @@ func (api *HttpApi) handlePlain(conn common.Conn, req *http.Request) {
 	if _, _, err := net.SplitHostPort(host); err != nil {
 		host = net.JoinHostPort(host, regularDefaultPort)
 	}
-	err := api.core.forward(ctx, host, accept)
-	api.handleForwardError(ctx, conn, err)
+	return host
+}
+
+// readResponse reads the response to req, skipping interim responses other
+// than "101 Switching Protocols".
+func readResponse(r *bufio.Reader, req *http.Request) (*http.Response, error) {
+	for {
+		resp, err := http.ReadResponse(r, req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= http.StatusOK || resp.StatusCode == http.StatusSwitchingProtocols {
+			return resp, nil
+		}
+	}
 }
 
+// handleForwardError describes err to the client of a hijacked connection.
 func (api *HttpApi) handleForwardError(ctx context.Context, conn common.Conn, err error) {
+	if perr, ok := api.describeForwardError(ctx, err); ok {
+		perr.write(conn)
+	}
+}
+
+// describeForwardError logs an error returned by Core.forward at the level
+// of its code and describes it for the client. It returns false if there is
+// nothing to describe.
+func (api *HttpApi) describeForwardError(ctx context.Context, err error) (proxyError, bool) {
 	if err == nil {
-		return
+		return proxyError{}, false
+	}
+	// Waits for a rate limit, a cooldown or the queue end with the error of
+	// the context, which carries no code.
+	if fault.Code[common.ForwardErrorCode](err) == common.ForwardUnknown {
+		if errors.Is(err, context.Canceled) {
+			api.logger.LogAttrs(
+				ctx,
+				slog.LevelInfo,
+				"Client left before the connection was forwarded.",
+				slog.Any("error", err),
+			)
+			return proxyError{}, false
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = fault.Wrap(err, "", common.ForwardTimeout)
+		}
 	}
+	// Only errors of the hub's policies are explained to the client, other
+	// messages may reveal details of the probes.
+	var status int
+	var message string
 	switch fault.Code[common.ForwardErrorCode](err) {
 	case common.ForwardUnknown, common.ForwardInternal:
 		api.logger.LogAttrs(
@@ -147,35 +458,181 @@ func (api *HttpApi) handleForwardError(ctx context.Context, conn common.Conn, er
 			"Unknown error when forwarding connection",
 			slog.Any("error", err),
 		)
-		writeHttpError(conn, http.StatusInternalServerError)
+		status = http.StatusInternalServerError
 	case common.ForwardFailedToResolveHost:
 		api.logger.LogAttrs(
 			ctx,
 			slog.LevelInfo,
 			"Failed to resolve target host when forwarding connection.",
 			slog.Any("error", err),
+			dialInfoAttr(dialInfoOfError(err)),
 		)
-		writeHttpError(conn, 502)
-	case common.ForwardHostUnreachable:
+		status = http.StatusBadGateway
+	case common.ForwardConnectionRefused:
+		api.logger.LogAttrs(
+			ctx,
+			slog.LevelInfo,
+			"Target refused the connection when forwarding connection.",
+			slog.Any("error", err),
+			dialInfoAttr(dialInfoOfError(err)),
+		)
+		status = http.StatusBadGateway
+	case common.ForwardHostUnreachable, common.ForwardNetworkUnreachable:
 		api.logger.LogAttrs(
 			ctx,
 			slog.LevelInfo,
 			"Failed to reach target when forwarding connection.",
 			slog.Any("error", err),
+			dialInfoAttr(dialInfoOfError(err)),
 		)
-		writeHttpError(conn, 504)
-	}
-
-	if err != nil {
-		// TODO: If this is a dial error (unknown host), it should not be 500.
+		status = http.StatusBadGateway
+	case common.ForwardTimeout:
 		api.logger.LogAttrs(
 			ctx,
-			slog.LevelError,
-			"Failed to forward regular connection",
+			slog.LevelInfo,
+			"Timed out dialing target when forwarding connection.",
+			slog.Any("error", err),
+			dialInfoAttr(dialInfoOfError(err)),
+		)
+		status = http.StatusGatewayTimeout
+	case common.ForwardTlsFailure:
+		api.logger.LogAttrs(
+			ctx,
+			slog.LevelInfo,
+			"TLS handshake with target failed when forwarding connection.",
+			slog.Any("error", err),
+			dialInfoAttr(dialInfoOfError(err)),
+		)
+		status = http.StatusBadGateway
+	case common.ForwardProbeUnavailable:
+		api.logger.LogAttrs(
+			ctx,
+			slog.LevelWarn,
+			"No probe available when forwarding connection.",
+			slog.Any("error", err),
+		)
+		status = http.StatusServiceUnavailable
+	case common.ForwardCooldown:
+		api.logger.LogAttrs(
+			ctx,
+			slog.LevelInfo,
+			"Every probe is in cooldown for the target host.",
+			slog.Any("error", err),
+		)
+		status = http.StatusTooManyRequests
+	case common.ForwardRateLimited:
+		api.logger.LogAttrs(
+			ctx,
+			slog.LevelInfo,
+			"Rate limit of the target host exceeded.",
+			slog.Any("error", err),
+		)
+		status = http.StatusTooManyRequests
+	case common.ForwardProbesBusy:
+		api.logger.LogAttrs(
+			ctx,
+			slog.LevelWarn,
+			"Every probe is at its connection limit.",
+			slog.Any("error", err),
+		)
+		status = http.StatusServiceUnavailable
+	case common.ForwardBlockedByPolicy:
+		api.logger.LogAttrs(
+			ctx,
+			slog.LevelInfo,
+			"Target address blocked by the probe's policy.",
 			slog.Any("error", err),
 		)
+		// The probe's error may reveal its internal network.
+		status, message = http.StatusForbidden, "target address blocked by the probe's policy"
+	case common.ForwardRejected:
+		api.logger.LogAttrs(
+			ctx,
+			slog.LevelInfo,
+			"Connection rejected by the hub configuration.",
+			slog.Any("error", err),
+		)
+		status, message = http.StatusForbidden, err.Error()
+	}
+	if status == 0 {
+		return proxyError{}, false
 	}
+	return newProxyError(ctx, status, err, message), true
+}
+
+// streamConn is a tunnel through an HTTP/2 stream, reading from the request
+// body and writing to the response.
+type streamConn struct {
+	body   io.ReadCloser
+	w      http.ResponseWriter
+	rc     *http.ResponseController
+	closed atomic.Bool
+
+	mu       sync.Mutex // Serializes writes, which must end before the handler returns
+	accepted bool       // Whether the response was started
+}
+
+func newStreamConn(w http.ResponseWriter, body io.ReadCloser) *streamConn {
+	return &streamConn{
+		body: body,
+		w:    w,
+		rc:   http.NewResponseController(w),
+	}
+}
 
+func (conn *streamConn) Name() string {
+	return "client"
+}
+
+func (conn *streamConn) Read(p []byte) (int, error) {
+	return conn.body.Read(p)
+}
+
+func (conn *streamConn) Write(p []byte) (int, error) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if conn.closed.Load() {
+		return 0, io.ErrClosedPipe
+	}
+	n, err := conn.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, conn.rc.Flush()
+}
+
+// Close ends the tunnel. Writes in progress are aborted, and Close waits for
+// them to return, after which the handler may return.
+func (conn *streamConn) Close() error {
+	if conn.closed.Swap(true) {
+		return nil
+	}
+	err := conn.body.Close()
+	if !conn.mu.TryLock() {
+		// A write is in progress, possibly waiting for the client's flow
+		// control. The deadline resets the stream.
+		conn.rc.SetWriteDeadline(time.Now())
+		conn.mu.Lock()
+	}
+	conn.mu.Unlock()
+	return err
+}
+
+// accept sends the 200 OK response, after which the stream is a tunnel.
+func (conn *streamConn) accept() error {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	conn.accepted = true
+	conn.w.WriteHeader(http.StatusOK)
+	return conn.rc.Flush()
+}
+
+// isAccepted reports whether the response has been started, after which no
+// error response can be sent.
+func (conn *streamConn) isAccepted() bool {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	return conn.accepted
 }
 
 type namer interface {
@@ -214,13 +671,83 @@ func hijack(w http.ResponseWriter, name string) (common.Conn, error) {
 	}, nil
 }
 
-func writeHttpError(w io.Writer, statusCode int) error {
-	statusText := http.StatusText(statusCode)
-	resp := fmt.Sprintf(
-		"HTTP/1.1 %d %s\r\nContent-Length: 0\r\nConnection: close\r\n\r\n",
-		statusCode,
-		statusText,
-	)
-	_, err := io.WriteString(w, resp)
-	return err
+// Headers describing a failed request to the client.
+const (
+	errorHeader   = "X-Rotox-Error"    // Error code of the failure
+	traceIdHeader = "X-Rotox-Trace-Id" // Trace id of the request, also found in the hub's logs
+)
+
+// Error codes of failures that Core.forward does not report.
+const (
+	errorInvalidRoutingHints = "INVALID_ROUTING_HINTS"
+	errorProxyAuthRequired   = "PROXY_AUTH_REQUIRED"
+	errorInvalidResponse     = "INVALID_RESPONSE"
+)
+
+// proxyError describes a failed request to the client, so that the failure
+// can be told apart from others and matched to the hub's logs.
+type proxyError struct {
+	status  int    // HTTP status code of the response
+	code    string // Error code, usually a common.ForwardErrorCode
+	message string // Explanation of the error, empty to leave it out
+	probe   string // Name of the probe that dialed the target, empty if none
+	traceId string // Trace id of the request
+}
+
+// newProxyError describes err, returned when forwarding the request of ctx.
+func newProxyError(ctx context.Context, status int, err error, message string) proxyError {
+	return proxyError{
+		status:  status,
+		code:    string(fault.Code[common.ForwardErrorCode](err)),
+		message: message,
+		probe:   probeOfError(err),
+		traceId: tracing.GetTraceId(ctx),
+	}
+}
+
+// header returns the headers of the error response.
+func (e proxyError) header() http.Header {
+	header := http.Header{}
+	header.Set(errorHeader, e.code)
+	header.Set(traceIdHeader, e.traceId)
+	header.Set("Content-Type", "text/plain; charset=utf-8")
+	return header
+}
+
+// body returns the plain text body of the error response, with one
+// "key: value" line per known detail.
+func (e proxyError) body() string {
+	var body strings.Builder
+	fmt.Fprintf(&body, "error: %s\n", e.code)
+	if e.message != "" {
+		fmt.Fprintf(&body, "message: %s\n", e.message)
+	}
+	if e.probe != "" {
+		fmt.Fprintf(&body, "probe: %s\n", e.probe)
+	}
+	fmt.Fprintf(&body, "trace_id: %s\n", e.traceId)
+	return body.String()
+}
+
+// serve writes the error response with w, before the connection is hijacked.
+func (e proxyError) serve(w http.ResponseWriter) {
+	maps.Copy(w.Header(), e.header())
+	w.WriteHeader(e.status)
+	io.WriteString(w, e.body())
+}
+
+// write writes the error response to a hijacked connection, which is closed
+// afterwards.
+func (e proxyError) write(w io.Writer) error {
+	body := e.body()
+	resp := &http.Response{
+		StatusCode:    e.status,
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.header(),
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Close:         true,
+	}
+	return resp.Write(w)
 }
@@ -0,0 +1,89 @@
+package hub
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/isacskoglund/rotox/internal/common"
+	"github.com/isacskoglund/rotox/internal/fault"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHttpApi_DescribeForwardError(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		err     error
+		status  int
+		code    string
+		message string
+		level   string
+	}{
+		{"rejected", fault.New("blocked", common.ForwardRejected), http.StatusForbidden, "REJECTED", "blocked", "INFO"},
+		{
+			"blocked by policy",
+			fault.New("address 10.0.0.1 of internal.example.com is blocked", common.ForwardBlockedByPolicy),
+			http.StatusForbidden, "BLOCKED_BY_POLICY", "target address blocked by the probe's policy", "INFO",
+		},
+		{"rate limited", fault.New("limited", common.ForwardRateLimited), http.StatusTooManyRequests, "RATE_LIMITED", "", "INFO"},
+		{"probes busy", fault.New("busy", common.ForwardProbesBusy), http.StatusServiceUnavailable, "PROBES_BUSY", "", "WARN"},
+		{"deadline", fmt.Errorf("waiting: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, "TIMEOUT", "", "INFO"},
+		{"client left", context.Canceled, 0, "", "", "INFO"},
+		{"unknown", fmt.Errorf("broken"), http.StatusInternalServerError, "UNKNOWN", "", "ERROR"},
+	} {
+		var logs bytes.Buffer
+		api := NewHttpApi(slog.New(slog.NewTextHandler(&logs, nil)), nil)
+
+		perr, ok := api.describeForwardError(context.Background(), test.err)
+		assert.Equal(t, test.status != 0, ok, test.name)
+		assert.Equal(t, test.status, perr.status, test.name)
+		assert.Equal(t, test.code, perr.code, test.name)
+		assert.Equal(t, test.message, perr.message, test.name)
+		// Every error is logged once, at the level of its code.
+		assert.Equal(t, 1, bytes.Count(logs.Bytes(), []byte("level=")), test.name)
+		assert.Contains(t, logs.String(), "level="+test.level, test.name)
+	}
+}
+
+func TestHttpApi_AuthenticateBeforeHints(t *testing.T) {
+	core := NewCore(slog.New(slog.DiscardHandler), []ProbeSpec{{Name: "a", Dialer: &stubDialer{"a"}}})
+	assert.NoError(t, core.SetUsernameGrammar(DefaultUsernameGrammar))
+	api := NewHttpApi(slog.New(slog.DiscardHandler), core)
+	api.SetAuthenticator(NewSecretAuthenticator("secret"))
+
+	for _, test := range []struct {
+		name     string
+		username string
+		password string
+		labels   string
+		status   int
+	}{
+		{"invalid hints without credentials", "team-region", "wrong", "", http.StatusProxyAuthRequired},
+		{"invalid labels without credentials", "team", "wrong", "a=", http.StatusProxyAuthRequired},
+		{"invalid hints", "team-region", "secret", "", http.StatusBadRequest},
+		{"invalid labels", "team", "secret", "a=", http.StatusBadRequest},
+	} {
+		req := httptest.NewRequest(http.MethodConnect, "http://example.com:443", nil)
+		req.Header.Set("Proxy-Authorization", basicProxyAuthorization(test.username, test.password))
+		if test.labels != "" {
+			req.Header.Set(labelSelectorHeader, test.labels)
+		}
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		assert.Equal(t, test.status, rec.Code, test.name)
+		if test.status == http.StatusProxyAuthRequired {
+			// Strangers learn nothing about the grammar.
+			assert.NotContains(t, rec.Body.String(), "region", test.name)
+			assert.NotContains(t, rec.Body.String(), "label", test.name)
+		}
+	}
+}
+
+func basicProxyAuthorization(username string, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
@@ -0,0 +1,113 @@
+package hub
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// labelSelectorHeader is the request header clients can use to restrict the
+// probes used for a request to those with matching labels.
+const labelSelectorHeader = "X-Rotox-Labels"
+
+// labelOperator is the comparison of a label requirement.
+type labelOperator int
+
+const (
+	labelEquals    labelOperator = iota // The label has one of the values
+	labelNotEquals                      // The label is missing or has none of the values
+	labelExists                         // The label is set
+	labelNotExists                      // The label is not set
+)
+
+// labelRequirement is a single comma-separated term of a LabelSelector.
+type labelRequirement struct {
+	key      string
+	operator labelOperator
+	values   []string
+}
+
+func (req labelRequirement) matches(labels map[string]string) bool {
+	value, ok := labels[req.key]
+	switch req.operator {
+	case labelEquals:
+		return ok && slices.Contains(req.values, value)
+	case labelNotEquals:
+		return !ok || !slices.Contains(req.values, value)
+	case labelExists:
+		return ok
+	default:
+		return !ok
+	}
+}
+
+// LabelSelector restricts the probes used for a request to those whose labels
+// match every requirement. Requirements are separated by commas and take the
+// forms "key=value", "key!=value", "key" (the label is set) and "!key" (the
+// label is not set). Alternative values are separated by "|", e.g.
+// "region=eu|us,provider!=gcp". The empty selector matches every probe.
+type LabelSelector []labelRequirement
+
+// ParseLabelSelector parses a selector such as "region=eu,provider!=gcp".
+func ParseLabelSelector(selector string) (LabelSelector, error) {
+	var result LabelSelector
+	if strings.TrimSpace(selector) == "" {
+		return result, nil
+	}
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		var req labelRequirement
+		var value string
+		if key, v, ok := strings.Cut(term, "!="); ok {
+			req = labelRequirement{key: key, operator: labelNotEquals}
+			value = v
+		} else if key, v, ok := strings.Cut(term, "="); ok {
+			req = labelRequirement{key: key, operator: labelEquals}
+			value = v
+		} else if key, ok := strings.CutPrefix(term, "!"); ok {
+			req = labelRequirement{key: key, operator: labelNotExists}
+		} else {
+			req = labelRequirement{key: term, operator: labelExists}
+		}
+		req.key = strings.TrimSpace(req.key)
+		if !validLabel(req.key) {
+			return nil, fmt.Errorf("invalid label selector term %q", term)
+		}
+		if req.operator == labelEquals || req.operator == labelNotEquals {
+			for _, v := range strings.Split(value, "|") {
+				v = strings.TrimSpace(v)
+				if !validLabel(v) {
+					return nil, fmt.Errorf("invalid label selector term %q", term)
+				}
+				req.values = append(req.values, v)
+			}
+		}
+		result = append(result, req)
+	}
+	return result, nil
+}
+
+// Matches reports whether labels satisfy every requirement of the selector.
+func (selector LabelSelector) Matches(labels map[string]string) bool {
+	for _, req := range selector {
+		if !req.matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// validLabel reports whether s can be used as a label key or value in a selector.
+func validLabel(s string) bool {
+	return s != "" && !strings.ContainsAny(s, "=!,| ")
+}
+
+// ValidateLabels checks that the labels of a probe can be matched by selectors.
+func ValidateLabels(labels map[string]string) error {
+	for key, value := range labels {
+		if !validLabel(key) || !validLabel(value) {
+			return fmt.Errorf("invalid label %s=%s: keys and values must be non-empty and must not contain any of =!,| or spaces", key, value)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package hub
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLabelSelector(t *testing.T) {
+	selector, err := ParseLabelSelector("region=eu|us, provider!=gcp,ipv6,!spot")
+	assert.NoError(t, err)
+	assert.Equal(t, LabelSelector{
+		{key: "region", operator: labelEquals, values: []string{"eu", "us"}},
+		{key: "provider", operator: labelNotEquals, values: []string{"gcp"}},
+		{key: "ipv6", operator: labelExists},
+		{key: "spot", operator: labelNotExists},
+	}, selector)
+
+	selector, err = ParseLabelSelector(" ")
+	assert.NoError(t, err)
+	assert.Empty(t, selector)
+
+	for _, invalid := range []string{"=eu", "region=", "region=eu|", "region==eu", "a,,b", "!", "region=e u"} {
+		_, err := ParseLabelSelector(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestLabelSelector_Matches(t *testing.T) {
+	labels := map[string]string{"region": "eu", "provider": "aws", "ipv6": "true"}
+	tests := []struct {
+		selector string
+		matches  bool
+	}{
+		{"", true},
+		{"region=eu", true},
+		{"region=us", false},
+		{"region=us|eu", true},
+		{"region=eu,provider!=gcp", true},
+		{"region=eu,provider!=aws", false},
+		{"tier!=premium", true},
+		{"ipv6", true},
+		{"tier", false},
+		{"!tier", true},
+		{"!ipv6", false},
+	}
+	for _, test := range tests {
+		selector, err := ParseLabelSelector(test.selector)
+		assert.NoError(t, err)
+		assert.Equal(t, test.matches, selector.Matches(labels), test.selector)
+	}
+	assert.True(t, LabelSelector{}.Matches(nil))
+}
+
+func TestValidateLabels(t *testing.T) {
+	assert.NoError(t, ValidateLabels(nil))
+	assert.NoError(t, ValidateLabels(map[string]string{"region": "eu-west", "cost-tier": "1"}))
+	assert.Error(t, ValidateLabels(map[string]string{"region": ""}))
+	assert.Error(t, ValidateLabels(map[string]string{"region": "eu,us"}))
+}
@@ -0,0 +1,69 @@
+package hub
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/netip"
+
+	"golang.org/x/sys/unix"
+)
+
+// ip6tSoOriginalDst is IP6T_SO_ORIGINAL_DST of linux/netfilter_ipv6/ip6_tables.h,
+// which golang.org/x/sys/unix does not define.
+const ip6tSoOriginalDst = 80
+
+// originalDestination returns the address a connection was sent to before
+// the firewall redirected it to the hub, e.g. with iptables REDIRECT or DNAT.
+// It is read from the connection tracking of netfilter through
+// SO_ORIGINAL_DST, or IP6T_SO_ORIGINAL_DST for IPv6 connections.
+func originalDestination(conn net.Conn) (netip.AddrPort, error) {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return netip.AddrPort{}, errors.New("not a TCP connection")
+	}
+	raw, err := tcpConn.SyscallConn()
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	isIpv4 := isIpv4Addr(tcpConn.LocalAddr())
+
+	var dst netip.AddrPort
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		// The options return a sockaddr_in or sockaddr_in6, which are read
+		// through getters of structures with the same size or larger.
+		if isIpv4 {
+			var mreq *unix.IPv6Mreq
+			mreq, sockErr = unix.GetsockoptIPv6Mreq(int(fd), unix.SOL_IP, unix.SO_ORIGINAL_DST)
+			if sockErr == nil {
+				port := binary.BigEndian.Uint16(mreq.Multiaddr[2:4])
+				addr := netip.AddrFrom4([4]byte(mreq.Multiaddr[4:8]))
+				dst = netip.AddrPortFrom(addr, port)
+			}
+			return
+		}
+		var info *unix.IPv6MTUInfo
+		info, sockErr = unix.GetsockoptIPv6MTUInfo(int(fd), unix.SOL_IPV6, ip6tSoOriginalDst)
+		if sockErr == nil {
+			// The port is in network byte order.
+			var port [2]byte
+			binary.NativeEndian.PutUint16(port[:], info.Addr.Port)
+			dst = netip.AddrPortFrom(netip.AddrFrom16(info.Addr.Addr), binary.BigEndian.Uint16(port[:]))
+		}
+	})
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	if sockErr != nil {
+		return netip.AddrPort{}, sockErr
+	}
+	return dst, nil
+}
+
+// isIpv4Addr reports whether addr is an IPv4 address, including IPv4 addresses
+// of connections accepted by dual-stack listeners.
+func isIpv4Addr(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	return ok && tcpAddr.IP.To4() != nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package hub
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+)
+
+// originalDestination returns the address a connection was sent to before
+// the firewall redirected it to the hub. It requires Linux.
+func originalDestination(net.Conn) (netip.AddrPort, error) {
+	return netip.AddrPort{}, errors.ErrUnsupported
+}
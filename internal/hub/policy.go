@@ -0,0 +1,161 @@
+package hub
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/isacskoglund/rotox/internal/common"
+	"github.com/isacskoglund/rotox/internal/fault"
+	"github.com/isacskoglund/rotox/internal/telemetry"
+)
+
+// DefaultAllowedPorts are the target ports allowed by the hub configuration
+// unless other ports are configured.
+var DefaultAllowedPorts = []string{"80", "443"}
+
+// AccessPolicy restricts the targets that clients may connect to, e.g. to
+// keep the probes from being used to send mail or to reach internal networks.
+// The zero policy allows every target.
+type AccessPolicy struct {
+	// AllowedPorts are the target ports that may be used, either single
+	// ports ("443") or ranges ("8000-8999"). Empty allows every port.
+	AllowedPorts []string
+	// DeniedDomains are patterns of target hosts that may not be used: an
+	// exact host ("example.com"), its subdomains ("*.example.com") or any
+	// host ("*").
+	DeniedDomains []string
+	// DeniedCidrs are IP ranges that may not be used. Only targets given as
+	// IP addresses are checked, host names are not resolved by the hub.
+	DeniedCidrs []string
+}
+
+// portRange is an inclusive range of ports.
+type portRange struct {
+	from, to int
+}
+
+// parsePortRange parses a single port ("443") or a range ("8000-8999").
+func parsePortRange(s string) (portRange, error) {
+	fromString, toString, isRange := strings.Cut(s, "-")
+	if !isRange {
+		toString = fromString
+	}
+	from, err := strconv.Atoi(strings.TrimSpace(fromString))
+	if err != nil {
+		return portRange{}, fmt.Errorf("invalid port %q", s)
+	}
+	to, err := strconv.Atoi(strings.TrimSpace(toString))
+	if err != nil || from < 1 || to > 65535 || from > to {
+		return portRange{}, fmt.Errorf("invalid port %q", s)
+	}
+	return portRange{from: from, to: to}, nil
+}
+
+// compiledPolicy is a validated AccessPolicy, ready for checking targets.
+type compiledPolicy struct {
+	ports    []portRange
+	domains  []string
+	prefixes []netip.Prefix
+}
+
+// compile validates the policy and prepares it for checking targets.
+func (policy AccessPolicy) compile() (*compiledPolicy, error) {
+	compiled := &compiledPolicy{}
+	for _, port := range policy.AllowedPorts {
+		r, err := parsePortRange(port)
+		if err != nil {
+			return nil, err
+		}
+		compiled.ports = append(compiled.ports, r)
+	}
+	for _, pattern := range policy.DeniedDomains {
+		pattern = strings.ToLower(pattern)
+		if !validHostPattern(pattern) {
+			return nil, fmt.Errorf("invalid denied domain pattern: %q", pattern)
+		}
+		compiled.domains = append(compiled.domains, pattern)
+	}
+	for _, cidr := range policy.DeniedCidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid denied cidr: %q", cidr)
+		}
+		compiled.prefixes = append(compiled.prefixes, prefix.Masked())
+	}
+	return compiled, nil
+}
+
+// accessPolicy checks targets against the configured AccessPolicy and
+// publishes a telemetry event for every denied connection.
+type accessPolicy struct {
+	tel    *multiTelemetryPublisher
+	policy atomic.Pointer[compiledPolicy]
+	now    func() time.Time
+}
+
+func newAccessPolicy(tel *multiTelemetryPublisher) *accessPolicy {
+	p := &accessPolicy{tel: tel, now: time.Now}
+	p.policy.Store(&compiledPolicy{})
+	return p
+}
+
+// configure validates and replaces the policy.
+func (p *accessPolicy) configure(policy AccessPolicy) error {
+	compiled, err := policy.compile()
+	if err != nil {
+		return err
+	}
+	p.policy.Store(compiled)
+	return nil
+}
+
+// check returns an error with code common.ForwardRejected if the policy does
+// not allow connections to targetAddress.
+func (p *accessPolicy) check(targetAddress string) error {
+	policy := p.policy.Load()
+	host := strings.TrimSuffix(targetHost(targetAddress), ".")
+
+	if len(policy.ports) > 0 {
+		_, portString, _ := net.SplitHostPort(targetAddress)
+		port, _ := strconv.Atoi(portString)
+		if !slices.ContainsFunc(policy.ports, func(r portRange) bool {
+			return port >= r.from && port <= r.to
+		}) {
+			return p.deny(targetAddress, telemetry.PolicyPortNotAllowed, portString,
+				fmt.Sprintf("port %s is not allowed", portString))
+		}
+	}
+	for _, pattern := range policy.domains {
+		if hostPatternMatches(pattern, host) {
+			return p.deny(targetAddress, telemetry.PolicyDomainDenied, pattern,
+				fmt.Sprintf("host %s is denied", host))
+		}
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addr = addr.Unmap().WithZone("")
+		for _, prefix := range policy.prefixes {
+			if prefix.Contains(addr) {
+				return p.deny(targetAddress, telemetry.PolicyIpRangeDenied, prefix.String(),
+					fmt.Sprintf("address %s is denied", addr))
+			}
+		}
+	}
+	return nil
+}
+
+// deny publishes a policy event and returns the matching error.
+func (p *accessPolicy) deny(targetAddress string, violation telemetry.PolicyViolation, rule string, reason string) error {
+	p.tel.PolicyPublisher().Publish(telemetry.PolicyEvent{
+		TargetAddress: targetAddress,
+		Violation:     violation,
+		Rule:          rule,
+		DeniedAt:      p.now(),
+	})
+	return fault.New(fmt.Sprintf("access policy: %s", reason), common.ForwardRejected)
+}
@@ -0,0 +1,102 @@
+package hub
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/isacskoglund/rotox/internal/common"
+	"github.com/isacskoglund/rotox/internal/fault"
+	"github.com/isacskoglund/rotox/internal/telemetry"
+	"github.com/stretchr/testify/assert"
+)
+
+// policyEventRecorder collects published policy events.
+type policyEventRecorder struct {
+	mu     sync.Mutex
+	events []telemetry.PolicyEvent
+}
+
+func (r *policyEventRecorder) Publish(event telemetry.PolicyEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func TestAccessPolicy_Check(t *testing.T) {
+	tel := newMultiTelemetryPublisher()
+	recorder := &policyEventRecorder{}
+	tel.policyEvents.register(recorder)
+	policy := newAccessPolicy(tel)
+
+	// The zero policy allows every target.
+	assert.NoError(t, policy.check("mail.example.com:25"))
+
+	assert.NoError(t, policy.configure(AccessPolicy{
+		AllowedPorts:  []string{"80", "443", "8000-8999"},
+		DeniedDomains: []string{"Internal.Example.com", "*.corp"},
+		DeniedCidrs:   []string{"10.0.0.0/8", "169.254.169.254/32", "fc00::/7"},
+	}))
+	tests := []struct {
+		target    string
+		violation telemetry.PolicyViolation
+	}{
+		{"example.com:443", ""},
+		{"example.com:8080", ""},
+		{"example.com:25", telemetry.PolicyPortNotAllowed},
+		{"example.com:9000", telemetry.PolicyPortNotAllowed},
+		{"internal.example.com:443", telemetry.PolicyDomainDenied},
+		{"INTERNAL.example.com.:443", telemetry.PolicyDomainDenied},
+		{"db.corp:80", telemetry.PolicyDomainDenied},
+		{"corp:80", ""},
+		{"10.1.2.3:80", telemetry.PolicyIpRangeDenied},
+		{"11.1.2.3:80", ""},
+		{"[::ffff:10.0.0.1]:80", telemetry.PolicyIpRangeDenied},
+		{"[fd00::1]:443", telemetry.PolicyIpRangeDenied},
+		{"169.254.169.254:80", telemetry.PolicyIpRangeDenied},
+	}
+	var violations []telemetry.PolicyViolation
+	for _, test := range tests {
+		err := policy.check(test.target)
+		if test.violation == "" {
+			assert.NoError(t, err, test.target)
+		} else {
+			assert.Equal(t, common.ForwardRejected, fault.Code[common.ForwardErrorCode](err), test.target)
+			violations = append(violations, test.violation)
+		}
+	}
+	var published []telemetry.PolicyViolation
+	for _, event := range recorder.events {
+		published = append(published, event.Violation)
+	}
+	assert.Equal(t, violations, published)
+	assert.Equal(t, "example.com:25", recorder.events[0].TargetAddress)
+	assert.Equal(t, "25", recorder.events[0].Rule)
+}
+
+func TestAccessPolicy_ConfigureInvalid(t *testing.T) {
+	policy := newAccessPolicy(newMultiTelemetryPublisher())
+	for _, invalid := range []AccessPolicy{
+		{AllowedPorts: []string{"http"}},
+		{AllowedPorts: []string{"0"}},
+		{AllowedPorts: []string{"9000-8000"}},
+		{AllowedPorts: []string{"1-65536"}},
+		{DeniedDomains: []string{"*.*.com"}},
+		{DeniedCidrs: []string{"10.0.0.0"}},
+	} {
+		assert.Error(t, policy.configure(invalid), invalid)
+	}
+}
+
+func TestCore_ForwardDeniedByPolicy(t *testing.T) {
+	core := NewCore(slog.New(slog.DiscardHandler), []ProbeSpec{{Name: "a", Dialer: &stubDialer{"a"}}})
+	assert.NoError(t, core.SetAccessPolicy(AccessPolicy{AllowedPorts: DefaultAllowedPorts}))
+
+	err := core.forward(context.Background(), "smtp.example.com:25", routingHints{}, func() (common.Conn, error) {
+		t.Fatal("the client connection must not be accepted")
+		return nil, nil
+	})
+	assert.Equal(t, common.ForwardRejected, fault.Code[common.ForwardErrorCode](err))
+}
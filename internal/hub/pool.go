@@ -0,0 +1,84 @@
+package hub
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/isacskoglund/rotox/internal/fault"
+)
+
+// PoolErrorCode represents error types that can occur when changing the pool of probes.
+type PoolErrorCode string
+
+// Error codes for changes to the pool of probes.
+const (
+	PoolUnknown                     = fault.Unknown     // Unknown error occurred
+	PoolInvalidProbe  PoolErrorCode = "INVALID_PROBE"   // Probe specification is invalid
+	PoolProbeNotFound PoolErrorCode = "PROBE_NOT_FOUND" // No probe with the given name
+	PoolProbeExists   PoolErrorCode = "PROBE_EXISTS"    // A probe with the given name already exists
+	PoolLastProbe     PoolErrorCode = "LAST_PROBE"      // The pool must not become empty
+)
+
+// AddProbe adds a probe to the pool while the core is forwarding.
+func (core *Core) AddProbe(spec ProbeSpec) (*Probe, error) {
+	core.mu.Lock()
+	defer core.mu.Unlock()
+	if core.probeByName(spec.Name) != nil {
+		return nil, fault.New(fmt.Sprintf("probe %s already exists", spec.Name), PoolProbeExists)
+	}
+	if err := ValidateLabels(spec.Labels); err != nil {
+		return nil, fault.Wrap(err, "invalid probe", PoolInvalidProbe)
+	}
+	probe := newProbe(spec)
+	core.probes = append(core.probes, probe)
+	core.queue.notify()
+	return probe, nil
+}
+
+// RemoveProbe removes a probe from the pool while the core is forwarding.
+// Connections already using the probe are not affected; the removed probe is
+// returned so that the caller can release its dialer once it is idle.
+func (core *Core) RemoveProbe(name string) (*Probe, error) {
+	core.mu.Lock()
+	defer core.mu.Unlock()
+	probe := core.probeByName(name)
+	if probe == nil {
+		return nil, fault.New(fmt.Sprintf("probe %s not found", name), PoolProbeNotFound)
+	}
+	if len(core.probes) == 1 {
+		return nil, fault.New("cannot remove the last probe", PoolLastProbe)
+	}
+	core.probes = slices.DeleteFunc(core.probes, func(p *Probe) bool {
+		return p == probe
+	})
+	return probe, nil
+}
+
+// SetDraining drains a probe, excluding it from new connections while letting
+// its established connections continue, or re-enables a drained probe.
+// Sessions pinned to a draining probe move to another probe.
+func (core *Core) SetDraining(name string, draining bool) (*Probe, error) {
+	core.mu.RLock()
+	defer core.mu.RUnlock()
+	probe := core.probeByName(name)
+	if probe == nil {
+		return nil, fault.New(fmt.Sprintf("probe %s not found", name), PoolProbeNotFound)
+	}
+	probe.draining.Store(draining)
+	if !draining {
+		core.queue.notify()
+	}
+	return probe, nil
+}
+
+// probeByName returns the probe with the given name, or nil.
+// The caller must hold core.mu.
+func (core *Core) probeByName(name string) *Probe {
+	idx := slices.IndexFunc(core.probes, func(probe *Probe) bool {
+		return probe.name == name
+	})
+	if idx < 0 {
+		return nil
+	}
+	return core.probes[idx]
+}
@@ -0,0 +1,210 @@
+package hub
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/isacskoglund/rotox/internal/common"
+)
+
+// idlePollInterval is how often WaitIdle checks for active connections.
+const idlePollInterval = 100 * time.Millisecond
+
+// maxRecentErrors is the number of recent errors kept per probe.
+const maxRecentErrors = 5
+
+// latencySmoothing is the weight of a new sample in the dial latency EWMA.
+const latencySmoothing = 0.3
+
+// ProbeSpec describes a probe to be added to the hub's pool.
+type ProbeSpec struct {
+	Name           string            // Human-readable name, typically the probe host
+	Weight         int               // Relative weight used by the weighted strategy, values below 1 count as 1
+	MaxConnections int               // Maximum number of concurrent connections, zero means unlimited
+	Group          string            // Name of the group the probe belongs to, optional
+	Labels         map[string]string // Labels matched by label selectors, e.g. region=eu, optional
+	Dialer         common.Dialer     // Dialer establishing connections through the probe
+	Connectivity   func() string     // Reports the state of the connection to the probe, optional
+}
+
+// ProbeError is an error that recently occurred when using a probe.
+type ProbeError struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// Probe is a member of the hub's pool of probes.
+// Besides dialing through the probe, it keeps the runtime statistics
+// used by the selection strategies.
+type Probe struct {
+	name         string
+	weight       int
+	group        string
+	labels       map[string]string
+	dialer       common.Dialer
+	connectivity func() string
+	active       atomic.Int64 // Number of connections currently using the probe
+	maxActive    atomic.Int64 // Maximum number of concurrent connections, zero means unlimited
+	draining     atomic.Bool  // Whether the probe is excluded from new connections
+	health       probeHealth  // Health state used for outlier ejection
+
+	mu           sync.Mutex    // Protects latency, recentErrors and egressIp
+	latency      time.Duration // EWMA of dial durations, zero until the first sample
+	recentErrors []ProbeError  // Most recent errors caused by the probe, oldest first
+	egressIp     string        // Most recently reported egress IP, empty if unknown
+}
+
+func newProbe(spec ProbeSpec) *Probe {
+	probe := &Probe{
+		name:         spec.Name,
+		weight:       max(spec.Weight, 1),
+		group:        spec.Group,
+		labels:       maps.Clone(spec.Labels),
+		dialer:       spec.Dialer,
+		connectivity: spec.Connectivity,
+	}
+	probe.maxActive.Store(int64(max(spec.MaxConnections, 0)))
+	return probe
+}
+
+// Name returns the human-readable name of the probe.
+func (probe *Probe) Name() string {
+	return probe.name
+}
+
+// Weight returns the relative weight of the probe.
+func (probe *Probe) Weight() int {
+	return probe.weight
+}
+
+// ActiveConnections returns the number of connections currently using the probe.
+func (probe *Probe) ActiveConnections() int64 {
+	return probe.active.Load()
+}
+
+// Group returns the name of the group the probe belongs to, or an empty string.
+func (probe *Probe) Group() string {
+	return probe.group
+}
+
+// Labels returns a copy of the labels of the probe.
+func (probe *Probe) Labels() map[string]string {
+	return maps.Clone(probe.labels)
+}
+
+// MaxConnections returns the maximum number of concurrent connections of the
+// probe, or zero if it is unlimited.
+func (probe *Probe) MaxConnections() int64 {
+	return probe.maxActive.Load()
+}
+
+// full reports whether the probe is at its connection limit.
+func (probe *Probe) full() bool {
+	limit := probe.maxActive.Load()
+	return limit > 0 && probe.active.Load() >= limit
+}
+
+// reserve increments the active connection count unless the probe is at its
+// connection limit. It reports whether the count was incremented.
+func (probe *Probe) reserve() bool {
+	for {
+		active := probe.active.Load()
+		limit := probe.maxActive.Load()
+		if limit > 0 && active >= limit {
+			return false
+		}
+		if probe.active.CompareAndSwap(active, active+1) {
+			return true
+		}
+	}
+}
+
+// Draining reports whether the probe is excluded from new connections.
+func (probe *Probe) Draining() bool {
+	return probe.draining.Load()
+}
+
+// Ejected reports whether the probe is currently ejected by health checking.
+func (probe *Probe) Ejected() bool {
+	return !probe.health.available(time.Now())
+}
+
+// Connectivity returns the state of the connection to the probe,
+// or an empty string if it is unknown.
+func (probe *Probe) Connectivity() string {
+	if probe.connectivity == nil {
+		return ""
+	}
+	return probe.connectivity()
+}
+
+// EgressIp returns the public IP address most recently reported by the probe,
+// or an empty string if it is unknown.
+func (probe *Probe) EgressIp() string {
+	probe.mu.Lock()
+	defer probe.mu.Unlock()
+	return probe.egressIp
+}
+
+// observeEgressIp records the public IP address reported for a connection.
+func (probe *Probe) observeEgressIp(ip string) {
+	probe.mu.Lock()
+	defer probe.mu.Unlock()
+	probe.egressIp = ip
+}
+
+// RecentErrors returns the most recent errors caused by the probe, oldest first.
+func (probe *Probe) RecentErrors() []ProbeError {
+	probe.mu.Lock()
+	defer probe.mu.Unlock()
+	return slices.Clone(probe.recentErrors)
+}
+
+// recordError adds an error caused by the probe to its recent errors.
+func (probe *Probe) recordError(now time.Time, err error) {
+	probe.mu.Lock()
+	defer probe.mu.Unlock()
+	if len(probe.recentErrors) == maxRecentErrors {
+		probe.recentErrors = slices.Delete(probe.recentErrors, 0, 1)
+	}
+	probe.recentErrors = append(probe.recentErrors, ProbeError{Time: now, Message: err.Error()})
+}
+
+// WaitIdle blocks until no connections are using the probe or ctx is done.
+// It is meant for probes removed from the pool, which receive no new connections.
+func (probe *Probe) WaitIdle(ctx context.Context) error {
+	ticker := time.NewTicker(idlePollInterval)
+	defer ticker.Stop()
+	for probe.ActiveConnections() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// Latency returns the exponentially weighted moving average of the time
+// it takes to dial a target through the probe. It is zero until the first
+// successful dial.
+func (probe *Probe) Latency() time.Duration {
+	probe.mu.Lock()
+	defer probe.mu.Unlock()
+	return probe.latency
+}
+
+// observeLatency adds a dial duration sample to the latency average.
+func (probe *Probe) observeLatency(d time.Duration) {
+	probe.mu.Lock()
+	defer probe.mu.Unlock()
+	if probe.latency == 0 {
+		probe.latency = d
+		return
+	}
+	probe.latency = time.Duration(latencySmoothing*float64(d) + (1-latencySmoothing)*float64(probe.latency))
+}
@@ -0,0 +1,201 @@
+package hub
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/isacskoglund/rotox/internal/common"
+	"github.com/isacskoglund/rotox/internal/fault"
+	"github.com/isacskoglund/rotox/internal/telemetry"
+)
+
+// Default limits of the queue of connections waiting for a probe.
+const (
+	DefaultQueueDepth   = 100
+	DefaultQueueTimeout = 10 * time.Second
+)
+
+// QueueConfig configures the queue of connections waiting for a probe when
+// every probe is at its connection limit.
+type QueueConfig struct {
+	MaxDepth int           // Maximum number of waiting connections, further connections fail right away, zero uses DefaultQueueDepth
+	Timeout  time.Duration // How long a connection waits for a probe, zero fails fast
+}
+
+// queueWaiter is a connection waiting in a connectionQueue.
+type queueWaiter struct {
+	targetAddress string
+	enqueuedAt    time.Time
+	deadline      time.Time
+	elem          *list.Element
+	ready         chan struct{} // Closed when the waiter is woken
+	woken         bool          // Whether ready is closed
+	wokenAt       uint64        // Release generation the waiter was woken for
+}
+
+// connectionQueue is a FIFO queue of connections waiting for a free
+// connection slot of a probe. Every time a slot is released, the oldest
+// waiter is woken to select a probe again. A waiter that still finds every
+// probe full (e.g. because the released probe is excluded for it) keeps its
+// place and passes the wake-up on to the next waiter.
+type connectionQueue struct {
+	tel *multiTelemetryPublisher
+
+	mu         sync.Mutex
+	cfg        QueueConfig
+	waiters    *list.List // *queueWaiter, oldest first
+	generation uint64     // Number of released slots
+	now        func() time.Time
+}
+
+func newConnectionQueue(tel *multiTelemetryPublisher) *connectionQueue {
+	return &connectionQueue{
+		tel:     tel,
+		cfg:     QueueConfig{MaxDepth: DefaultQueueDepth, Timeout: DefaultQueueTimeout},
+		waiters: list.New(),
+		now:     time.Now,
+	}
+}
+
+// configure replaces the configuration. Connections that are already
+// waiting keep their deadline.
+func (q *connectionQueue) configure(cfg QueueConfig) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.cfg = cfg
+}
+
+// enter adds a connection to the back of the queue. If the queue is full or
+// waiting is disabled, an error with code common.ForwardProbesBusy is returned.
+// Unless another waiter is awake already, the new waiter is woken right away:
+// a slot may have been released before it entered, and a connection that
+// joins behind waiters may well be able to use a probe they cannot.
+func (q *connectionQueue) enter(targetAddress string) (*queueWaiter, error) {
+	q.mu.Lock()
+	now := q.now()
+	if q.cfg.Timeout <= 0 || q.waiters.Len() >= q.cfg.MaxDepth {
+		depth := q.waiters.Len()
+		q.mu.Unlock()
+		q.publish(telemetry.QueueEvent{
+			TargetAddress: targetAddress,
+			Outcome:       telemetry.QueueRejected,
+			Depth:         depth,
+			EnqueuedAt:    now,
+			DequeuedAt:    now,
+		})
+		return nil, fault.New("every probe is at its connection limit and the queue is full", common.ForwardProbesBusy)
+	}
+	w := &queueWaiter{
+		targetAddress: targetAddress,
+		enqueuedAt:    now,
+		deadline:      now.Add(q.cfg.Timeout),
+		ready:         make(chan struct{}),
+	}
+	w.elem = q.waiters.PushBack(w)
+	if !q.awake() {
+		q.wakeFrom(w.elem)
+	}
+	q.mu.Unlock()
+	return w, nil
+}
+
+// len returns the number of waiting connections.
+func (q *connectionQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.waiters.Len()
+}
+
+// awake reports whether a waiter was woken and has not selected a probe yet.
+// The caller must hold q.mu.
+func (q *connectionQueue) awake() bool {
+	for elem := q.waiters.Front(); elem != nil; elem = elem.Next() {
+		if elem.Value.(*queueWaiter).woken {
+			return true
+		}
+	}
+	return false
+}
+
+// wait blocks until w is woken, its deadline passes or ctx is done.
+// In the latter cases w leaves the queue and an error is returned.
+func (q *connectionQueue) wait(ctx context.Context, w *queueWaiter) error {
+	timer := time.NewTimer(time.Until(w.deadline))
+	defer timer.Stop()
+	select {
+	case <-w.ready:
+		return nil
+	case <-timer.C:
+		q.leave(w, telemetry.QueueTimedOut)
+		return fault.New("timed out waiting for a probe with a free connection slot", common.ForwardProbesBusy)
+	case <-ctx.Done():
+		q.leave(w, telemetry.QueueCanceled)
+		return ctx.Err()
+	}
+}
+
+// retry is called by a woken waiter that still found every probe full.
+// If no slot was released since it was woken, it goes back to waiting at its
+// place and the next waiter is woken instead.
+func (q *connectionQueue) retry(w *queueWaiter) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if w.wokenAt != q.generation {
+		// A slot was released in the meantime, select again right away.
+		w.wokenAt = q.generation
+		return
+	}
+	w.woken = false
+	w.ready = make(chan struct{})
+	q.wakeFrom(w.elem.Next())
+}
+
+// leave removes w from the queue.
+func (q *connectionQueue) leave(w *queueWaiter, outcome telemetry.QueueOutcome) {
+	q.mu.Lock()
+	next := w.elem.Next()
+	q.waiters.Remove(w.elem)
+	if w.woken && outcome != telemetry.QueueServed {
+		// Do not swallow the wake-up.
+		q.wakeFrom(next)
+	}
+	depth := q.waiters.Len()
+	now := q.now()
+	q.mu.Unlock()
+	q.publish(telemetry.QueueEvent{
+		TargetAddress: w.targetAddress,
+		Outcome:       outcome,
+		Depth:         depth,
+		EnqueuedAt:    w.enqueuedAt,
+		DequeuedAt:    now,
+	})
+}
+
+// notify reports that a connection slot was released, or that a probe may
+// have become available otherwise, and wakes the oldest waiter.
+func (q *connectionQueue) notify() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.generation++
+	q.wakeFrom(q.waiters.Front())
+}
+
+// wakeFrom wakes the first waiter that is not awake already, starting at elem.
+// The caller must hold q.mu.
+func (q *connectionQueue) wakeFrom(elem *list.Element) {
+	for next := elem; next != nil; next = next.Next() {
+		w := next.Value.(*queueWaiter)
+		if !w.woken {
+			w.woken = true
+			w.wokenAt = q.generation
+			close(w.ready)
+			return
+		}
+	}
+}
+
+func (q *connectionQueue) publish(event telemetry.QueueEvent) {
+	q.tel.QueuePublisher().Publish(event)
+}
@@ -0,0 +1,169 @@
+package hub
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/isacskoglund/rotox/internal/common"
+	"github.com/isacskoglund/rotox/internal/fault"
+	"github.com/isacskoglund/rotox/internal/telemetry"
+	"github.com/stretchr/testify/assert"
+)
+
+// queueEventRecorder collects published queue events.
+type queueEventRecorder struct {
+	mu     sync.Mutex
+	events []telemetry.QueueEvent
+}
+
+func (r *queueEventRecorder) Publish(event telemetry.QueueEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *queueEventRecorder) outcomes() []telemetry.QueueOutcome {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var outcomes []telemetry.QueueOutcome
+	for _, event := range r.events {
+		outcomes = append(outcomes, event.Outcome)
+	}
+	return outcomes
+}
+
+func queueDepth(core *Core) int {
+	core.queue.mu.Lock()
+	defer core.queue.mu.Unlock()
+	return core.queue.waiters.Len()
+}
+
+func newLimitedCore(maxDepth int, timeout time.Duration) (*Core, *queueEventRecorder) {
+	core := NewCore(slog.New(slog.DiscardHandler), []ProbeSpec{
+		{Name: "a", MaxConnections: 1, Dialer: &stubDialer{"a"}},
+		{Name: "b", MaxConnections: 1, Dialer: &stubDialer{"b"}},
+	})
+	core.SetQueue(QueueConfig{MaxDepth: maxDepth, Timeout: timeout})
+	recorder := &queueEventRecorder{}
+	core.tel.queueEvents.register(recorder)
+	return core, recorder
+}
+
+func TestCore_ConnectionLimit(t *testing.T) {
+	ctx := context.Background()
+	core, recorder := newLimitedCore(2, time.Second)
+	a, err := core.awaitProbe(ctx, "example.com:443", routingHints{}, nil)
+	assert.NoError(t, err)
+	b, err := core.awaitProbe(ctx, "example.com:443", routingHints{}, nil)
+	assert.NoError(t, err)
+	assert.NotSame(t, a, b)
+
+	// Connections wait for a free slot in the order they arrived. A waiter
+	// that cannot use the released probe lets the next one have it.
+	results := make(chan *Probe)
+	go func() {
+		probe, err := core.awaitProbe(ctx, "example.com:443", routingHints{}, []*Probe{a})
+		assert.NoError(t, err)
+		results <- probe
+	}()
+	assert.Eventually(t, func() bool { return queueDepth(core) == 1 }, time.Second, time.Millisecond)
+	go func() {
+		probe, err := core.awaitProbe(ctx, "example.com:443", routingHints{}, nil)
+		assert.NoError(t, err)
+		results <- probe
+	}()
+	assert.Eventually(t, func() bool { return queueDepth(core) == 2 }, time.Second, time.Millisecond)
+
+	// The queue is full.
+	_, err = core.awaitProbe(ctx, "example.com:443", routingHints{}, nil)
+	assert.Equal(t, common.ForwardProbesBusy, fault.Code[common.ForwardErrorCode](err))
+
+	core.releaseProbe(a)
+	assert.Same(t, a, <-results)
+	core.releaseProbe(b)
+	assert.Same(t, b, <-results)
+	assert.Equal(t, 0, queueDepth(core))
+	assert.Equal(t, []telemetry.QueueOutcome{
+		telemetry.QueueRejected, telemetry.QueueServed, telemetry.QueueServed,
+	}, recorder.outcomes())
+}
+
+func TestCore_ConnectionLimitTimeout(t *testing.T) {
+	ctx := context.Background()
+	core, recorder := newLimitedCore(10, 10*time.Millisecond)
+	for range 2 {
+		_, err := core.awaitProbe(ctx, "example.com:443", routingHints{}, nil)
+		assert.NoError(t, err)
+	}
+
+	_, err := core.awaitProbe(ctx, "example.com:443", routingHints{}, nil)
+	assert.Equal(t, common.ForwardProbesBusy, fault.Code[common.ForwardErrorCode](err))
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = core.awaitProbe(ctx, "example.com:443", routingHints{}, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// A zero timeout fails fast.
+	core.SetQueue(QueueConfig{MaxDepth: 10})
+	_, err = core.awaitProbe(context.Background(), "example.com:443", routingHints{}, nil)
+	assert.Equal(t, common.ForwardProbesBusy, fault.Code[common.ForwardErrorCode](err))
+
+	assert.Equal(t, []telemetry.QueueOutcome{
+		telemetry.QueueTimedOut, telemetry.QueueCanceled, telemetry.QueueRejected,
+	}, recorder.outcomes())
+	assert.Equal(t, 0, queueDepth(core))
+}
+
+func TestCore_ConnectionLimitFifo(t *testing.T) {
+	ctx := context.Background()
+	core, _ := newLimitedCore(10, time.Second)
+	a, err := core.awaitProbe(ctx, "example.com:443", routingHints{}, nil)
+	assert.NoError(t, err)
+	b, err := core.awaitProbe(ctx, "example.com:443", routingHints{}, nil)
+	assert.NoError(t, err)
+
+	first := make(chan *Probe)
+	go func() {
+		probe, err := core.awaitProbe(ctx, "example.com:443", routingHints{}, nil)
+		assert.NoError(t, err)
+		first <- probe
+	}()
+	assert.Eventually(t, func() bool { return queueDepth(core) == 1 }, time.Second, time.Millisecond)
+
+	// A connection arriving after a slot was released for the waiting one
+	// joins the back of the queue instead of taking the slot.
+	core.mu.Lock()
+	core.releaseProbe(a)
+	second := make(chan *Probe)
+	go func() {
+		probe, err := core.awaitProbe(ctx, "example.com:443", routingHints{}, nil)
+		assert.NoError(t, err)
+		second <- probe
+	}()
+	assert.Eventually(t, func() bool { return queueDepth(core) == 2 }, time.Second, time.Millisecond)
+	core.mu.Unlock()
+
+	assert.Same(t, a, <-first)
+	core.releaseProbe(b)
+	assert.Same(t, b, <-second)
+	assert.Equal(t, 0, queueDepth(core))
+}
+
+func TestCore_SetQueueDefaults(t *testing.T) {
+	core := NewCore(slog.New(slog.DiscardHandler), []ProbeSpec{{Name: "a", Dialer: &stubDialer{"a"}}})
+
+	// A zero depth uses the default, the timeout is kept.
+	core.SetQueue(QueueConfig{Timeout: 3 * time.Second})
+	assert.Equal(t, QueueConfig{MaxDepth: DefaultQueueDepth, Timeout: 3 * time.Second}, core.queue.cfg)
+
+	settings := Settings{Selector: &roundRobinSelector{}, Queue: QueueConfig{MaxDepth: 5, Timeout: -time.Second}}
+	assert.Error(t, core.Configure(settings))
+	settings.Queue = QueueConfig{MaxDepth: -1}
+	assert.Error(t, core.Configure(settings))
+	assert.Equal(t, 3*time.Second, core.queue.cfg.Timeout)
+}
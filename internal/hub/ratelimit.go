@@ -0,0 +1,264 @@
+package hub
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/isacskoglund/rotox/internal/common"
+	"github.com/isacskoglund/rotox/internal/fault"
+	"golang.org/x/net/publicsuffix"
+)
+
+// rateLimitSweepInterval is how often idle rate limit state is removed.
+const rateLimitSweepInterval = time.Minute
+
+// Keys that rate limits can be applied per.
+const (
+	RateLimitKeyHost   = "host"   // Each target host is limited separately
+	RateLimitKeyDomain = "domain" // Hosts sharing a registrable domain (e.g. a.example.com and b.example.com) are limited together
+)
+
+// RateLimitRule limits the connections to the target hosts matching a pattern,
+// across all probes. Each target (host or registrable domain, see Key) matching
+// the pattern is limited separately.
+type RateLimitRule struct {
+	// Pattern matches target hosts: an exact host ("example.com"), its
+	// subdomains ("*.example.com") or any host ("*").
+	Pattern           string
+	Key               string        // RateLimitKeyHost (default) or RateLimitKeyDomain
+	RequestsPerSecond float64       // Sustained rate of new connections, zero means unlimited
+	Burst             int           // Connections allowed at once above the sustained rate, at least 1
+	MaxConnections    int           // Maximum number of concurrent connections, zero means unlimited
+	MaxWait           time.Duration // How long a connection waits for the limits to allow it, zero fails fast
+}
+
+// matches reports whether the rule applies to host.
+func (rule RateLimitRule) matches(host string) bool {
+	return hostPatternMatches(rule.Pattern, host)
+}
+
+// hostPatternMatches reports whether host matches pattern: an exact host
+// ("example.com"), its subdomains ("*.example.com") or any host ("*").
+func hostPatternMatches(pattern string, host string) bool {
+	if pattern == "*" {
+		return true
+	}
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return host == pattern
+}
+
+// validHostPattern reports whether pattern can be used with hostPatternMatches.
+// The pattern must be lower case.
+func validHostPattern(pattern string) bool {
+	host := strings.TrimPrefix(pattern, "*.")
+	return host != "" && (pattern == "*" || !strings.Contains(host, "*"))
+}
+
+// key returns the target that host is limited as.
+func (rule RateLimitRule) key(host string) string {
+	if rule.Key != RateLimitKeyDomain {
+		return host
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		// E.g. IP addresses and public suffixes, which are limited by themselves.
+		return host
+	}
+	return domain
+}
+
+// validate checks that the rule is well-formed and normalizes it.
+func (rule *RateLimitRule) validate() error {
+	rule.Pattern = strings.ToLower(rule.Pattern)
+	if !validHostPattern(rule.Pattern) {
+		return fmt.Errorf("invalid rate limit pattern: %q", rule.Pattern)
+	}
+	switch rule.Key {
+	case "":
+		rule.Key = RateLimitKeyHost
+	case RateLimitKeyHost, RateLimitKeyDomain:
+	default:
+		return fmt.Errorf("invalid rate limit key: %q", rule.Key)
+	}
+	if rule.RequestsPerSecond < 0 || rule.MaxConnections < 0 || rule.MaxWait < 0 {
+		return fmt.Errorf("rate limit for %q must not be negative", rule.Pattern)
+	}
+	rule.Burst = max(rule.Burst, 1)
+	return nil
+}
+
+// rateLimitState is the state of the limits of a single target.
+type rateLimitState struct {
+	tokens    float64       // Available connections of the token bucket
+	updatedAt time.Time     // When tokens was last refilled
+	active    int           // Number of concurrent connections
+	released  chan struct{} // Closed and replaced when a connection ends
+}
+
+// rateLimiter limits the connections to target hosts according to rules.
+// The first matching rule applies to a host.
+type rateLimiter struct {
+	mu      sync.Mutex
+	rules   []RateLimitRule
+	states  map[string]*rateLimitState // State per rule pattern and target
+	sweptAt time.Time
+	now     func() time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		states: map[string]*rateLimitState{},
+		now:    time.Now,
+	}
+}
+
+// validateRateLimits validates rules and returns a normalized copy of them.
+func validateRateLimits(rules []RateLimitRule) ([]RateLimitRule, error) {
+	rules = append([]RateLimitRule(nil), rules...)
+	for i := range rules {
+		if err := rules[i].validate(); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// configure replaces the rules, resetting the state of every target unless
+// the rules are unchanged. Connections that are already allowed are released
+// as usual.
+func (l *rateLimiter) configure(rules []RateLimitRule) error {
+	rules, err := validateRateLimits(rules)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if slices.Equal(l.rules, rules) {
+		return nil
+	}
+	l.rules = rules
+	l.states = map[string]*rateLimitState{}
+	return nil
+}
+
+// acquire waits until the rules allow a new connection to targetAddress, up
+// to the maximum wait of the matching rule. The returned function must be
+// called once the connection has ended. If the connection is not allowed in
+// time, an error with code common.ForwardRateLimited is returned.
+func (l *rateLimiter) acquire(ctx context.Context, targetAddress string) (func(), error) {
+	host := targetHost(targetAddress)
+	l.mu.Lock()
+	idx := -1
+	for i, rule := range l.rules {
+		if rule.matches(host) {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		l.mu.Unlock()
+		return func() {}, nil
+	}
+	rule := l.rules[idx]
+	stateKey := rule.Pattern + " " + rule.key(host)
+	deadline := l.now().Add(rule.MaxWait)
+
+	for {
+		now := l.now()
+		l.sweep(now)
+		state, ok := l.states[stateKey]
+		if !ok {
+			state = &rateLimitState{
+				tokens:    float64(rule.Burst),
+				updatedAt: now,
+				released:  make(chan struct{}),
+			}
+			l.states[stateKey] = state
+		}
+		l.refill(rule, state, now)
+
+		// Wait for a connection to end or for a token, whichever is missing.
+		var released <-chan struct{}
+		var wait time.Duration
+		switch {
+		case rule.MaxConnections > 0 && state.active >= rule.MaxConnections:
+			released = state.released
+			wait = deadline.Sub(now)
+		case rule.RequestsPerSecond > 0 && state.tokens < 1:
+			wait = time.Duration((1 - state.tokens) / rule.RequestsPerSecond * float64(time.Second))
+		default:
+			if rule.RequestsPerSecond > 0 {
+				state.tokens--
+			}
+			state.active++
+			l.mu.Unlock()
+			return func() { l.release(state) }, nil
+		}
+		l.mu.Unlock()
+
+		if wait <= 0 || now.Add(wait).After(deadline) && released == nil {
+			return nil, fault.New(
+				fmt.Sprintf("rate limit exceeded for %s", rule.key(host)),
+				common.ForwardRateLimited,
+			)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-released:
+		case <-timer.C:
+		}
+		timer.Stop()
+		l.mu.Lock()
+	}
+}
+
+// release ends a connection allowed by acquire.
+func (l *rateLimiter) release(state *rateLimitState) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	state.active--
+	close(state.released)
+	state.released = make(chan struct{})
+}
+
+// refill adds the tokens earned since the last refill.
+// The caller must hold l.mu.
+func (l *rateLimiter) refill(rule RateLimitRule, state *rateLimitState, now time.Time) {
+	if rule.RequestsPerSecond > 0 {
+		earned := now.Sub(state.updatedAt).Seconds() * rule.RequestsPerSecond
+		state.tokens = min(state.tokens+earned, float64(rule.Burst))
+	}
+	state.updatedAt = now
+}
+
+// sweep removes the state of idle targets with a full token bucket,
+// at most once per rateLimitSweepInterval.
+// The caller must hold l.mu.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.sweptAt) < rateLimitSweepInterval {
+		return
+	}
+	l.sweptAt = now
+	for key, state := range l.states {
+		pattern, _, _ := strings.Cut(key, " ")
+		for _, rule := range l.rules {
+			if rule.Pattern != pattern {
+				continue
+			}
+			l.refill(rule, state, now)
+			if state.active == 0 && state.tokens >= float64(rule.Burst) {
+				delete(l.states, key)
+			}
+			break
+		}
+	}
+}
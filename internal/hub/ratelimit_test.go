@@ -0,0 +1,93 @@
+package hub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/isacskoglund/rotox/internal/common"
+	"github.com/isacskoglund/rotox/internal/fault"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter_RequestsPerSecond(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(0, 0)
+	limiter := newRateLimiter()
+	limiter.now = func() time.Time { return now }
+	assert.NoError(t, limiter.configure([]RateLimitRule{
+		{Pattern: "*.example.com", Key: RateLimitKeyDomain, RequestsPerSecond: 2, Burst: 2},
+	}))
+
+	// The burst is shared by the hosts of the domain.
+	_, err := limiter.acquire(ctx, "a.example.com:443")
+	assert.NoError(t, err)
+	_, err = limiter.acquire(ctx, "B.example.com:80")
+	assert.NoError(t, err)
+	_, err = limiter.acquire(ctx, "a.example.com:443")
+	assert.Equal(t, common.ForwardRateLimited, fault.Code[common.ForwardErrorCode](err))
+
+	// Hosts matching no rule are not limited.
+	_, err = limiter.acquire(ctx, "example.org:443")
+	assert.NoError(t, err)
+
+	// Tokens are refilled at the sustained rate.
+	now = now.Add(500 * time.Millisecond)
+	_, err = limiter.acquire(ctx, "a.example.com:443")
+	assert.NoError(t, err)
+	_, err = limiter.acquire(ctx, "a.example.com:443")
+	assert.Error(t, err)
+
+	// Unchanged rules keep their state, changed rules start afresh.
+	assert.NoError(t, limiter.configure([]RateLimitRule{
+		{Pattern: "*.EXAMPLE.com", Key: RateLimitKeyDomain, RequestsPerSecond: 2, Burst: 2},
+	}))
+	_, err = limiter.acquire(ctx, "a.example.com:443")
+	assert.Error(t, err)
+	assert.NoError(t, limiter.configure([]RateLimitRule{
+		{Pattern: "*.example.com", Key: RateLimitKeyDomain, RequestsPerSecond: 1, Burst: 2},
+	}))
+	_, err = limiter.acquire(ctx, "a.example.com:443")
+	assert.NoError(t, err)
+}
+
+func TestRateLimiter_MaxConnections(t *testing.T) {
+	ctx := context.Background()
+	limiter := newRateLimiter()
+	assert.NoError(t, limiter.configure([]RateLimitRule{
+		{Pattern: "example.com", MaxConnections: 1, MaxWait: time.Second},
+		{Pattern: "*", MaxConnections: 1},
+	}))
+
+	// The first matching rule applies, and each host is limited separately.
+	release, err := limiter.acquire(ctx, "example.com:443")
+	assert.NoError(t, err)
+	releaseOther, err := limiter.acquire(ctx, "example.org:443")
+	assert.NoError(t, err)
+	_, err = limiter.acquire(ctx, "example.org:443")
+	assert.Equal(t, common.ForwardRateLimited, fault.Code[common.ForwardErrorCode](err))
+	releaseOther()
+
+	// A connection waits for another to end.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		release()
+	}()
+	release, err = limiter.acquire(ctx, "example.com:443")
+	assert.NoError(t, err)
+
+	// Waiting stops when the context ends.
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = limiter.acquire(ctx, "example.com:443")
+	assert.ErrorIs(t, err, context.Canceled)
+	release()
+}
+
+func TestRateLimiter_InvalidRules(t *testing.T) {
+	limiter := newRateLimiter()
+	assert.Error(t, limiter.configure([]RateLimitRule{{Pattern: ""}}))
+	assert.Error(t, limiter.configure([]RateLimitRule{{Pattern: "*.*.com"}}))
+	assert.Error(t, limiter.configure([]RateLimitRule{{Pattern: "*", Key: "path"}}))
+	assert.Error(t, limiter.configure([]RateLimitRule{{Pattern: "*", RequestsPerSecond: -1}}))
+}
@@ -0,0 +1,210 @@
+package hub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/isacskoglund/rotox/internal/common"
+)
+
+// directDialTimeout is the maximum duration of a direct dial from the hub.
+const directDialTimeout = 10 * time.Second
+
+// RouteAction is what happens to a connection matching a RouteRule.
+type RouteAction string
+
+// Actions of routing rules.
+const (
+	RouteToGroup RouteAction = "group"  // Use a probe of the rule's group
+	RouteReject  RouteAction = "reject" // Refuse the connection
+	RouteDirect  RouteAction = "direct" // Dial the target from the hub itself, bypassing the probes
+)
+
+// RouteRule decides how connections to the targets it matches are routed.
+// A rule matches a target if every matcher that is set matches. Rules without
+// matchers match every target.
+type RouteRule struct {
+	Name string // Name of the rule, used in logs
+
+	// Hosts are glob patterns (see path.Match) of which the target host must
+	// match one, e.g. "*.example.com". Matching is case-insensitive.
+	Hosts     []string
+	HostRegex string   // Regular expression the target host must match
+	Ports     []int    // Ports of which the target port must be one
+	Cidrs     []string // IP ranges of which the target must be in one, only IP address targets match
+
+	Action RouteAction
+	Group  string // Probe group used by RouteToGroup
+
+	// SetHeaders are headers set on plaintext HTTP requests to matching
+	// targets, overriding the HeaderPolicy. An empty value removes the header.
+	SetHeaders map[string]string
+}
+
+// compiledRoute is a validated RouteRule, ready for matching.
+type compiledRoute struct {
+	RouteRule
+	hostRegex *regexp.Regexp
+	prefixes  []netip.Prefix
+}
+
+// compile validates the rule and prepares it for matching.
+func (rule RouteRule) compile() (*compiledRoute, error) {
+	if rule.Name == "" {
+		return nil, errors.New("routing rule must have a name")
+	}
+	route := &compiledRoute{RouteRule: rule}
+	route.Hosts = make([]string, len(rule.Hosts))
+	for i, pattern := range rule.Hosts {
+		pattern = strings.ToLower(pattern)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("routing rule %q: invalid host pattern %q", rule.Name, pattern)
+		}
+		route.Hosts[i] = pattern
+	}
+	if rule.HostRegex != "" {
+		re, err := regexp.Compile(rule.HostRegex)
+		if err != nil {
+			return nil, fmt.Errorf("routing rule %q: invalid host regex: %w", rule.Name, err)
+		}
+		route.hostRegex = re
+	}
+	for _, port := range rule.Ports {
+		if port < 1 || port > 65535 {
+			return nil, fmt.Errorf("routing rule %q: invalid port %d", rule.Name, port)
+		}
+	}
+	for _, cidr := range rule.Cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("routing rule %q: invalid cidr %q", rule.Name, cidr)
+		}
+		route.prefixes = append(route.prefixes, prefix.Masked())
+	}
+	if err := validateSetHeaders(rule.SetHeaders); err != nil {
+		return nil, fmt.Errorf("routing rule %q: %w", rule.Name, err)
+	}
+	switch rule.Action {
+	case RouteToGroup:
+		if rule.Group == "" {
+			return nil, fmt.Errorf("routing rule %q: group action requires a group", rule.Name)
+		}
+	case RouteReject, RouteDirect:
+	default:
+		return nil, fmt.Errorf("routing rule %q: invalid action %q", rule.Name, rule.Action)
+	}
+	return route, nil
+}
+
+// matches reports whether the rule applies to a connection to host and port.
+func (route *compiledRoute) matches(host string, port int) bool {
+	if len(route.Hosts) > 0 && !slices.ContainsFunc(route.Hosts, func(pattern string) bool {
+		ok, _ := path.Match(pattern, host)
+		return ok
+	}) {
+		return false
+	}
+	if route.hostRegex != nil && !route.hostRegex.MatchString(host) {
+		return false
+	}
+	if len(route.Ports) > 0 && !slices.Contains(route.Ports, port) {
+		return false
+	}
+	if len(route.prefixes) > 0 {
+		addr, err := netip.ParseAddr(host)
+		if err != nil {
+			return false
+		}
+		addr = addr.Unmap()
+		if !slices.ContainsFunc(route.prefixes, func(prefix netip.Prefix) bool {
+			return prefix.Contains(addr)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// router holds the ordered routing rules. The first matching rule applies.
+type router struct {
+	routes atomic.Pointer[[]*compiledRoute]
+}
+
+// configure validates and replaces the rules.
+func (r *router) configure(rules []RouteRule) error {
+	routes := make([]*compiledRoute, len(rules))
+	for i, rule := range rules {
+		route, err := rule.compile()
+		if err != nil {
+			return err
+		}
+		routes[i] = route
+	}
+	r.routes.Store(&routes)
+	return nil
+}
+
+// match returns the first rule matching targetAddress, or nil if none does.
+func (r *router) match(targetAddress string) *RouteRule {
+	routes := r.routes.Load()
+	if routes == nil {
+		return nil
+	}
+	host, portString, err := net.SplitHostPort(targetAddress)
+	if err != nil {
+		host = targetAddress
+	}
+	host = strings.ToLower(host)
+	port, _ := strconv.Atoi(portString)
+	for _, route := range *routes {
+		if route.matches(host, port) {
+			return &route.RouteRule
+		}
+	}
+	return nil
+}
+
+// directDialer dials targets from the hub itself, for RouteDirect.
+type directDialer struct {
+	dialer net.Dialer
+}
+
+func (d *directDialer) Dial(ctx context.Context, address string) (common.Conn, error) {
+	dialStart := time.Now()
+	conn, err := d.dialer.DialContext(ctx, "tcp", address)
+	info := common.DialInfo{Duration: time.Since(dialStart)}
+	if err != nil {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) {
+			info.RemoteIp = common.IpOf(opErr.Addr)
+		}
+		return nil, common.WithDialInfo(common.InterpretDialError(err, "failed to dial directly"), info)
+	}
+	info.RemoteIp = common.IpOf(conn.RemoteAddr())
+	info.LocalAddress = conn.LocalAddr().String()
+	return &directConn{Conn: conn, info: info}, nil
+}
+
+// directConn is a connection dialed by directDialer.
+type directConn struct {
+	net.Conn
+	info common.DialInfo
+}
+
+func (conn *directConn) Name() string {
+	return "direct"
+}
+
+func (conn *directConn) DialInfo() common.DialInfo {
+	return conn.info
+}
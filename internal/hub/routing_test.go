@@ -0,0 +1,74 @@
+package hub
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/isacskoglund/rotox/internal/common"
+	"github.com/isacskoglund/rotox/internal/fault"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouter_Match(t *testing.T) {
+	r := &router{}
+	assert.Nil(t, r.match("example.com:443"))
+
+	assert.NoError(t, r.configure([]RouteRule{
+		{Name: "metadata", Cidrs: []string{"169.254.0.0/16", "fd00::/8"}, Action: RouteReject},
+		{Name: "internal", Hosts: []string{"*.corp.internal", "corp.internal"}, Action: RouteDirect},
+		{Name: "eu-https", HostRegex: `\.(de|fr)$`, Ports: []int{443}, Action: RouteToGroup, Group: "eu"},
+		{Name: "vm", Hosts: []string{"*.example.com"}, Action: RouteToGroup, Group: "vm"},
+	}))
+	tests := []struct {
+		target string
+		rule   string
+	}{
+		{"169.254.169.254:80", "metadata"},
+		{"[fd12::1]:443", "metadata"},
+		{"[::ffff:169.254.1.1]:80", "metadata"},
+		{"10.0.0.1:80", ""},
+		{"db.corp.internal:5432", "internal"},
+		{"CORP.internal:80", "internal"},
+		{"notcorp.internal:80", ""},
+		{"shop.de:443", "eu-https"},
+		{"shop.de:80", ""},
+		{"a.b.example.com:80", "vm"},
+		{"example.com:80", ""},
+	}
+	for _, test := range tests {
+		rule := r.match(test.target)
+		if test.rule == "" {
+			assert.Nil(t, rule, test.target)
+		} else if assert.NotNil(t, rule, test.target) {
+			assert.Equal(t, test.rule, rule.Name, test.target)
+		}
+	}
+}
+
+func TestRouter_ConfigureInvalid(t *testing.T) {
+	r := &router{}
+	for _, rule := range []RouteRule{
+		{Action: RouteReject},
+		{Name: "a", Action: "drop"},
+		{Name: "a", Action: RouteToGroup},
+		{Name: "a", Hosts: []string{"[a-"}, Action: RouteReject},
+		{Name: "a", HostRegex: "(", Action: RouteReject},
+		{Name: "a", Ports: []int{0}, Action: RouteReject},
+		{Name: "a", Cidrs: []string{"10.0.0.1"}, Action: RouteReject},
+		{Name: "a", SetHeaders: map[string]string{"Bad Name": "x"}, Action: RouteDirect},
+	} {
+		assert.Error(t, r.configure([]RouteRule{rule}), rule)
+	}
+}
+
+func TestCore_ForwardRejectedByRoute(t *testing.T) {
+	core := NewCore(slog.New(slog.DiscardHandler), []ProbeSpec{{Name: "a", Dialer: &stubDialer{"a"}}})
+	assert.NoError(t, core.SetRoutes([]RouteRule{{Name: "block", Hosts: []string{"blocked.com"}, Action: RouteReject}}))
+
+	err := core.forward(context.Background(), "blocked.com:443", routingHints{}, func() (common.Conn, error) {
+		t.Fatal("the client connection must not be accepted")
+		return nil, nil
+	})
+	assert.Equal(t, common.ForwardRejected, fault.Code[common.ForwardErrorCode](err))
+}
@@ -0,0 +1,150 @@
+package hub
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"sync/atomic"
+)
+
+// Names of the built-in selection strategies.
+const (
+	StrategyRoundRobin       = "round_robin"
+	StrategyRandom           = "random"
+	StrategyWeighted         = "weighted"
+	StrategyLeastConnections = "least_connections"
+	StrategyLatency          = "latency"
+	StrategyConsistentHash   = "consistent_hash"
+)
+
+// Selector chooses which probe to use for a connection.
+type Selector interface {
+	// Select returns one of the candidates to use for a connection to
+	// targetAddress. Candidates is never empty.
+	Select(candidates []*Probe, targetAddress string) *Probe
+}
+
+// NewSelector creates one of the built-in selectors by strategy name.
+func NewSelector(strategy string) (Selector, error) {
+	switch strategy {
+	case StrategyRoundRobin:
+		return &roundRobinSelector{}, nil
+	case StrategyRandom:
+		return randomSelector{}, nil
+	case StrategyWeighted:
+		return weightedSelector{}, nil
+	case StrategyLeastConnections:
+		return leastConnectionsSelector{}, nil
+	case StrategyLatency:
+		return latencySelector{}, nil
+	case StrategyConsistentHash:
+		return consistentHashSelector{}, nil
+	}
+	return nil, fmt.Errorf("unknown selection strategy: %s", strategy)
+}
+
+// roundRobinSelector cycles through the candidates in order.
+type roundRobinSelector struct {
+	next atomic.Uint64
+}
+
+func (s *roundRobinSelector) Select(candidates []*Probe, _ string) *Probe {
+	n := s.next.Add(1) - 1
+	return candidates[n%uint64(len(candidates))]
+}
+
+// randomSelector picks a uniformly random candidate.
+type randomSelector struct{}
+
+func (randomSelector) Select(candidates []*Probe, _ string) *Probe {
+	return candidates[rand.IntN(len(candidates))]
+}
+
+// weightedSelector picks a random candidate with probability
+// proportional to its weight.
+type weightedSelector struct{}
+
+func (weightedSelector) Select(candidates []*Probe, _ string) *Probe {
+	total := 0
+	for _, probe := range candidates {
+		total += probe.Weight()
+	}
+	n := rand.IntN(total)
+	for _, probe := range candidates {
+		n -= probe.Weight()
+		if n < 0 {
+			return probe
+		}
+	}
+	panic("unreachable")
+}
+
+// leastConnectionsSelector picks the candidate with the fewest active
+// connections. Ties are broken by starting the scan at a random offset.
+type leastConnectionsSelector struct{}
+
+func (leastConnectionsSelector) Select(candidates []*Probe, _ string) *Probe {
+	offset := rand.IntN(len(candidates))
+	best := candidates[offset]
+	for i := 1; i < len(candidates); i++ {
+		probe := candidates[(offset+i)%len(candidates)]
+		if probe.ActiveConnections() < best.ActiveConnections() {
+			best = probe
+		}
+	}
+	return best
+}
+
+// latencySelector uses the "power of two choices": it samples two random
+// candidates and picks the one with the lowest dial latency. Probes without
+// measurements are preferred, and sampling keeps slow probes from being
+// starved entirely, allowing their average to recover.
+type latencySelector struct{}
+
+func (latencySelector) Select(candidates []*Probe, _ string) *Probe {
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+	i := rand.IntN(len(candidates))
+	j := rand.IntN(len(candidates) - 1)
+	if j >= i {
+		j++
+	}
+	a, b := candidates[i], candidates[j]
+	if b.Latency() < a.Latency() {
+		return b
+	}
+	return a
+}
+
+// consistentHashSelector maps each target host to the same candidate using
+// rendezvous hashing, so that a host keeps using the same probe and only
+// the hosts of a removed probe are remapped when candidates change.
+type consistentHashSelector struct{}
+
+func (consistentHashSelector) Select(candidates []*Probe, targetAddress string) *Probe {
+	host := targetHost(targetAddress)
+	var best *Probe
+	var bestScore uint64
+	for _, probe := range candidates {
+		h := fnv.New64a()
+		h.Write([]byte(probe.Name()))
+		h.Write([]byte{0})
+		h.Write([]byte(host))
+		if score := h.Sum64(); best == nil || score > bestScore {
+			best, bestScore = probe, score
+		}
+	}
+	return best
+}
+
+// targetHost returns the host of a target address, without the port.
+func targetHost(targetAddress string) string {
+	host, _, err := net.SplitHostPort(targetAddress)
+	if err != nil {
+		host = targetAddress
+	}
+	return strings.ToLower(host)
+}
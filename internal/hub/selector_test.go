@@ -0,0 +1,102 @@
+package hub
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestProbes(weights ...int) []*Probe {
+	probes := make([]*Probe, len(weights))
+	for i, weight := range weights {
+		probes[i] = newProbe(ProbeSpec{
+			Name:   fmt.Sprintf("probe-%d", i),
+			Weight: weight,
+		})
+	}
+	return probes
+}
+
+func TestNewSelector(t *testing.T) {
+	for _, strategy := range []string{
+		StrategyRoundRobin,
+		StrategyRandom,
+		StrategyWeighted,
+		StrategyLeastConnections,
+		StrategyLatency,
+		StrategyConsistentHash,
+	} {
+		t.Run(strategy, func(t *testing.T) {
+			selector, err := NewSelector(strategy)
+			assert.NoError(t, err)
+			probes := newTestProbes(1, 1, 1)
+			for range 10 {
+				assert.Contains(t, probes, selector.Select(probes, "example.com:443"))
+			}
+			assert.NotNil(t, selector.Select(probes[:1], "example.com:443"))
+		})
+	}
+
+	_, err := NewSelector("unknown")
+	assert.Error(t, err)
+}
+
+func TestRoundRobinSelector(t *testing.T) {
+	probes := newTestProbes(1, 1, 1)
+	selector := &roundRobinSelector{}
+	for i := range 6 {
+		assert.Equal(t, probes[i%3], selector.Select(probes, "example.com:443"))
+	}
+}
+
+func TestWeightedSelector(t *testing.T) {
+	probes := newTestProbes(1, 9)
+	counts := map[*Probe]int{}
+	for range 10000 {
+		counts[weightedSelector{}.Select(probes, "example.com:443")]++
+	}
+	assert.InDelta(t, 1000, counts[probes[0]], 300)
+	assert.InDelta(t, 9000, counts[probes[1]], 300)
+}
+
+func TestLeastConnectionsSelector(t *testing.T) {
+	probes := newTestProbes(1, 1, 1)
+	probes[0].active.Store(2)
+	probes[2].active.Store(1)
+	for range 10 {
+		assert.Equal(t, probes[1], leastConnectionsSelector{}.Select(probes, "example.com:443"))
+	}
+}
+
+func TestLatencySelector(t *testing.T) {
+	probes := newTestProbes(1, 1)
+	probes[0].observeLatency(100 * time.Millisecond)
+	probes[1].observeLatency(10 * time.Millisecond)
+	for range 10 {
+		assert.Equal(t, probes[1], latencySelector{}.Select(probes, "example.com:443"))
+	}
+
+	// The average moves towards new samples.
+	probes[1].observeLatency(1010 * time.Millisecond)
+	assert.Equal(t, 310*time.Millisecond, probes[1].Latency())
+	assert.Equal(t, probes[0], latencySelector{}.Select(probes, "example.com:443"))
+}
+
+func TestConsistentHashSelector(t *testing.T) {
+	probes := newTestProbes(1, 1, 1, 1)
+	selected := consistentHashSelector{}.Select(probes, "example.com:443")
+
+	// The port does not matter.
+	assert.Equal(t, selected, consistentHashSelector{}.Select(probes, "example.com:80"))
+
+	// Removing another probe does not remap the host.
+	var others []*Probe
+	for _, probe := range probes {
+		if probe != selected {
+			others = append(others, probe)
+		}
+	}
+	assert.Equal(t, selected, consistentHashSelector{}.Select(append(others[1:], selected), "example.com:443"))
+}
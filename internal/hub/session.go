@@ -0,0 +1,132 @@
+package hub
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// Default limits for sticky sessions.
+const (
+	defaultSessionTTL  = 10 * time.Minute
+	defaultMaxSessions = 10000
+)
+
+// sessionStore pins client-named sessions to a probe for a limited time.
+// Sessions expire once their TTL has passed and the number of sessions is
+// bounded; when full, the session closest to expiry is evicted.
+type sessionStore struct {
+	mu          sync.Mutex          // Protects the fields below
+	ttl         time.Duration       // Lifetime of a session
+	maxSessions int                 // Maximum number of concurrent sessions
+	sessions    map[string]*session // Sessions by id
+	expiry      sessionHeap         // Sessions ordered by expiry
+	now         func() time.Time    // Clock, replaceable for testing
+}
+
+// session is a single sticky session.
+type session struct {
+	id        string    // Client provided session id
+	probe     *Probe    // Probe the session is pinned to
+	expiresAt time.Time // When the session expires
+	heapIdx   int       // Position in sessionStore.expiry
+}
+
+func newSessionStore(ttl time.Duration, maxSessions int) *sessionStore {
+	return &sessionStore{
+		ttl:         ttl,
+		maxSessions: maxSessions,
+		sessions:    make(map[string]*session),
+		now:         time.Now,
+	}
+}
+
+// getOrPut returns the probe pinned to the session with the given id.
+// If there is no such session, or its probe is no longer eligible, a new
+// session lasting ttl (or the store's TTL if zero) is pinned to the probe
+// returned by pick.
+func (store *sessionStore) getOrPut(
+	id string,
+	ttl time.Duration,
+	eligible func(*Probe) bool,
+	pick func() *Probe,
+) *Probe {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	now := store.now()
+	store.purge(now)
+
+	if s, ok := store.sessions[id]; ok {
+		if eligible(s.probe) {
+			return s.probe
+		}
+		store.delete(s)
+	}
+	for len(store.sessions) >= store.maxSessions && len(store.expiry) > 0 {
+		store.delete(store.expiry[0])
+	}
+	if ttl <= 0 {
+		ttl = store.ttl
+	}
+	s := &session{
+		id:        id,
+		probe:     pick(),
+		expiresAt: now.Add(ttl),
+	}
+	store.sessions[id] = s
+	heap.Push(&store.expiry, s)
+	return s.probe
+}
+
+// remove ends the session with the given id, if any.
+func (store *sessionStore) remove(id string) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if s, ok := store.sessions[id]; ok {
+		store.delete(s)
+	}
+}
+
+// purge deletes all sessions that have expired.
+func (store *sessionStore) purge(now time.Time) {
+	for len(store.expiry) > 0 && !store.expiry[0].expiresAt.After(now) {
+		store.delete(store.expiry[0])
+	}
+}
+
+func (store *sessionStore) delete(s *session) {
+	heap.Remove(&store.expiry, s.heapIdx)
+	delete(store.sessions, s.id)
+}
+
+// sessionHeap implements heap.Interface as a min-heap on expiry time.
+type sessionHeap []*session
+
+func (h sessionHeap) Len() int {
+	return len(h)
+}
+
+func (h sessionHeap) Less(i, j int) bool {
+	return h[i].expiresAt.Before(h[j].expiresAt)
+}
+
+func (h sessionHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].heapIdx = i
+	h[j].heapIdx = j
+}
+
+func (h *sessionHeap) Push(x any) {
+	s := x.(*session)
+	s.heapIdx = len(*h)
+	*h = append(*h, s)
+}
+
+func (h *sessionHeap) Pop() any {
+	old := *h
+	n := len(old)
+	s := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return s
+}
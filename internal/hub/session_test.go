@@ -0,0 +1,56 @@
+package hub
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionStore(t *testing.T) {
+	now := time.Unix(0, 0)
+	store := newSessionStore(time.Minute, 2)
+	store.now = func() time.Time { return now }
+	probes := make([]*Probe, 8)
+	for i := range probes {
+		probes[i] = newProbe(ProbeSpec{Name: fmt.Sprintf("probe-%d", i)})
+	}
+	always := func(*Probe) bool { return true }
+	pick := func(probeIdx int) func() *Probe {
+		return func() *Probe { return probes[probeIdx] }
+	}
+
+	// A new session is pinned to the picked probe.
+	assert.Equal(t, probes[1], store.getOrPut("a", 0, always, pick(1)))
+	// An existing session keeps its probe.
+	now = now.Add(30 * time.Second)
+	assert.Equal(t, probes[1], store.getOrPut("a", 0, always, pick(2)))
+	assert.Equal(t, probes[2], store.getOrPut("b", 0, always, pick(2)))
+
+	// The session closest to expiry is evicted when the store is full.
+	assert.Equal(t, probes[3], store.getOrPut("c", 0, always, pick(3)))
+	assert.Len(t, store.sessions, 2)
+	assert.Equal(t, probes[4], store.getOrPut("a", 0, always, pick(4)))
+	assert.Equal(t, probes[4], store.getOrPut("a", 0, always, pick(5)))
+
+	// Expired sessions are replaced.
+	now = now.Add(time.Minute)
+	assert.Equal(t, probes[6], store.getOrPut("a", 0, always, pick(6)))
+	assert.Len(t, store.sessions, 1)
+
+	// Removed sessions are replaced.
+	store.remove("a")
+	assert.Equal(t, probes[7], store.getOrPut("a", 0, always, pick(7)))
+
+	// Sessions pinned to an ineligible probe are replaced.
+	never := func(*Probe) bool { return false }
+	assert.Equal(t, probes[0], store.getOrPut("a", 0, never, pick(0)))
+	assert.Len(t, store.sessions, 1)
+
+	// A new session may ask for its own lifetime.
+	assert.Equal(t, probes[1], store.getOrPut("d", 2*time.Minute, always, pick(1)))
+	now = now.Add(90 * time.Second)
+	assert.Equal(t, probes[1], store.getOrPut("d", 0, always, pick(2)))
+	assert.Equal(t, probes[3], store.getOrPut("a", 0, always, pick(3)))
+}
@@ -0,0 +1,316 @@
+package hub
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/isacskoglund/rotox/internal/common"
+	"github.com/isacskoglund/rotox/internal/fault"
+	"github.com/isacskoglund/rotox/internal/tracing"
+)
+
+// SOCKS protocol constants (RFC 1928 and RFC 1929).
+const (
+	socks5Version       = 0x05
+	socks5AuthVersion   = 0x01
+	socks5CmdConnect    = 0x01
+	socks5AtypIPv4      = 0x01
+	socks5AtypDomain    = 0x03
+	socks5AtypIPv6      = 0x04
+	socks5MethodNoAuth  = 0x00
+	socks5MethodUserPwd = 0x02
+	socks5MethodNone    = 0xff
+)
+
+// socks5Reply is a reply code sent in response to a SOCKS5 request.
+type socks5Reply byte
+
+// Reply codes defined in RFC 1928, section 6.
+const (
+	socks5Succeeded               socks5Reply = 0x00
+	socks5GeneralFailure          socks5Reply = 0x01
+	socks5NotAllowed              socks5Reply = 0x02
+	socks5NetworkUnreachable      socks5Reply = 0x03
+	socks5HostUnreachable         socks5Reply = 0x04
+	socks5ConnectionRefused       socks5Reply = 0x05
+	socks5CommandNotSupported     socks5Reply = 0x07
+	socks5AddressTypeNotSupported socks5Reply = 0x08
+)
+
+// socks5HandshakeTimeout bounds the time a client may spend on the
+// handshake before the connection is dropped.
+const socks5HandshakeTimeout = 10 * time.Second
+
+// Socks5Api implements a SOCKS5 proxy server (RFC 1928).
+// Only the CONNECT command is supported. Each request is forwarded
+// through the hub's probe network, just like HttpApi does.
+type Socks5Api struct {
+	logger *slog.Logger  // Logger for SOCKS5 API operations
+	core   *Core         // Core hub service for request forwarding
+	auth   Authenticator // Verifies client credentials, nil disables authentication
+	labels LabelSelector // Default label selector for clients that do not send one
+}
+
+// NewSocks5Api creates a new SOCKS5 API instance that serves proxy requests.
+// The API handles the SOCKS5 protocol specifics and delegates actual
+// forwarding to the provided core service.
+func NewSocks5Api(
+	logger *slog.Logger,
+	core *Core,
+) *Socks5Api {
+	return &Socks5Api{
+		logger: logger,
+		core:   core,
+	}
+}
+
+// SetAuthenticator requires clients to authenticate with the
+// username/password method (RFC 1929), verified by auth.
+func (api *Socks5Api) SetAuthenticator(auth Authenticator) {
+	api.auth = auth
+}
+
+// SetLabelSelector sets the label selector used for clients that do not
+// send labels in the username.
+func (api *Socks5Api) SetLabelSelector(selector LabelSelector) {
+	api.labels = selector
+}
+
+// Serve accepts incoming connections on the listener and handles each
+// of them in a separate goroutine. It blocks until the listener fails.
+func (api *Socks5Api) Serve(lis net.Listener) error {
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			return err
+		}
+		go api.handle(conn)
+	}
+}
+
+func (api *Socks5Api) handle(conn net.Conn) {
+	defer conn.Close()
+	traceId, err := uuid.NewRandom()
+	if err != nil {
+		panic(fmt.Errorf("failed to randomize uuid: %w", err))
+	}
+	ctx := tracing.WithTraceId(context.Background(), traceId.String())
+
+	conn.SetDeadline(time.Now().Add(socks5HandshakeTimeout))
+	target, hints, err := api.handshake(conn)
+	if err != nil {
+		api.logger.LogAttrs(
+			ctx,
+			slog.LevelInfo,
+			"SOCKS5 handshake failed",
+			slog.Any("error", err),
+		)
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	api.logger.LogAttrs(
+		ctx,
+		slog.LevelInfo,
+		"Handling SOCKS5 CONNECT request",
+	)
+
+	// Waits before the connection is accepted end when the client leaves.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	watch := watchClient(conn, cancel)
+	defer watch.stop()
+
+	accepted := false
+	accept := func() (common.Conn, error) {
+		accepted = true
+		reader := watch.stop()
+		if err := writeSocks5Reply(conn, socks5Succeeded); err != nil {
+			return nil, fmt.Errorf("failed to write success reply to client: %w", err)
+		}
+		return &customConn{
+			Reader: reader,
+			Writer: conn,
+			Closer: conn,
+			namer:  &customNamer{name: "client"},
+		}, nil
+	}
+
+	err = api.core.forward(ctx, target, hints, accept)
+	if err == nil {
+		return
+	}
+	api.logger.LogAttrs(
+		ctx,
+		slog.LevelInfo,
+		"Failed to forward SOCKS5 connection",
+		slog.Any("error", err),
+	)
+	if !accepted {
+		writeSocks5Reply(conn, socks5ReplyFromError(err))
+	}
+}
+
+// handshake performs method negotiation, optional authentication and
+// reads the client request. It returns the requested target address
+// and the routing hints encoded in the username, if any.
+func (api *Socks5Api) handshake(conn net.Conn) (string, routingHints, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", routingHints{}, fmt.Errorf("failed to read greeting: %w", err)
+	}
+	if header[0] != socks5Version {
+		return "", routingHints{}, fmt.Errorf("unsupported SOCKS version %d", header[0])
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return "", routingHints{}, fmt.Errorf("failed to read methods: %w", err)
+	}
+
+	// Username/password is required when authentication is enabled.
+	// Otherwise it is still preferred, since the username may carry hints.
+	method := byte(socks5MethodNoAuth)
+	if api.auth != nil || slices.Contains(methods, socks5MethodUserPwd) {
+		method = socks5MethodUserPwd
+	}
+	if !slices.Contains(methods, method) {
+		conn.Write([]byte{socks5Version, socks5MethodNone})
+		return "", routingHints{}, errors.New("no acceptable authentication method")
+	}
+	if _, err := conn.Write([]byte{socks5Version, method}); err != nil {
+		return "", routingHints{}, fmt.Errorf("failed to write method selection: %w", err)
+	}
+	var hints routingHints
+	if method == socks5MethodUserPwd {
+		var err error
+		if hints, err = api.authenticate(conn); err != nil {
+			return "", routingHints{}, err
+		}
+	}
+	if len(hints.labels) == 0 {
+		hints.labels = api.labels
+	}
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return "", routingHints{}, fmt.Errorf("failed to read request: %w", err)
+	}
+	if request[0] != socks5Version {
+		return "", routingHints{}, fmt.Errorf("unsupported SOCKS version %d in request", request[0])
+	}
+	if request[1] != socks5CmdConnect {
+		writeSocks5Reply(conn, socks5CommandNotSupported)
+		return "", routingHints{}, fmt.Errorf("unsupported command %d", request[1])
+	}
+
+	var host string
+	switch request[3] {
+	case socks5AtypIPv4:
+		addr := make([]byte, net.IPv4len)
+		if _, err := io.ReadFull(conn, addr); err != nil {
+			return "", routingHints{}, fmt.Errorf("failed to read IPv4 address: %w", err)
+		}
+		host = net.IP(addr).String()
+	case socks5AtypIPv6:
+		addr := make([]byte, net.IPv6len)
+		if _, err := io.ReadFull(conn, addr); err != nil {
+			return "", routingHints{}, fmt.Errorf("failed to read IPv6 address: %w", err)
+		}
+		host = net.IP(addr).String()
+	case socks5AtypDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return "", routingHints{}, fmt.Errorf("failed to read domain length: %w", err)
+		}
+		domain := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, domain); err != nil {
+			return "", routingHints{}, fmt.Errorf("failed to read domain: %w", err)
+		}
+		host = string(domain)
+	default:
+		writeSocks5Reply(conn, socks5AddressTypeNotSupported)
+		return "", routingHints{}, fmt.Errorf("unsupported address type %d", request[3])
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return "", routingHints{}, fmt.Errorf("failed to read port: %w", err)
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), hints, nil
+}
+
+// authenticate performs the username/password subnegotiation (RFC 1929).
+// The credentials are only verified if an authenticator is set.
+// It returns the routing hints encoded in the username.
+func (api *Socks5Api) authenticate(conn net.Conn) (routingHints, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return routingHints{}, fmt.Errorf("failed to read authentication header: %w", err)
+	}
+	if header[0] != socks5AuthVersion {
+		return routingHints{}, fmt.Errorf("unsupported authentication version %d", header[0])
+	}
+	username := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, username); err != nil {
+		return routingHints{}, fmt.Errorf("failed to read username: %w", err)
+	}
+	length := make([]byte, 1)
+	if _, err := io.ReadFull(conn, length); err != nil {
+		return routingHints{}, fmt.Errorf("failed to read password length: %w", err)
+	}
+	password := make([]byte, length[0])
+	if _, err := io.ReadFull(conn, password); err != nil {
+		return routingHints{}, fmt.Errorf("failed to read password: %w", err)
+	}
+
+	// Credentials are checked first, so that strangers learn nothing about
+	// the grammar of the username.
+	user, hints, err := api.core.parseUsername(string(username))
+	if api.auth != nil && !api.auth.Authenticate(user, string(password)) {
+		conn.Write([]byte{socks5AuthVersion, 0x01})
+		return routingHints{}, errors.New("invalid credentials")
+	}
+	if err != nil {
+		conn.Write([]byte{socks5AuthVersion, 0x01})
+		return routingHints{}, err
+	}
+	if _, err := conn.Write([]byte{socks5AuthVersion, 0x00}); err != nil {
+		return routingHints{}, fmt.Errorf("failed to write authentication status: %w", err)
+	}
+	return hints, nil
+}
+
+// socks5ReplyFromError maps a forwarding error to the matching SOCKS5 reply code.
+func socks5ReplyFromError(err error) socks5Reply {
+	switch fault.Code[common.ForwardErrorCode](err) {
+	case common.ForwardFailedToResolveHost, common.ForwardHostUnreachable, common.ForwardTimeout:
+		return socks5HostUnreachable
+	case common.ForwardNetworkUnreachable:
+		return socks5NetworkUnreachable
+	case common.ForwardConnectionRefused:
+		return socks5ConnectionRefused
+	case common.ForwardRejected, common.ForwardBlockedByPolicy:
+		return socks5NotAllowed
+	default:
+		return socks5GeneralFailure
+	}
+}
+
+// writeSocks5Reply writes a reply with an unspecified (zero) bound address.
+func writeSocks5Reply(w io.Writer, reply socks5Reply) error {
+	_, err := w.Write([]byte{
+		socks5Version, byte(reply), 0x00,
+		socks5AtypIPv4, 0, 0, 0, 0, // BND.ADDR
+		0, 0, // BND.PORT
+	})
+	return err
+}
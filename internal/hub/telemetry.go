@@ -10,6 +10,9 @@ import (
 type telemetryPublisher interface {
 	TransferPublisher() common.Publisher[telemetry.TransferEvent]
 	ConnectionPublisher() common.Publisher[telemetry.ConnectionEvent]
+	ProbePublisher() common.Publisher[telemetry.ProbeEvent]
+	QueuePublisher() common.Publisher[telemetry.QueueEvent]
+	PolicyPublisher() common.Publisher[telemetry.PolicyEvent]
 }
 
 type multiPublisher[T any] struct {
@@ -31,12 +34,18 @@ func (m *multiPublisher[T]) register(pub common.Publisher[T]) {
 type multiTelemetryPublisher struct {
 	transferEvents   *multiPublisher[telemetry.TransferEvent]
 	connectionEvents *multiPublisher[telemetry.ConnectionEvent]
+	probeEvents      *multiPublisher[telemetry.ProbeEvent]
+	queueEvents      *multiPublisher[telemetry.QueueEvent]
+	policyEvents     *multiPublisher[telemetry.PolicyEvent]
 }
 
 func newMultiTelemetryPublisher() *multiTelemetryPublisher {
 	return &multiTelemetryPublisher{
 		transferEvents:   &multiPublisher[telemetry.TransferEvent]{},
 		connectionEvents: &multiPublisher[telemetry.ConnectionEvent]{},
+		probeEvents:      &multiPublisher[telemetry.ProbeEvent]{},
+		queueEvents:      &multiPublisher[telemetry.QueueEvent]{},
+		policyEvents:     &multiPublisher[telemetry.PolicyEvent]{},
 	}
 }
 
@@ -48,7 +57,22 @@ func (mtp *multiTelemetryPublisher) ConnectionPublisher() common.Publisher[telem
 	return mtp.connectionEvents
 }
 
+func (mtp *multiTelemetryPublisher) ProbePublisher() common.Publisher[telemetry.ProbeEvent] {
+	return mtp.probeEvents
+}
+
+func (mtp *multiTelemetryPublisher) QueuePublisher() common.Publisher[telemetry.QueueEvent] {
+	return mtp.queueEvents
+}
+
+func (mtp *multiTelemetryPublisher) PolicyPublisher() common.Publisher[telemetry.PolicyEvent] {
+	return mtp.policyEvents
+}
+
 func (mtp *multiTelemetryPublisher) register(pub telemetryPublisher) {
 	mtp.transferEvents.register(pub.TransferPublisher())
 	mtp.connectionEvents.register(pub.ConnectionPublisher())
+	mtp.probeEvents.register(pub.ProbePublisher())
+	mtp.queueEvents.register(pub.QueuePublisher())
+	mtp.policyEvents.register(pub.PolicyPublisher())
 }
@@ -0,0 +1,159 @@
+package hub
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// tlsCheckInterval is how often a TlsReloader checks its files for changes.
+const tlsCheckInterval = 10 * time.Second
+
+// TlsFiles are the PEM files configuring TLS for a proxy listener.
+type TlsFiles struct {
+	CertFile     string // Certificate chain presented to clients
+	KeyFile      string // Private key of the certificate
+	ClientCaFile string // CA certificates that must sign the client certificates, empty to not require any
+}
+
+// TlsReloader provides the TLS configuration of a proxy listener, loaded from
+// TlsFiles. The files are checked for changes when clients connect, at most
+// every tlsCheckInterval, and loaded again once they have changed, e.g. when
+// the certificate is renewed. If changed files fail to load, the previous
+// configuration stays in use.
+type TlsReloader struct {
+	logger *slog.Logger
+
+	mu        sync.Mutex
+	files     TlsFiles
+	config    *tls.Config // Configuration loaded from files
+	modTimes  []time.Time // Modification times of files when they were loaded
+	checkedAt time.Time   // When files were last checked for changes
+}
+
+// NewTlsReloader loads files and returns a reloader of them.
+func NewTlsReloader(logger *slog.Logger, files TlsFiles) (*TlsReloader, error) {
+	r := &TlsReloader{logger: logger}
+	if err := r.SetFiles(files); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// SetFiles loads files, which are used for new connections from now on.
+func (r *TlsReloader) SetFiles(files TlsFiles) error {
+	modTimes, err := tlsModTimes(files)
+	if err != nil {
+		return err
+	}
+	config, err := loadTlsConfig(files)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.files = files
+	r.config = config
+	r.modTimes = modTimes
+	r.checkedAt = time.Now()
+	return nil
+}
+
+// Config returns a TLS configuration for a listener. Every connection uses
+// the files loaded at the time the client connects.
+func (r *TlsReloader) Config() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.current(), nil
+		},
+	}
+}
+
+// current returns the configuration loaded from the files, loading them
+// again first if they have changed.
+func (r *TlsReloader) current() *tls.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checkedAt) < tlsCheckInterval {
+		return r.config
+	}
+	r.checkedAt = time.Now()
+
+	modTimes, err := tlsModTimes(r.files)
+	if err == nil && slices.Equal(modTimes, r.modTimes) {
+		return r.config
+	}
+	var config *tls.Config
+	if err == nil {
+		config, err = loadTlsConfig(r.files)
+	}
+	if err != nil {
+		r.logger.LogAttrs(
+			context.Background(),
+			slog.LevelWarn,
+			"Failed to reload changed TLS files, keeping the previous ones",
+			slog.String("cert_file", r.files.CertFile),
+			slog.Any("error", err),
+		)
+		return r.config
+	}
+	r.logger.LogAttrs(
+		context.Background(),
+		slog.LevelInfo,
+		"Reloaded changed TLS files",
+		slog.String("cert_file", r.files.CertFile),
+	)
+	r.config = config
+	r.modTimes = modTimes
+	return r.config
+}
+
+// loadTlsConfig loads the TLS configuration of a listener from files.
+func loadTlsConfig(files TlsFiles) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(files.CertFile, files.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading certificate: %w", err)
+	}
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	if files.ClientCaFile != "" {
+		data, err := os.ReadFile(files.ClientCaFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.New("error loading client CA file: no certificates found")
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// tlsModTimes returns the modification times of files.
+func tlsModTimes(files TlsFiles) ([]time.Time, error) {
+	var modTimes []time.Time
+	for _, name := range []string{files.CertFile, files.KeyFile, files.ClientCaFile} {
+		if name == "" {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+		modTimes = append(modTimes, info.ModTime())
+	}
+	return modTimes, nil
+}
@@ -0,0 +1,169 @@
+package hub
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testCert is a certificate with its private key, signed by parent or
+// self-signed if parent is nil.
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, name string, isCa bool, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCa,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+// write writes the certificate and key as PEM files to dir.
+func (c *testCert) write(t *testing.T, dir string, name string) (certFile string, keyFile string) {
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	keyDer, err := x509.MarshalECPrivateKey(c.key)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0o600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+	return certFile, keyFile
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+// handshake connects a client using config to a listener using the
+// reloader, returning the server certificate seen by the client.
+func handshake(r *TlsReloader, config *tls.Config) (*x509.Certificate, error) {
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", r.Config())
+	if err != nil {
+		return nil, err
+	}
+	defer lis.Close()
+	serverErr := make(chan error, 1)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		defer conn.Close()
+		serverErr <- conn.(*tls.Conn).Handshake()
+	}()
+
+	client, err := tls.Dial("tcp", lis.Addr().String(), config)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	if err := <-serverErr; err != nil {
+		return nil, err
+	}
+	return client.ConnectionState().PeerCertificates[0], nil
+}
+
+func TestTlsReloader_ReloadsChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	first := newTestCert(t, "proxy.example.com", true, nil)
+	certFile, keyFile := first.write(t, dir, "proxy")
+	r, err := NewTlsReloader(slog.New(slog.DiscardHandler), TlsFiles{CertFile: certFile, KeyFile: keyFile})
+	assert.NoError(t, err)
+
+	clientConfig := func() *tls.Config {
+		return &tls.Config{InsecureSkipVerify: true}
+	}
+	cert, err := handshake(r, clientConfig())
+	assert.NoError(t, err)
+	assert.Equal(t, first.cert.SerialNumber, cert.SerialNumber)
+
+	second := newTestCert(t, "proxy.example.com", true, nil)
+	second.write(t, dir, "proxy")
+	future := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(certFile, future, future))
+	r.checkedAt = time.Time{}
+	cert, err = handshake(r, clientConfig())
+	assert.NoError(t, err)
+	assert.Equal(t, second.cert.SerialNumber, cert.SerialNumber)
+
+	// Broken files are not loaded.
+	assert.NoError(t, os.WriteFile(keyFile, []byte("broken"), 0o600))
+	assert.NoError(t, os.Chtimes(keyFile, future, future.Add(time.Minute)))
+	r.checkedAt = time.Time{}
+	cert, err = handshake(r, clientConfig())
+	assert.NoError(t, err)
+	assert.Equal(t, second.cert.SerialNumber, cert.SerialNumber)
+}
+
+func TestTlsReloader_RequiresClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "clients-ca", true, nil)
+	caFile, _ := ca.write(t, dir, "ca")
+	server := newTestCert(t, "proxy.example.com", false, nil)
+	certFile, keyFile := server.write(t, dir, "proxy")
+	r, err := NewTlsReloader(slog.New(slog.DiscardHandler), TlsFiles{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		ClientCaFile: caFile,
+	})
+	assert.NoError(t, err)
+
+	signed := newTestCert(t, "client", false, ca)
+	unsigned := newTestCert(t, "client", false, nil)
+	for _, test := range []struct {
+		name  string
+		certs []tls.Certificate
+		ok    bool
+	}{
+		{"signed", []tls.Certificate{signed.tlsCertificate()}, true},
+		{"unsigned", []tls.Certificate{unsigned.tlsCertificate()}, false},
+		{"none", nil, false},
+	} {
+		_, err := handshake(r, &tls.Config{InsecureSkipVerify: true, Certificates: test.certs})
+		if test.ok {
+			assert.NoError(t, err, test.name)
+		} else {
+			assert.Error(t, err, test.name)
+		}
+	}
+
+	_, err = NewTlsReloader(slog.New(slog.DiscardHandler), TlsFiles{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		ClientCaFile: keyFile,
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,230 @@
+package hub
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/isacskoglund/rotox/internal/common"
+	"github.com/isacskoglund/rotox/internal/tracing"
+)
+
+// sniffTimeout bounds the time the hub waits for the TLS ClientHello or the
+// HTTP request head of a client before it forwards the connection to the
+// original destination as it is.
+const sniffTimeout = time.Second
+
+// tlsRecordTypeHandshake is the first byte of a TLS ClientHello.
+const tlsRecordTypeHandshake = 0x16
+
+// errSniffed stops the TLS handshake used to read a ClientHello.
+var errSniffed = errors.New("client hello sniffed")
+
+// TransparentApi implements a transparent proxy: it accepts connections that
+// the firewall redirected to the hub, e.g. with iptables REDIRECT, and
+// forwards each of them through the hub's probe network to the destination
+// the client originally connected to. Clients need no proxy configuration,
+// and are therefore not authenticated.
+type TransparentApi struct {
+	logger *slog.Logger  // Logger for transparent proxy operations
+	core   *Core         // Core hub service for request forwarding
+	labels LabelSelector // Label selector of the probes used for every connection
+	sniff  bool          // Whether target host names are read from TLS SNI or the HTTP Host header
+
+	// originalDestination returns the destination of a redirected
+	// connection, replaced in tests.
+	originalDestination func(net.Conn) (netip.AddrPort, error)
+}
+
+// NewTransparentApi creates a new transparent proxy API instance. The
+// original destinations of connections can only be read on Linux.
+func NewTransparentApi(
+	logger *slog.Logger,
+	core *Core,
+) *TransparentApi {
+	return &TransparentApi{
+		logger:              logger,
+		core:                core,
+		originalDestination: originalDestination,
+	}
+}
+
+// SetLabelSelector sets the label selector of the probes used for every
+// connection.
+func (api *TransparentApi) SetLabelSelector(selector LabelSelector) {
+	api.labels = selector
+}
+
+// SetSniffHostnames makes the API read the host name a client asks for from
+// the server name (SNI) of a TLS ClientHello or the Host header of a
+// plaintext HTTP request, and forward the connection to that host name on the
+// original port. Routing rules and rate limits then see the host name instead
+// of the IP address. Connections without one are forwarded to the original
+// destination. Protocols in which the server speaks first are delayed by up
+// to sniffTimeout.
+func (api *TransparentApi) SetSniffHostnames(sniff bool) {
+	api.sniff = sniff
+}
+
+// Serve accepts incoming connections on the listener and handles each
+// of them in a separate goroutine. It blocks until the listener fails.
+func (api *TransparentApi) Serve(lis net.Listener) error {
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			return err
+		}
+		go api.handle(conn)
+	}
+}
+
+func (api *TransparentApi) handle(conn net.Conn) {
+	defer conn.Close()
+	traceId, err := uuid.NewRandom()
+	if err != nil {
+		panic(fmt.Errorf("failed to randomize uuid: %w", err))
+	}
+	ctx := tracing.WithTraceId(context.Background(), traceId.String())
+
+	dst, err := api.originalDestination(conn)
+	if err != nil {
+		api.logger.LogAttrs(
+			ctx,
+			slog.LevelWarn,
+			"Failed to read original destination of transparent connection",
+			slog.Any("error", err),
+		)
+		return
+	}
+	// Connections made to the listener itself were not redirected, and would
+	// be forwarded back to the hub.
+	if local, ok := conn.LocalAddr().(*net.TCPAddr); ok && addrPortEqual(local.AddrPort(), dst) {
+		api.logger.LogAttrs(
+			ctx,
+			slog.LevelInfo,
+			"Refusing transparent connection that was not redirected",
+			slog.String("destination", dst.String()),
+		)
+		return
+	}
+
+	targetAddress := dst.String()
+	var sniffed []byte
+	if api.sniff {
+		conn.SetReadDeadline(time.Now().Add(sniffTimeout))
+		var host string
+		host, sniffed = sniffHostname(conn)
+		conn.SetReadDeadline(time.Time{})
+		if host != "" {
+			targetAddress = net.JoinHostPort(host, strconv.Itoa(int(dst.Port())))
+		}
+	}
+
+	api.logger.LogAttrs(
+		ctx,
+		slog.LevelInfo,
+		"Handling transparent connection",
+		slog.String("destination", dst.String()),
+		slog.String("target", targetAddress),
+	)
+
+	// Waits before the connection is accepted end when the client leaves.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	watch := watchClient(conn, cancel)
+	defer watch.stop()
+
+	accept := func() (common.Conn, error) {
+		return &customConn{
+			Reader: io.MultiReader(bytes.NewReader(sniffed), watch.stop()),
+			Writer: conn,
+			Closer: conn,
+			namer:  &customNamer{name: "client"},
+		}, nil
+	}
+	// There is no protocol to report errors to the client in, the connection
+	// is closed instead.
+	err = api.core.forward(ctx, targetAddress, routingHints{labels: api.labels}, accept)
+	if err != nil {
+		api.logger.LogAttrs(
+			ctx,
+			slog.LevelInfo,
+			"Failed to forward transparent connection",
+			slog.Any("error", err),
+			dialInfoAttr(dialInfoOfError(err)),
+		)
+	}
+}
+
+// addrPortEqual reports whether a and b are the same address and port,
+// comparing IPv4-mapped IPv6 addresses as IPv4 addresses.
+func addrPortEqual(a, b netip.AddrPort) bool {
+	return a.Addr().Unmap() == b.Addr().Unmap() && a.Port() == b.Port()
+}
+
+// sniffHostname reads the start of a connection to find the host name the
+// client asks for: the server name of a TLS ClientHello, or the Host header of
+// a plaintext HTTP request. The host name is empty if there is none. The bytes
+// read are returned too, and must be replayed to the target.
+func sniffHostname(r io.Reader) (string, []byte) {
+	var read bytes.Buffer
+	br := bufio.NewReader(io.TeeReader(r, &read))
+	first, err := br.Peek(1)
+	if err != nil {
+		return "", read.Bytes()
+	}
+
+	var host string
+	if first[0] == tlsRecordTypeHandshake {
+		host = sniffServerName(br)
+	} else if req, err := http.ReadRequest(br); err == nil {
+		host = req.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+	}
+	// IP addresses are no better than the original destination.
+	if _, err := netip.ParseAddr(host); err == nil {
+		host = ""
+	}
+	return host, read.Bytes()
+}
+
+// sniffServerName reads a TLS ClientHello from r and returns its server
+// name, which is empty if the client sent none.
+func sniffServerName(r io.Reader) string {
+	var serverName string
+	tls.Server(sniffConn{r: r}, &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverName = hello.ServerName
+			return nil, errSniffed
+		},
+	}).Handshake()
+	return serverName
+}
+
+// sniffConn is a read-only connection for sniffServerName. The TLS handshake
+// only reads from it, and the alerts it writes on failure are discarded.
+type sniffConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (conn sniffConn) Read(p []byte) (int, error) {
+	return conn.r.Read(p)
+}
+
+func (conn sniffConn) Write(p []byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
@@ -0,0 +1,111 @@
+package hub
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/isacskoglund/rotox/internal/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// clientHello returns the ClientHello a TLS client sends to serverName.
+func clientHello(t *testing.T, serverName string) []byte {
+	client, server := net.Pipe()
+	go func() {
+		tls.Client(client, &tls.Config{ServerName: serverName, InsecureSkipVerify: true}).Handshake()
+		client.Close()
+	}()
+	var hello bytes.Buffer
+	sniffServerName(io.TeeReader(server, &hello))
+	server.Close()
+	assert.NotZero(t, hello.Len())
+	return hello.Bytes()
+}
+
+func TestSniffHostname(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		host string
+	}{
+		{"tls", clientHello(t, "example.com"), "example.com"},
+		{"tls without server name", clientHello(t, ""), ""},
+		{"http", []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\nbody"), "example.com"},
+		{"http with port", []byte("GET / HTTP/1.1\r\nHost: example.com:8080\r\n\r\n"), "example.com"},
+		{"http with ip address", []byte("GET / HTTP/1.1\r\nHost: 10.0.0.1\r\n\r\n"), ""},
+		{"other", []byte("SSH-2.0-OpenSSH_9.6\r\n"), ""},
+		{"empty", nil, ""},
+	}
+	for _, test := range tests {
+		host, sniffed := sniffHostname(bytes.NewReader(test.data))
+		assert.Equal(t, test.host, host, test.name)
+		// What was read is a prefix of the data, the rest is still unread.
+		assert.True(t, bytes.HasPrefix(test.data, sniffed), test.name)
+	}
+}
+
+// targetDialer is a dialer that records the addresses it dials and connects
+// to a pipe.
+type targetDialer struct {
+	addresses chan string
+	target    net.Conn // Target side of the last dialed pipe
+}
+
+func (d *targetDialer) Dial(_ context.Context, address string) (common.Conn, error) {
+	hubSide, targetSide := net.Pipe()
+	d.target = targetSide
+	d.addresses <- address
+	return &customConn{
+		Reader: hubSide,
+		Writer: hubSide,
+		Closer: hubSide,
+		namer:  &customNamer{name: "target"},
+	}, nil
+}
+
+func TestTransparentApi_Handle(t *testing.T) {
+	dst := netip.MustParseAddrPort("93.184.216.34:443")
+	for _, test := range []struct {
+		name   string
+		sniff  bool
+		data   []byte
+		target string
+	}{
+		{"original destination", false, []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"), "93.184.216.34:443"},
+		{"sniffed http host", true, []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"), "example.com:443"},
+		{"sniffed server name", true, clientHello(t, "example.com"), "example.com:443"},
+	} {
+		dialer := &targetDialer{addresses: make(chan string, 1)}
+		core := NewCore(slog.New(slog.DiscardHandler), []ProbeSpec{{Name: "a", Dialer: dialer}})
+		api := NewTransparentApi(slog.New(slog.DiscardHandler), core)
+		api.SetSniffHostnames(test.sniff)
+		api.originalDestination = func(net.Conn) (netip.AddrPort, error) {
+			return dst, nil
+		}
+
+		client, hubSide := net.Pipe()
+		done := make(chan struct{})
+		go func() {
+			api.handle(hubSide)
+			close(done)
+		}()
+		go client.Write(test.data)
+
+		assert.Equal(t, test.target, <-dialer.addresses, test.name)
+		// The sniffed bytes are replayed to the target.
+		received := make([]byte, len(test.data))
+		_, err := io.ReadFull(dialer.target, received)
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.data, received, test.name)
+
+		dialer.target.Close()
+		client.Close()
+		<-done
+	}
+}
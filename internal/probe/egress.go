@@ -0,0 +1,133 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// egressRefreshInterval is how often the egress IP is discovered again.
+	egressRefreshInterval = 5 * time.Minute
+	// egressDiscoveryTimeout is the maximum duration of a single discovery.
+	egressDiscoveryTimeout = 5 * time.Second
+	// maxEchoResponseSize is the maximum accepted size of an echo response.
+	maxEchoResponseSize = 64
+)
+
+// EgressIpDiscoverer discovers the public IP address that the probe egresses
+// from, by asking an echo service such as https://api.ipify.org.
+// It implements common.EgressIpSource.
+//
+// Discovery happens in the background, so that reporting the address never
+// delays a connection. The address is refreshed periodically, since the
+// egress IP of serverless instances may change.
+type EgressIpDiscoverer struct {
+	logger  *slog.Logger // Logger for discovery operations
+	echoURL string       // Configured echo URL, takes precedence over the one provided by the hub
+	client  *http.Client // HTTP client used to query the echo service
+
+	mu           sync.Mutex // Protects the fields below
+	ip           string     // Most recently discovered address, empty if unknown
+	discoveredAt time.Time  // When the last discovery was attempted
+	discovering  bool       // Whether a discovery is in progress
+}
+
+// NewEgressIpDiscoverer creates a discoverer using echoURL, or the URL provided
+// by the hub if echoURL is empty. The echo service is connected to through
+// guard like any target, so that the URL provided by the hub cannot reach the
+// probe's internal network. A nil guard allows every address.
+func NewEgressIpDiscoverer(logger *slog.Logger, echoURL string, guard *DialGuard) *EgressIpDiscoverer {
+	dialer := &net.Dialer{Timeout: egressDiscoveryTimeout}
+	if guard != nil {
+		dialer.Control = guard.Control
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	// A proxy would connect to the echo service past the guard.
+	transport.Proxy = nil
+	return &EgressIpDiscoverer{
+		logger:  logger,
+		echoURL: echoURL,
+		client:  &http.Client{Timeout: egressDiscoveryTimeout, Transport: transport},
+	}
+}
+
+// EgressIp returns the most recently discovered egress IP, or an empty string
+// if it is not known yet. It starts a discovery in the background if the
+// address is due for a refresh.
+func (d *EgressIpDiscoverer) EgressIp(echoURL string) string {
+	if d.echoURL != "" {
+		echoURL = d.echoURL
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if echoURL != "" && !d.discovering && time.Since(d.discoveredAt) >= egressRefreshInterval {
+		d.discovering = true
+		go d.discover(echoURL)
+	}
+	return d.ip
+}
+
+// discover queries the echo service and stores the address on success.
+func (d *EgressIpDiscoverer) discover(echoURL string) {
+	ctx, cancel := context.WithTimeout(context.Background(), egressDiscoveryTimeout)
+	defer cancel()
+	ip, err := d.fetch(ctx, echoURL)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.discovering = false
+	d.discoveredAt = time.Now()
+	if err != nil {
+		d.logger.LogAttrs(
+			ctx,
+			slog.LevelWarn,
+			"Failed to discover egress IP.",
+			slog.String("echo_url", echoURL),
+			slog.Any("error", err),
+		)
+		return
+	}
+	if ip != d.ip {
+		d.logger.LogAttrs(
+			ctx,
+			slog.LevelInfo,
+			"Discovered egress IP.",
+			slog.String("egress_ip", ip),
+		)
+	}
+	d.ip = ip
+}
+
+// fetch returns the IP address in the response of the echo service.
+func (d *EgressIpDiscoverer) fetch(ctx context.Context, echoURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, echoURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid echo url: %w", err)
+	}
+	res, err := d.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("echo service responded with status %d", res.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxEchoResponseSize))
+	if err != nil {
+		return "", fmt.Errorf("failed to read echo response: %w", err)
+	}
+	ip := net.ParseIP(strings.TrimSpace(string(body)))
+	if ip == nil {
+		return "", fmt.Errorf("echo response is not an IP address: %q", body)
+	}
+	return ip.String(), nil
+}
@@ -0,0 +1,88 @@
+package probe
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"syscall"
+)
+
+// DefaultBlockedCidrs are the address ranges that a DialGuard blocks unless
+// they are explicitly allowed: loopback, link-local (including the cloud
+// metadata servers at 169.254.169.254 and fd00:ec2::254), private, shared and
+// unspecified addresses.
+var DefaultBlockedCidrs = []string{
+	"0.0.0.0/8",      // "This" network, 0.0.0.0 reaches the local host
+	"10.0.0.0/8",     // Private (RFC 1918)
+	"100.64.0.0/10",  // Shared address space (RFC 6598)
+	"127.0.0.0/8",    // Loopback
+	"169.254.0.0/16", // Link-local, including metadata servers
+	"172.16.0.0/12",  // Private (RFC 1918)
+	"192.168.0.0/16", // Private (RFC 1918)
+	"::/128",         // Unspecified
+	"::1/128",        // Loopback
+	"fc00::/7",       // Unique local, including metadata servers
+	"fe80::/10",      // Link-local
+}
+
+// BlockedAddressError is returned by DialGuard.Control for addresses that the
+// guard blocks.
+type BlockedAddressError struct {
+	Address netip.Addr // The blocked address
+}
+
+func (err *BlockedAddressError) Error() string {
+	return fmt.Sprintf("address %s is blocked by policy", err.Address)
+}
+
+// DialGuard keeps the probe from connecting to its own internal network,
+// e.g. the metadata server of a cloud platform, on behalf of the hub.
+//
+// The guard is installed as the Control hook of a net.Dialer, so every
+// address is checked after the host name has been resolved, right before
+// connecting. Host names resolving to a blocked address, including through
+// DNS rebinding, are thereby blocked as well.
+type DialGuard struct {
+	blocked []netip.Prefix // Ranges that are blocked
+	allowed []netip.Prefix // Ranges that are allowed even if blocked
+}
+
+// NewDialGuard creates a guard blocking DefaultBlockedCidrs except for the
+// allowed ranges, given in CIDR notation (e.g. "10.1.0.0/16").
+func NewDialGuard(allowed []string) (*DialGuard, error) {
+	guard := &DialGuard{}
+	for _, cidr := range DefaultBlockedCidrs {
+		guard.blocked = append(guard.blocked, netip.MustParsePrefix(cidr))
+	}
+	for _, cidr := range allowed {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed cidr: %q", cidr)
+		}
+		guard.allowed = append(guard.allowed, prefix.Masked())
+	}
+	return guard, nil
+}
+
+// Allows reports whether the guard allows connecting to addr.
+func (guard *DialGuard) Allows(addr netip.Addr) bool {
+	addr = addr.Unmap().WithZone("")
+	contains := func(prefix netip.Prefix) bool {
+		return prefix.Contains(addr)
+	}
+	return !slices.ContainsFunc(guard.blocked, contains) ||
+		slices.ContainsFunc(guard.allowed, contains)
+}
+
+// Control implements the Control hook of net.Dialer. It returns a
+// *BlockedAddressError if the resolved address is blocked.
+func (guard *DialGuard) Control(network string, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("unexpected dial address %q: %w", address, err)
+	}
+	if !guard.Allows(addrPort.Addr()) {
+		return &BlockedAddressError{Address: addrPort.Addr().Unmap()}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package probe
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/isacskoglund/rotox/internal/common"
+	"github.com/isacskoglund/rotox/internal/fault"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialGuard_Allows(t *testing.T) {
+	guard, err := NewDialGuard([]string{"10.1.0.0/16"})
+	assert.NoError(t, err)
+	tests := []struct {
+		addr    string
+		allowed bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1::1", true},
+		{"127.0.0.1", false},
+		{"0.0.0.0", false},
+		{"169.254.169.254", false},
+		{"10.0.0.1", false},
+		{"10.1.2.3", true},
+		{"172.20.0.1", false},
+		{"192.168.1.1", false},
+		{"100.100.100.100", false},
+		{"::1", false},
+		{"::", false},
+		{"::ffff:127.0.0.1", false},
+		{"fd00:ec2::254", false},
+		{"fe80::1%eth0", false},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.allowed, guard.Allows(netip.MustParseAddr(test.addr)), test.addr)
+	}
+
+	_, err = NewDialGuard([]string{"10.1.0.0"})
+	assert.Error(t, err)
+}
+
+func TestService_ForwardBlockedByGuard(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer lis.Close()
+
+	guard, err := NewDialGuard(nil)
+	assert.NoError(t, err)
+	svc := NewService(slog.New(slog.DiscardHandler), &net.Dialer{Control: guard.Control})
+	accept := func(common.DialInfo) (common.Conn, error) {
+		t.Fatal("the client connection must not be accepted")
+		return nil, nil
+	}
+
+	// The check applies to resolved addresses, not only to IP literals.
+	_, port, _ := net.SplitHostPort(lis.Addr().String())
+	for _, address := range []string{lis.Addr().String(), net.JoinHostPort("localhost", port)} {
+		err = svc.Forward(context.Background(), address, accept)
+		assert.Equal(t, common.ForwardBlockedByPolicy, fault.Code[common.ForwardErrorCode](err), address)
+	}
+}
+
+func TestEgressIpDiscoverer_BlockedByGuard(t *testing.T) {
+	echo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, "203.0.113.7")
+	}))
+	defer echo.Close()
+
+	guard, err := NewDialGuard(nil)
+	assert.NoError(t, err)
+	discoverer := NewEgressIpDiscoverer(slog.New(slog.DiscardHandler), "", guard)
+	_, err = discoverer.fetch(context.Background(), echo.URL)
+	var blocked *BlockedAddressError
+	assert.ErrorAs(t, err, &blocked)
+
+	// Without the guard, the same echo service is reached.
+	ip, err := NewEgressIpDiscoverer(slog.New(slog.DiscardHandler), "", nil).fetch(context.Background(), echo.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "203.0.113.7", ip)
+}
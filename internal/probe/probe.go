@@ -20,6 +20,7 @@ import (
 	"errors"
 	"log/slog"
 	"net"
+	"time"
 
 	"github.com/isacskoglund/rotox/internal/common"
 	"github.com/isacskoglund/rotox/internal/fault"
@@ -51,24 +52,33 @@ func NewService(
 //
 // The function establishes the target connection first, then calls accept to
 // get the client connection, and finally starts bidirectional traffic relay.
+// Info about the dial is passed to accept, or attached to the returned error
+// if the dial fails (see common.DialInfoOf).
 func (svc *Service) Forward(
 	ctx context.Context,
 	address string,
-	accept func() (common.Conn, error),
+	accept func(info common.DialInfo) (common.Conn, error),
 ) error {
+	dialStart := time.Now()
 	targetTcpConn, err := svc.dialer.DialContext(ctx, "tcp", address)
+	info := common.DialInfo{Duration: time.Since(dialStart)}
 	if err != nil {
-		err = interpretDialError(err)
-		return err
+		var opErr *net.OpError
+		if errors.As(err, &opErr) {
+			info.RemoteIp = common.IpOf(opErr.Addr)
+		}
+		return common.WithDialInfo(interpretDialError(err), info)
 	}
 	defer targetTcpConn.Close()
+	info.RemoteIp = common.IpOf(targetTcpConn.RemoteAddr())
+	info.LocalAddress = targetTcpConn.LocalAddr().String()
 
 	targetConn := &namedConn{
 		Conn: targetTcpConn,
 		name: "target",
 	}
 
-	clientConn, err := accept()
+	clientConn, err := accept(info)
 	if err != nil {
 		return err
 	}
@@ -108,17 +118,9 @@ func interpretDialError(err error) error {
 		return nil
 	}
 	prefix := "failed to dial"
-	var dnsErr *net.DNSError
-	if errors.As(err, &dnsErr) {
-		return fault.Wrap(err, prefix, common.ForwardFailedToResolveHost)
-	}
-	var netErr net.Error
-	if errors.As(err, &netErr) && netErr.Timeout() {
-		return fault.Wrap(err, prefix, common.ForwardHostUnreachable)
-	}
-	var opErr *net.OpError
-	if errors.As(err, &opErr) {
-		return fault.Wrap(err, prefix, common.ForwardHostUnreachable)
+	var blockedErr *BlockedAddressError
+	if errors.As(err, &blockedErr) {
+		return fault.Wrap(err, prefix, common.ForwardBlockedByPolicy)
 	}
-	return fault.Wrap(err, prefix, common.ForwardUnknown)
+	return common.InterpretDialError(err, prefix)
 }
@@ -0,0 +1,45 @@
+package probe
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"testing"
+
+	"github.com/isacskoglund/rotox/internal/common"
+	"github.com/isacskoglund/rotox/internal/fault"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_ForwardConnectionRefused(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	address := lis.Addr().String()
+	lis.Close()
+
+	svc := NewService(slog.New(slog.DiscardHandler), &net.Dialer{})
+	err = svc.Forward(context.Background(), address, func(common.DialInfo) (common.Conn, error) {
+		t.Fatal("the client connection must not be accepted")
+		return nil, nil
+	})
+	assert.Equal(t, common.ForwardConnectionRefused, fault.Code[common.ForwardErrorCode](err))
+	info, ok := common.DialInfoOf(err)
+	assert.True(t, ok)
+	assert.Equal(t, "127.0.0.1", info.RemoteIp)
+}
+
+func TestService_ForwardDialInfo(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer lis.Close()
+
+	svc := NewService(slog.New(slog.DiscardHandler), &net.Dialer{})
+	var info common.DialInfo
+	_ = svc.Forward(context.Background(), lis.Addr().String(), func(got common.DialInfo) (common.Conn, error) {
+		info = got
+		return nil, assert.AnError
+	})
+	assert.Equal(t, "127.0.0.1", info.RemoteIp)
+	assert.NotEmpty(t, info.LocalAddress)
+	assert.Positive(t, info.Duration)
+}
@@ -15,6 +15,8 @@ type ConnectionEvent struct {
 	ConnectionId  string    // Unique identifier for the connection
 	ClientAddress string    // Address of the connecting client
 	TargetAddress string    // Address of the target destination
+	Probe         string    // Name of the probe used for the connection
+	EgressIp      string    // Public IP address of the probe, empty if unknown
 	OpenedAt      time.Time // When the connection was established
 	// ClosedAt indicates when the connection was closed.
 	// A zero value indicates the connection is still open.
@@ -29,3 +31,61 @@ type TransferEvent struct {
 	FinishedAt   time.Time // When the transfer completed
 	BytesCount   uint64    // Number of bytes transferred
 }
+
+// ProbeState represents the health state of a probe as seen by the hub.
+type ProbeState string
+
+// Probe states.
+const (
+	ProbeHealthy ProbeState = "HEALTHY" // The probe is used for new connections
+	ProbeEjected ProbeState = "EJECTED" // The probe is excluded from selection
+)
+
+// ProbeEvent represents a change of a probe's health state.
+type ProbeEvent struct {
+	Probe     string     // Name of the probe
+	State     ProbeState // State the probe changed to
+	Reason    string     // Human-readable reason for the change
+	ChangedAt time.Time  // When the state changed
+}
+
+// QueueOutcome represents how a connection left the queue of connections
+// waiting for a probe.
+type QueueOutcome string
+
+// Queue outcomes.
+const (
+	QueueServed   QueueOutcome = "SERVED"    // A probe became available
+	QueueTimedOut QueueOutcome = "TIMED_OUT" // No probe became available in time
+	QueueRejected QueueOutcome = "REJECTED"  // The queue was full
+	QueueCanceled QueueOutcome = "CANCELED"  // The client went away while waiting
+)
+
+// QueueEvent represents a connection that waited for a probe because every
+// probe was at its connection limit. The time spent in the queue is the
+// difference between DequeuedAt and EnqueuedAt.
+type QueueEvent struct {
+	TargetAddress string       // Address of the target destination
+	Outcome       QueueOutcome // How the connection left the queue
+	Depth         int          // Number of connections still waiting after this one left
+	EnqueuedAt    time.Time    // When the connection started waiting
+	DequeuedAt    time.Time    // When the connection stopped waiting
+}
+
+// PolicyViolation represents why the access policy denied a connection.
+type PolicyViolation string
+
+// Policy violations.
+const (
+	PolicyPortNotAllowed PolicyViolation = "PORT_NOT_ALLOWED" // The target port is not allowed
+	PolicyDomainDenied   PolicyViolation = "DOMAIN_DENIED"    // The target host matches a denied domain pattern
+	PolicyIpRangeDenied  PolicyViolation = "IP_RANGE_DENIED"  // The target address is in a denied IP range
+)
+
+// PolicyEvent represents a connection denied by the access policy of the hub.
+type PolicyEvent struct {
+	TargetAddress string          // Address of the target destination
+	Violation     PolicyViolation // Why the connection was denied
+	Rule          string          // Denied port, domain pattern or IP range that matched
+	DeniedAt      time.Time       // When the connection was denied
+}
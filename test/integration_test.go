@@ -4,16 +4,27 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/tls"
+	"encoding/base64"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/isacskoglund/rotox/internal/hub"
+	"github.com/isacskoglund/rotox/internal/probe"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/net/http2"
 	"google.golang.org/grpc/test/bufconn"
 )
 
@@ -35,7 +46,7 @@ func TestConnectWithSingleProbe(t *testing.T) {
 		targetDialer.On("DialContext", mock.Anything, "tcp", target).Once().Return(targetConn, nil)
 		logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel}))
 		serveProbe(grpcLis, logger.With("logger", "probe"), targetDialer)
-		serveHub(httpLis, logger.With("logger", "hub"), []*bufconn.Listener{grpcLis})
+		serveHub(httpLis, logger.With("logger", "hub"), []*bufconn.Listener{grpcLis}, nil)
 	}
 
 	// 2. Establish connection
@@ -89,3 +100,557 @@ func TestConnectWithSingleProbe(t *testing.T) {
 		assert.Equal(t, 0, n)
 	}
 }
+
+func TestConnectWithProxyAuthentication(t *testing.T) {
+	target := "www.example.com:443"
+	hash, err := bcrypt.GenerateFromPassword([]byte("alice-password"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	usersFile := filepath.Join(t.TempDir(), "users")
+	users := "# comment\n"
+	for _, user := range []string{"alice", "dev-group", "ops-region-eu"} {
+		users += user + ":" + string(hash) + "\n"
+	}
+	err = os.WriteFile(usersFile, []byte(users), 0o600)
+	assert.NoError(t, err)
+	usersAuth, err := hub.NewUsersAuthenticator(usersFile)
+	assert.NoError(t, err)
+	auth := hub.NewMultiAuthenticator(hub.NewSecretAuthenticator("shared-secret"), usersAuth)
+
+	type Case struct {
+		name           string
+		authorization  string
+		expectedStatus int
+	}
+
+	cases := []Case{
+		{
+			name:           "missing credentials",
+			expectedStatus: http.StatusProxyAuthRequired,
+		},
+		{
+			name:           "invalid scheme",
+			authorization:  "Bearer shared-secret",
+			expectedStatus: http.StatusProxyAuthRequired,
+		},
+		{
+			name:           "wrong password",
+			authorization:  basicAuth("alice", "wrong-password"),
+			expectedStatus: http.StatusProxyAuthRequired,
+		},
+		{
+			name:           "shared secret",
+			authorization:  basicAuth("anyone", "shared-secret"),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "users file",
+			authorization:  basicAuth("alice", "alice-password"),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			// Without username hints, usernames are used as they are.
+			name:           "hyphenated username",
+			authorization:  basicAuth("dev-group", "alice-password"),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "hyphenated username with hint key",
+			authorization:  basicAuth("ops-region-eu", "alice-password"),
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			httpLis := bufconn.Listen(bufSize)
+			defer httpLis.Close()
+			{
+				targetDialer := &mockDialer{}
+				grpcLis := bufconn.Listen(bufSize)
+				defer grpcLis.Close()
+				targetDialer.On("DialContext", mock.Anything, "tcp", target).Maybe().Return(newMockConn(1024), nil)
+				logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+				serveProbe(grpcLis, logger.With("logger", "probe"), targetDialer)
+				serveHub(httpLis, logger.With("logger", "hub"), []*bufconn.Listener{grpcLis}, auth)
+			}
+
+			httpConn, err := httpLis.DialContext(context.Background())
+			assert.NoError(t, err, "dial httpLis")
+			defer httpConn.Close()
+			connectRequest := http.Request{
+				Method: "CONNECT",
+				Host:   target,
+				URL: &url.URL{
+					Opaque: target,
+				},
+				Header: http.Header{},
+			}
+			if c.authorization != "" {
+				connectRequest.Header.Set("Proxy-Authorization", c.authorization)
+			}
+			err = connectRequest.Write(httpConn)
+			assert.NoError(t, err, "write connectRequest to httpConn")
+			res, err := http.ReadResponse(bufio.NewReader(httpConn), &connectRequest)
+			assert.NoError(t, err, "read connect response")
+			assert.Equal(t, c.expectedStatus, res.StatusCode, "connect response status code")
+			if c.expectedStatus != http.StatusProxyAuthRequired {
+				return
+			}
+			assert.Equal(t, `Basic realm="rotox"`, res.Header.Get("Proxy-Authenticate"))
+
+			// Plaintext requests are authenticated the same way.
+			plainConn, err := httpLis.DialContext(context.Background())
+			assert.NoError(t, err, "dial httpLis")
+			defer plainConn.Close()
+			plainRequest, err := http.NewRequest(http.MethodGet, "http://www.example.com/", nil)
+			assert.NoError(t, err)
+			if c.authorization != "" {
+				plainRequest.Header.Set("Proxy-Authorization", c.authorization)
+			}
+			err = plainRequest.WriteProxy(plainConn)
+			assert.NoError(t, err, "write plainRequest to plainConn")
+			res, err = http.ReadResponse(bufio.NewReader(plainConn), plainRequest)
+			assert.NoError(t, err, "read plain response")
+			assert.Equal(t, http.StatusProxyAuthRequired, res.StatusCode, "plain response status code")
+			assert.Equal(t, `Basic realm="rotox"`, res.Header.Get("Proxy-Authenticate"))
+		})
+	}
+}
+
+func basicAuth(username string, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+func TestSocks5ConnectWithSingleProbe(t *testing.T) {
+	type Case struct {
+		name        string
+		secret      string
+		method      byte
+		credentials []byte
+		request     []byte
+		target      string
+	}
+
+	cases := []Case{
+		{
+			name:    "domain without authentication",
+			method:  0x00,
+			request: []byte{0x05, 0x01, 0x00, 0x03, 15, 'w', 'w', 'w', '.', 'e', 'x', 'a', 'm', 'p', 'l', 'e', '.', 'c', 'o', 'm', 0x01, 0xbb},
+			target:  "www.example.com:443",
+		},
+		{
+			name:    "ipv4 without authentication",
+			method:  0x00,
+			request: []byte{0x05, 0x01, 0x00, 0x01, 10, 0, 0, 1, 0x00, 0x50},
+			target:  "10.0.0.1:80",
+		},
+		{
+			name:    "ipv6 without authentication",
+			method:  0x00,
+			request: []byte{0x05, 0x01, 0x00, 0x04, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0x1f, 0x90},
+			target:  "[2001:db8::1]:8080",
+		},
+		{
+			name:        "domain with authentication",
+			secret:      "secret",
+			method:      0x02,
+			credentials: []byte{0x01, 4, 'u', 's', 'e', 'r', 6, 's', 'e', 'c', 'r', 'e', 't'},
+			request:     []byte{0x05, 0x01, 0x00, 0x03, 11, 'e', 'x', 'a', 'm', 'p', 'l', 'e', '.', 'c', 'o', 'm', 0x00, 0x50},
+			target:      "example.com:80",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// 1. Spin up hub and probe.
+			targetConn := newMockConn(1024)
+			socks5Lis := bufconn.Listen(bufSize)
+			defer socks5Lis.Close()
+			{
+				targetDialer := &mockDialer{}
+				grpcLis := bufconn.Listen(bufSize)
+				defer grpcLis.Close()
+				targetDialer.On("DialContext", mock.Anything, "tcp", c.target).Once().Return(targetConn, nil)
+				logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+				serveProbe(grpcLis, logger.With("logger", "probe"), targetDialer)
+				serveHubSocks5(socks5Lis, logger.With("logger", "hub"), []*bufconn.Listener{grpcLis}, c.secret)
+			}
+
+			// 2. Negotiate and establish connection.
+			socks5Conn, err := socks5Lis.DialContext(context.Background())
+			assert.NoError(t, err, "dial socks5Lis")
+			{
+				_, err = socks5Conn.Write([]byte{0x05, 0x01, c.method})
+				assert.NoError(t, err, "write greeting")
+				selection := make([]byte, 2)
+				_, err = io.ReadFull(socks5Conn, selection)
+				assert.NoError(t, err, "read method selection")
+				assert.Equal(t, []byte{0x05, c.method}, selection, "method selection")
+
+				if c.credentials != nil {
+					_, err = socks5Conn.Write(c.credentials)
+					assert.NoError(t, err, "write credentials")
+					status := make([]byte, 2)
+					_, err = io.ReadFull(socks5Conn, status)
+					assert.NoError(t, err, "read authentication status")
+					assert.Equal(t, []byte{0x01, 0x00}, status, "authentication status")
+				}
+
+				_, err = socks5Conn.Write(c.request)
+				assert.NoError(t, err, "write request")
+				reply := make([]byte, 10)
+				_, err = io.ReadFull(socks5Conn, reply)
+				assert.NoError(t, err, "read reply")
+				assert.Equal(t, byte(0x00), reply[1], "reply code")
+			}
+
+			// 3. Send data
+			{
+				dataToSend := []byte("some-random-content-being-sent")
+				_, err = socks5Conn.Write(dataToSend)
+				assert.NoError(t, err)
+				written := targetConn.fromWrite(time.Second)
+				assert.Equal(t, dataToSend, written)
+			}
+
+			// 4. Close the connection from the target side
+			{
+				targetConn.Close()
+				received := make([]byte, 10)
+				n, err := socks5Conn.Read(received)
+				assert.Equal(t, io.EOF, err)
+				assert.Equal(t, 0, n)
+			}
+		})
+	}
+}
+
+func TestConnectWithStickySession(t *testing.T) {
+	target := "www.example.com:443"
+
+	httpLis := bufconn.Listen(bufSize)
+	defer httpLis.Close()
+	targetDialers := []*mockDialer{{}, {}}
+	{
+		logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		grpcLiss := make([]*bufconn.Listener, len(targetDialers))
+		for i, targetDialer := range targetDialers {
+			grpcLiss[i] = bufconn.Listen(bufSize)
+			defer grpcLiss[i].Close()
+			targetDialer.On("DialContext", mock.Anything, "tcp", target).Maybe().Return(newMockConn(1024), nil)
+			serveProbe(grpcLiss[i], logger.With("logger", "probe"), targetDialer)
+		}
+		core := serveHub(httpLis, logger.With("logger", "hub"), grpcLiss, nil)
+		assert.NoError(t, core.SetUsernameGrammar(hub.DefaultUsernameGrammar))
+	}
+
+	connect := func(header http.Header) {
+		httpConn, err := httpLis.DialContext(context.Background())
+		assert.NoError(t, err, "dial httpLis")
+		defer httpConn.Close()
+		connectRequest := http.Request{
+			Method: "CONNECT",
+			Host:   target,
+			URL: &url.URL{
+				Opaque: target,
+			},
+			Header: header,
+		}
+		err = connectRequest.Write(httpConn)
+		assert.NoError(t, err, "write connectRequest to httpConn")
+		res, err := http.ReadResponse(bufio.NewReader(httpConn), &connectRequest)
+		assert.NoError(t, err, "read connect response")
+		assert.Equal(t, http.StatusOK, res.StatusCode, "connect response status code")
+	}
+
+	// Requests within a session all use the same probe.
+	for range 3 {
+		connect(http.Header{"Proxy-Authorization": {basicAuth("user-session-abc123", "")}})
+	}
+	for range 3 {
+		connect(http.Header{"X-Rotox-Session": {"def456"}})
+	}
+	assert.ElementsMatch(
+		t,
+		[]int{3, 3},
+		[]int{len(targetDialers[0].Calls), len(targetDialers[1].Calls)},
+		"calls per probe",
+	)
+
+	// Requests without a session rotate between probes.
+	connect(http.Header{})
+	connect(http.Header{})
+	assert.Equal(t, 4, len(targetDialers[0].Calls), "calls to first probe")
+	assert.Equal(t, 4, len(targetDialers[1].Calls), "calls to second probe")
+}
+
+func TestConnectWithFailover(t *testing.T) {
+	target := "www.example.com:443"
+
+	httpLis := bufconn.Listen(bufSize)
+	defer httpLis.Close()
+	targetDialer := &mockDialer{}
+	{
+		logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		// The first probe is down, so every connection has to fail over.
+		downLis := bufconn.Listen(bufSize)
+		downLis.Close()
+		upLis := bufconn.Listen(bufSize)
+		defer upLis.Close()
+		targetDialer.On("DialContext", mock.Anything, "tcp", target).Return(newMockConn(1024), nil)
+		serveProbe(upLis, logger.With("logger", "probe"), targetDialer)
+		serveHub(httpLis, logger.With("logger", "hub"), []*bufconn.Listener{downLis, upLis}, nil)
+	}
+
+	for range 4 {
+		httpConn, err := httpLis.DialContext(context.Background())
+		assert.NoError(t, err, "dial httpLis")
+		connectRequest := http.Request{
+			Method: "CONNECT",
+			Host:   target,
+			URL: &url.URL{
+				Opaque: target,
+			},
+		}
+		err = connectRequest.Write(httpConn)
+		assert.NoError(t, err, "write connectRequest to httpConn")
+		res, err := http.ReadResponse(bufio.NewReader(httpConn), &connectRequest)
+		assert.NoError(t, err, "read connect response")
+		assert.Equal(t, http.StatusOK, res.StatusCode, "connect response status code")
+		httpConn.Close()
+	}
+	assert.Equal(t, 4, len(targetDialer.Calls), "calls to healthy probe")
+}
+
+func TestConnectReportsEgressIp(t *testing.T) {
+	target := "www.example.com:443"
+	egressIp := "203.0.113.7"
+
+	echo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.WriteString(w, egressIp+"\n")
+	}))
+	defer echo.Close()
+
+	httpLis := bufconn.Listen(bufSize)
+	defer httpLis.Close()
+	var core *hub.Core
+	{
+		logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		targetDialer := &mockDialer{}
+		targetDialer.On("DialContext", mock.Anything, "tcp", target).Return(newMockConn(1024), nil)
+		grpcLis := bufconn.Listen(bufSize)
+		defer grpcLis.Close()
+
+		// Let the probe discover its egress IP before connecting.
+		discoverer := probe.NewEgressIpDiscoverer(logger.With("logger", "probe"), echo.URL, nil)
+		assert.Eventually(t, func() bool {
+			return discoverer.EgressIp("") == egressIp
+		}, time.Second, 10*time.Millisecond, "discover egress IP")
+		serveProbeWithEgress(grpcLis, logger.With("logger", "probe"), targetDialer, discoverer)
+
+		core = newHubCore(logger.With("logger", "hub"), []*bufconn.Listener{grpcLis})
+		go http.Serve(httpLis, hub.NewHttpApi(logger.With("logger", "hub"), core))
+	}
+
+	httpConn, err := httpLis.DialContext(context.Background())
+	assert.NoError(t, err, "dial httpLis")
+	defer httpConn.Close()
+	connectRequest := http.Request{
+		Method: "CONNECT",
+		Host:   target,
+		URL: &url.URL{
+			Opaque: target,
+		},
+	}
+	err = connectRequest.Write(httpConn)
+	assert.NoError(t, err, "write connectRequest to httpConn")
+	res, err := http.ReadResponse(bufio.NewReader(httpConn), &connectRequest)
+	assert.NoError(t, err, "read connect response")
+	assert.Equal(t, http.StatusOK, res.StatusCode, "connect response status code")
+
+	ips := core.EgressIps()
+	if assert.Len(t, ips, 1, "egress ips") {
+		assert.Equal(t, egressIp, ips[0].Ip)
+		assert.Equal(t, []string{"probe-0"}, ips[0].Probes)
+		assert.Equal(t, uint64(1), ips[0].TotalConnections)
+		assert.True(t, ips[0].Live)
+	}
+}
+
+func TestConnectErrorResponse(t *testing.T) {
+	target := "unknown.example.com:443"
+	httpLis := bufconn.Listen(bufSize)
+	defer httpLis.Close()
+	{
+		logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		targetDialer := &mockDialer{}
+		targetDialer.On("DialContext", mock.Anything, "tcp", target).Return(nil, &net.DNSError{
+			Err:        "no such host",
+			Name:       "unknown.example.com",
+			IsNotFound: true,
+		})
+		grpcLis := bufconn.Listen(bufSize)
+		defer grpcLis.Close()
+		serveProbe(grpcLis, logger.With("logger", "probe"), targetDialer)
+		serveHub(httpLis, logger.With("logger", "hub"), []*bufconn.Listener{grpcLis}, nil)
+	}
+
+	httpConn, err := httpLis.DialContext(context.Background())
+	assert.NoError(t, err, "dial httpLis")
+	defer httpConn.Close()
+	connectRequest := http.Request{
+		Method: "CONNECT",
+		Host:   target,
+		URL: &url.URL{
+			Opaque: target,
+		},
+	}
+	err = connectRequest.Write(httpConn)
+	assert.NoError(t, err, "write connectRequest to httpConn")
+	res, err := http.ReadResponse(bufio.NewReader(httpConn), &connectRequest)
+	assert.NoError(t, err, "read connect response")
+	assert.Equal(t, http.StatusBadGateway, res.StatusCode, "connect response status code")
+	assert.Equal(t, "FAILED_TO_RESOLVE_HOST", res.Header.Get("X-Rotox-Error"))
+	traceId := res.Header.Get("X-Rotox-Trace-Id")
+	assert.NotEmpty(t, traceId)
+
+	body, err := io.ReadAll(res.Body)
+	assert.NoError(t, err, "read error body")
+	assert.Equal(t, strings.Join([]string{
+		"error: FAILED_TO_RESOLVE_HOST",
+		"probe: probe-0",
+		"trace_id: " + traceId,
+		"",
+	}, "\n"), string(body))
+}
+
+func TestPlainRequestsWithKeepAlive(t *testing.T) {
+	targetA := newMockConn(1024)
+	targetB := newMockConn(1024)
+	httpLis := bufconn.Listen(bufSize)
+	defer httpLis.Close()
+	{
+		logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		targetDialer := &mockDialer{}
+		targetDialer.On("DialContext", mock.Anything, "tcp", "a.example.com:80").Once().Return(targetA, nil)
+		targetDialer.On("DialContext", mock.Anything, "tcp", "b.example.com:80").Once().Return(targetB, nil)
+		grpcLis := bufconn.Listen(bufSize)
+		defer grpcLis.Close()
+		serveProbe(grpcLis, logger.With("logger", "probe"), targetDialer)
+		serveHub(httpLis, logger.With("logger", "hub"), []*bufconn.Listener{grpcLis}, nil)
+	}
+	// The targets respond once they have received the request.
+	respond := func(target *mockConn, response string) <-chan string {
+		received := make(chan string, 1)
+		go func() {
+			first := <-target.writeCh
+			received <- string(first) + string(target.fromWrite(100*time.Millisecond))
+			target.toRead([]byte(response))
+		}()
+		return received
+	}
+	receivedA := respond(targetA, "HTTP/1.1 200 OK\r\nContent-Length: 1\r\n\r\na")
+	receivedB := respond(targetB, "HTTP/1.1 201 Created\r\nTransfer-Encoding: chunked\r\n\r\n1\r\nb\r\n0\r\n\r\n")
+
+	httpConn, err := httpLis.DialContext(context.Background())
+	assert.NoError(t, err, "dial httpLis")
+	defer httpConn.Close()
+	reader := bufio.NewReader(httpConn)
+
+	// Both requests use the same client connection, but go to different targets.
+	get, _ := http.NewRequest("GET", "http://a.example.com/", nil)
+	assert.NoError(t, get.WriteProxy(httpConn), "write GET request")
+	res, err := http.ReadResponse(reader, get)
+	assert.NoError(t, err, "read GET response")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	body, _ := io.ReadAll(res.Body)
+	assert.Equal(t, "a", string(body))
+
+	post, _ := http.NewRequest("POST", "http://b.example.com/upload", strings.NewReader("payload"))
+	post.ContentLength = -1
+	post.Header.Set("Expect", "100-continue")
+	assert.NoError(t, post.WriteProxy(httpConn), "write POST request")
+	res, err = http.ReadResponse(reader, post)
+	assert.NoError(t, err, "read POST response")
+	if res.StatusCode == http.StatusContinue {
+		res, err = http.ReadResponse(reader, post)
+		assert.NoError(t, err, "read POST response")
+	}
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	body, _ = io.ReadAll(res.Body)
+	assert.Equal(t, "b", string(body))
+
+	requestA := <-receivedA
+	assert.True(t, strings.HasPrefix(requestA, "GET http://a.example.com/ HTTP/1.1\r\n"), requestA)
+	requestB := <-receivedB
+	assert.True(t, strings.HasPrefix(requestB, "POST http://b.example.com/upload HTTP/1.1\r\n"), requestB)
+	assert.NotContains(t, requestB, "Expect")
+	assert.True(t, strings.HasSuffix(requestB, "\r\n\r\n7\r\npayload\r\n0\r\n\r\n"), requestB)
+}
+
+func TestConnectOverHttp2(t *testing.T) {
+	targets := []string{"a.example.com:443", "b.example.com:443"}
+	targetConns := []*mockConn{newMockConn(1024), newMockConn(1024)}
+	httpLis := bufconn.Listen(bufSize)
+	defer httpLis.Close()
+	{
+		logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		targetDialer := &mockDialer{}
+		for i, target := range targets {
+			targetDialer.On("DialContext", mock.Anything, "tcp", target).Once().Return(targetConns[i], nil)
+		}
+		grpcLis := bufconn.Listen(bufSize)
+		defer grpcLis.Close()
+		serveProbe(grpcLis, logger.With("logger", "probe"), targetDialer)
+		serveHub(httpLis, logger.With("logger", "hub"), []*bufconn.Listener{grpcLis}, nil)
+	}
+
+	// HTTP/2 with prior knowledge (h2c), every tunnel is a stream of the
+	// same connection.
+	var dials atomic.Int32
+	transport := &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			dials.Add(1)
+			return httpLis.DialContext(ctx)
+		},
+	}
+	defer transport.CloseIdleConnections()
+
+	var responses []*http.Response
+	for i, target := range targets {
+		body, toTarget := io.Pipe()
+		req, err := http.NewRequest("CONNECT", "http://hub", body)
+		assert.NoError(t, err)
+		req.Host = target
+		res, err := transport.RoundTrip(req)
+		assert.NoError(t, err, "connect to %s", target)
+		assert.Equal(t, http.StatusOK, res.StatusCode, "connect response status code")
+		responses = append(responses, res)
+
+		sent := []byte("to-" + target)
+		_, err = toTarget.Write(sent)
+		assert.NoError(t, err)
+		assert.Equal(t, sent, targetConns[i].fromWrite(100*time.Millisecond))
+
+		received := []byte("from-" + target)
+		targetConns[i].toRead(received)
+		buf := make([]byte, len(received))
+		_, err = io.ReadFull(res.Body, buf)
+		assert.NoError(t, err)
+		assert.Equal(t, received, buf)
+		defer toTarget.Close()
+	}
+	assert.Equal(t, int32(1), dials.Load(), "connections to the hub")
+
+	// Closing the target ends its stream only.
+	targetConns[0].Close()
+	rest, err := io.ReadAll(responses[0].Body)
+	assert.NoError(t, err)
+	assert.Empty(t, rest)
+	targetConns[1].toRead([]byte("more"))
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(responses[1].Body, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "more", string(buf))
+}
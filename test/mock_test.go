@@ -17,7 +17,8 @@ type mockDialer struct {
 
 func (m *mockDialer) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
 	args := m.Called(ctx, network, address)
-	return args.Get(0).(net.Conn), args.Error(1)
+	conn, _ := args.Get(0).(net.Conn)
+	return conn, args.Error(1)
 }
 
 type mockConn struct {
@@ -104,11 +105,11 @@ func (m *mockConn) Write(src []byte) (int, error) {
 }
 
 func (m *mockConn) LocalAddr() net.Addr {
-	panic("not implemented")
+	return &net.TCPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 40000}
 }
 
 func (m *mockConn) RemoteAddr() net.Addr {
-	panic("not implemented")
+	return &net.TCPAddr{IP: net.IPv4(93, 184, 216, 34), Port: 443}
 }
 
 func (m *mockConn) SetDeadline(t time.Time) error {
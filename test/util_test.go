@@ -6,7 +6,6 @@ import (
 	"log"
 	"log/slog"
 	"net"
-	"net/http"
 
 	forward_pb "github.com/isacskoglund/rotox/gen/go/forward/v1"
 	"github.com/isacskoglund/rotox/internal/common"
@@ -19,11 +18,18 @@ import (
 )
 
 func serveProbe(lis net.Listener, logger *slog.Logger, dialer *mockDialer) {
+	serveProbeWithEgress(lis, logger, dialer, nil)
+}
+
+func serveProbeWithEgress(lis net.Listener, logger *slog.Logger, dialer *mockDialer, egress common.EgressIpSource) {
 	probe := grpc_transport.NewForwardServer(
 		logger,
 		probe.NewService(logger, dialer),
 	)
 	probe.SetReadFromBufSize(10)
+	if egress != nil {
+		probe.SetEgressIpSource(egress)
+	}
 	s := grpc.NewServer()
 	forward_pb.RegisterForwardServiceServer(s, probe)
 	go func() {
@@ -37,9 +43,38 @@ func serveProbe(lis net.Listener, logger *slog.Logger, dialer *mockDialer) {
 	}()
 }
 
-func serveHub(httpApiHub *bufconn.Listener, logger *slog.Logger, probes []*bufconn.Listener) {
-	dialers := make([]common.Dialer, len(probes))
-	for i := range dialers {
+func serveHub(httpApiHub *bufconn.Listener, logger *slog.Logger, probes []*bufconn.Listener, auth hub.Authenticator) *hub.Core {
+	core := newHubCore(logger, probes)
+	httpApi := hub.NewHttpApi(logger, core)
+	if auth != nil {
+		httpApi.SetAuthenticator(auth)
+	}
+	go func() {
+		err := httpApi.Serve(httpApiHub)
+		if err != nil && err.Error() != "closed" {
+			log.Fatalf("unexpected error when serving http api: %v", err)
+		}
+	}()
+	return core
+}
+
+func serveHubSocks5(socks5Lis *bufconn.Listener, logger *slog.Logger, probes []*bufconn.Listener, secret string) {
+	core := newHubCore(logger, probes)
+	socks5Api := hub.NewSocks5Api(logger, core)
+	if secret != "" {
+		socks5Api.SetAuthenticator(hub.NewSecretAuthenticator(secret))
+	}
+	go func() {
+		err := socks5Api.Serve(socks5Lis)
+		if err != nil && err.Error() != "closed" {
+			log.Fatalf("unexpected error when serving socks5 api: %v", err)
+		}
+	}()
+}
+
+func newHubCore(logger *slog.Logger, probes []*bufconn.Listener) *hub.Core {
+	specs := make([]hub.ProbeSpec, len(probes))
+	for i := range specs {
 		client := grpc_transport.NewForwardClient(
 			forward_pb.NewForwardServiceClient(
 				newGrpcClient(probes[i]),
@@ -47,16 +82,12 @@ func serveHub(httpApiHub *bufconn.Listener, logger *slog.Logger, probes []*bufco
 		)
 		setter := client.(interface{ SetReadFromBufSize(size uint) })
 		setter.SetReadFromBufSize(10)
-		dialers[i] = client
-	}
-	core := hub.NewCore(logger, dialers)
-	httpApi := hub.NewHttpApi(logger, core)
-	go func() {
-		err := http.Serve(httpApiHub, httpApi)
-		if err != nil && err.Error() != "closed" {
-			log.Fatalf("unexpected error when serving http api: %v", err)
+		specs[i] = hub.ProbeSpec{
+			Name:   fmt.Sprintf("probe-%d", i),
+			Dialer: client,
 		}
-	}()
+	}
+	return hub.NewCore(logger, specs)
 }
 
 func newGrpcClient(lis *bufconn.Listener) *grpc.ClientConn {